@@ -0,0 +1,844 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/rand"
+	"regexp/syntax"
+	"sort"
+	"strconv"
+	"sync"
+	"unicode"
+)
+
+// Engine is the algorithm a generator uses to generate strings.
+type Engine int
+
+const (
+	// TreeEngine generates strings by walking the parsed pattern, choosing alternatives and repeat counts at random.
+	// It supports CaptureGroupHandler and Providers, but ignores assertions such as ^ and \b.
+	TreeEngine Engine = iota
+	// AutomatonEngine compiles the pattern into a deterministic automaton, and generates strings by random walks
+	// over it, weighted by the number of strings reachable from each state. Lengths are chosen uniformly, and then
+	// strings of that length uniformly. It respects assertions, lookarounds, MustMatch and MustNotMatch, but
+	// ignores CaptureGroupHandler and Providers, and only measures output length in Runes.
+	//
+	// Repeats aren't generated individually, so MaxUnboundedRepeatCount limits the length of strings instead: unless
+	// MaxOutputLength is set, strings are at most MaxUnboundedRepeatCount runes longer than the shortest string the
+	// pattern matches. Lengths are also limited to 2^22 divided by the number of states in the automaton, and
+	// NewGenerator returns an ErrInvalidArgs error if MaxOutputLength is longer. NewGenerator returns an
+	// ErrUnsupportedOp error if Universe, RepeatDistribution or GroupArgs are set.
+	AutomatonEngine
+)
+
+var engineNames = map[Engine]string{
+	TreeEngine:      "TreeEngine",
+	AutomatonEngine: "AutomatonEngine",
+}
+
+func (e Engine) String() string {
+	if name, ok := engineNames[e]; ok {
+		return name
+	}
+	return fmt.Sprintf("Engine(%d)", int(e))
+}
+
+// Maximum number of states in an automaton. Patterns that need more are reported as too complex.
+const maxAutomatonStates = 10000
+
+// runeInterval is an inclusive range of runes.
+type runeInterval struct {
+	lo, hi rune
+}
+
+// The runes automata generate. The null byte is excluded for consistency with parseCharClass, and surrogates
+// are excluded because they can't be encoded in UTF-8.
+var automatonAlphabet = []runeInterval{{1, 0xd7ff}, {0xe000, unicode.MaxRune}}
+
+// The runes automata are compared over by DiffPatterns. Patterns can differ on the null byte even though
+// generators never produce it.
+var diffAlphabet = []runeInterval{{0, 0xd7ff}, {0xe000, unicode.MaxRune}}
+
+/*
+dfa is a deterministic finite automaton over runes. Transitions are labelled with intervals of runes, and runes
+without a transition go to an implicit dead state.
+
+DFAs are always trimmed: every state can reach an accepting state, so a DFA with no states matches nothing.
+*/
+type dfa struct {
+	// states[0] is the start state.
+	states []dfaState
+}
+
+type dfaState struct {
+	accept bool
+	// Sorted and non-overlapping.
+	transitions []dfaTransition
+}
+
+type dfaTransition struct {
+	lo, hi rune
+	to     int
+}
+
+// step returns the state reached from state on r, or -1 if r leads to the dead state.
+func (d *dfa) step(state int, r rune) int {
+	transitions := d.states[state].transitions
+	i := sort.Search(len(transitions), func(i int) bool {
+		return transitions[i].hi >= r
+	})
+	if i < len(transitions) && transitions[i].lo <= r {
+		return transitions[i].to
+	}
+	return -1
+}
+
+// matches returns true if d accepts s.
+func (d *dfa) matches(s string) bool {
+	if len(d.states) == 0 {
+		return false
+	}
+	state := 0
+	for _, r := range s {
+		if state = d.step(state, r); state < 0 {
+			return false
+		}
+	}
+	return d.states[state].accept
+}
+
+// isFinite returns true if d accepts a finite number of strings. DFAs are trimmed, so that's the case unless a
+// state can reach itself.
+func (d *dfa) isFinite() bool {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	marks := make([]int, len(d.states))
+	var visit func(state int) bool
+	visit = func(state int) bool {
+		marks[state] = visiting
+		for _, t := range d.states[state].transitions {
+			if marks[t.to] == visiting || (marks[t.to] == unvisited && !visit(t.to)) {
+				return false
+			}
+		}
+		marks[state] = visited
+		return true
+	}
+	for state := range d.states {
+		if marks[state] == unvisited && !visit(state) {
+			return false
+		}
+	}
+	return true
+}
+
+// boundaries appends the runes where the transitions of state start and end to result.
+func (d *dfa) boundaries(state int, result []rune) []rune {
+	for _, t := range d.states[state].transitions {
+		result = append(result, t.lo, t.hi+1)
+	}
+	return result
+}
+
+// trim returns d without the states that are unreachable or can't reach an accepting state.
+func (d *dfa) trim() *dfa {
+	// Find the states that can reach an accepting state, by working backwards from the accepting states.
+	predecessors := make([][]int, len(d.states))
+	live := make([]bool, len(d.states))
+	var queue []int
+	for from, state := range d.states {
+		for _, t := range state.transitions {
+			predecessors[t.to] = append(predecessors[t.to], from)
+		}
+		if state.accept {
+			live[from] = true
+			queue = append(queue, from)
+		}
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		for _, from := range predecessors[state] {
+			if !live[from] {
+				live[from] = true
+				queue = append(queue, from)
+			}
+		}
+	}
+
+	result := &dfa{}
+	if len(d.states) == 0 || !live[0] {
+		return result
+	}
+
+	// Number the live states in the order they're reached from the start state.
+	index := map[int]int{0: 0}
+	queue = []int{0}
+	for i := 0; i < len(queue); i++ {
+		state := d.states[queue[i]]
+		trimmed := dfaState{accept: state.accept}
+		for _, t := range state.transitions {
+			if !live[t.to] {
+				continue
+			}
+			to, ok := index[t.to]
+			if !ok {
+				to = len(queue)
+				index[t.to] = to
+				queue = append(queue, t.to)
+			}
+			trimmed.transitions = append(trimmed.transitions, dfaTransition{t.lo, t.hi, to})
+		}
+		result.states = append(result.states, trimmed)
+	}
+	return result
+}
+
+// automatonState is a state of an automaton being built by explore.
+type automatonState interface {
+	// key returns a string that is equal for equivalent states.
+	key() string
+	accept() bool
+	// boundaries returns the runes where next may start returning a different state. Runes between two
+	// consecutive boundaries must all lead to the same state.
+	boundaries() []rune
+	// next returns the state reached on r, or nil if r leads to the dead state.
+	next(r rune) automatonState
+}
+
+// explore builds a dfa over the runes in alphabet from the states reachable from start.
+func explore(start automatonState, alphabet []runeInterval) (*dfa, error) {
+	index := map[string]int{start.key(): 0}
+	queue := []automatonState{start}
+	result := &dfa{}
+
+	for i := 0; i < len(queue); i++ {
+		state := dfaState{accept: queue[i].accept()}
+		for _, interval := range splitAlphabet(alphabet, queue[i].boundaries()) {
+			next := queue[i].next(interval.lo)
+			if next == nil {
+				continue
+			}
+
+			key := next.key()
+			to, ok := index[key]
+			if !ok {
+				if len(queue) >= maxAutomatonStates {
+					return nil, newError(ErrUnsupportedOp, nil, "pattern is too complex: automaton needs more than %d states",
+						maxAutomatonStates)
+				}
+				to = len(queue)
+				index[key] = to
+				queue = append(queue, next)
+			}
+
+			last := len(state.transitions) - 1
+			if last >= 0 && state.transitions[last].to == to && state.transitions[last].hi+1 == interval.lo {
+				state.transitions[last].hi = interval.hi
+			} else {
+				state.transitions = append(state.transitions, dfaTransition{interval.lo, interval.hi, to})
+			}
+		}
+		result.states = append(result.states, state)
+	}
+
+	return result.trim(), nil
+}
+
+// splitAlphabet splits alphabet into intervals that start at each boundary.
+func splitAlphabet(alphabet []runeInterval, boundaries []rune) []runeInterval {
+	sort.Slice(boundaries, func(i, j int) bool {
+		return boundaries[i] < boundaries[j]
+	})
+
+	var result []runeInterval
+	i := 0
+	for _, interval := range alphabet {
+		lo := interval.lo
+		for ; i < len(boundaries) && boundaries[i] <= interval.hi; i++ {
+			if boundaries[i] > lo {
+				result = append(result, runeInterval{lo, boundaries[i] - 1})
+				lo = boundaries[i]
+			}
+		}
+		result = append(result, runeInterval{lo, interval.hi})
+	}
+	return result
+}
+
+// compileDFA builds a dfa over the runes in alphabet that matches the same strings as re. Runes in transparent are
+// ignored by empty-width assertions (e.g. `\b`), so they can be inserted between runes without changing what
+// matches.
+func compileDFA(re *syntax.Regexp, transparent runeInterval, alphabet []runeInterval) (*dfa, error) {
+	prog, err := syntax.Compile(re.Simplify())
+	if err != nil {
+		compileErr := newError(ErrUnsupportedOp, re, "cannot compile /%s/", re)
+		compileErr.Cause = err
+		return nil, compileErr
+	}
+	automaton := &progAutomaton{prog, transparent}
+	return explore(&progState{automaton, []progThread{{uint32(prog.Start), noContext}}, -1}, alphabet)
+}
+
+type progAutomaton struct {
+	prog        *syntax.Prog
+	transparent runeInterval
+}
+
+/*
+progState is a set of threads of a syntax.Prog. Empty-width assertions depend on the next rune, so threads are
+stored at the instruction they've reached, before following empty-width and other non-consuming instructions.
+*/
+type progState struct {
+	*progAutomaton
+	threads []progThread
+	// The previous rune for empty-width assertions: -1 at the start of the text, otherwise a rune with the same
+	// context as the previous rune (see contextRune).
+	prev rune
+}
+
+/*
+progThread is a thread of a syntax.Prog. Empty-width assertions before a transparent rune depend on the rune after
+it, which isn't known yet, so threads that consume a transparent rune guess the context of the next rune that
+isn't transparent (or -1 for the end of the text). The thread dies if the guess is wrong.
+*/
+type progThread struct {
+	pc uint32
+	// The guessed context of the next rune, or noContext if the thread hasn't consumed a transparent rune since
+	// the last rune.
+	next rune
+}
+
+const noContext = -2
+
+// Contexts a transparent rune can be followed by.
+var nextContexts = []rune{-1, '\n', 'a', ' '}
+
+func (s *progState) key() string {
+	var key bytes.Buffer
+	key.WriteString(strconv.Itoa(int(s.prev)))
+	for _, thread := range s.threads {
+		key.WriteByte(',')
+		key.WriteString(strconv.Itoa(int(thread.pc)))
+		if thread.next != noContext {
+			key.WriteByte('/')
+			key.WriteString(strconv.Itoa(int(thread.next)))
+		}
+	}
+	return key.String()
+}
+
+func (s *progState) accept() bool {
+	_, match := s.closure(-1)
+	return match
+}
+
+func (s *progState) boundaries() []rune {
+	// Empty-width assertions distinguish newlines and word runes.
+	result := []rune{'\n', '\n' + 1, '0', '9' + 1, 'A', 'Z' + 1, '_', '_' + 1, 'a', 'z' + 1,
+		s.transparent.lo, s.transparent.hi + 1}
+	for _, next := range nextContexts[1:] {
+		pcs, _ := s.closure(next)
+		for _, pc := range pcs {
+			inst := &s.prog.Inst[pc]
+			switch inst.Op {
+			case syntax.InstRune1:
+				result = append(result, inst.Rune[0], inst.Rune[0]+1)
+			case syntax.InstRune:
+				if len(inst.Rune) == 1 {
+					// A single folded rune.
+					r := inst.Rune[0]
+					for folded := unicode.SimpleFold(r); ; folded = unicode.SimpleFold(folded) {
+						result = append(result, folded, folded+1)
+						if folded == r {
+							break
+						}
+					}
+					continue
+				}
+				for i := 0; i+1 < len(inst.Rune); i += 2 {
+					result = append(result, inst.Rune[i], inst.Rune[i+1]+1)
+				}
+			}
+		}
+	}
+	return result
+}
+
+func (s *progState) next(r rune) automatonState {
+	var next []progThread
+	prev := s.prev
+	if r >= s.transparent.lo && r <= s.transparent.hi {
+		for _, context := range nextContexts {
+			next = s.step(next, r, context, context)
+		}
+	} else {
+		prev = contextRune(r)
+		next = s.step(next, r, prev, noContext)
+	}
+	if len(next) == 0 {
+		return nil
+	}
+
+	sort.Slice(next, func(i, j int) bool {
+		if next[i].pc != next[j].pc {
+			return next[i].pc < next[j].pc
+		}
+		return next[i].next < next[j].next
+	})
+	unique := next[:1]
+	for _, thread := range next[1:] {
+		if thread != unique[len(unique)-1] {
+			unique = append(unique, thread)
+		}
+	}
+	return &progState{s.progAutomaton, unique, prev}
+}
+
+// step appends the threads that consume r when the next rune that isn't transparent has the given context, and
+// guess as their guess for the context of the next rune.
+func (s *progState) step(threads []progThread, r, context, guess rune) []progThread {
+	pcs, _ := s.closure(context)
+	for _, pc := range pcs {
+		inst := &s.prog.Inst[pc]
+		var matches bool
+		switch inst.Op {
+		case syntax.InstRuneAny:
+			matches = true
+		case syntax.InstRuneAnyNotNL:
+			matches = r != '\n'
+		default:
+			matches = inst.MatchRune(r)
+		}
+		if matches {
+			threads = append(threads, progThread{inst.Out, guess})
+		}
+	}
+	return threads
+}
+
+// closure returns the rune-consuming instructions reachable from the threads of s when the next rune that isn't
+// transparent has the same context as next (-1 for the end of the text), and whether the threads can match.
+// Threads that guessed a different context are ignored.
+func (s *progState) closure(next rune) (pcs []uint32, match bool) {
+	context := syntax.EmptyOpContext(s.prev, next)
+	visited := make(map[uint32]bool)
+	var visit func(pc uint32)
+	visit = func(pc uint32) {
+		if visited[pc] {
+			return
+		}
+		visited[pc] = true
+
+		inst := &s.prog.Inst[pc]
+		switch inst.Op {
+		case syntax.InstAlt, syntax.InstAltMatch:
+			visit(inst.Out)
+			visit(inst.Arg)
+		case syntax.InstCapture, syntax.InstNop:
+			visit(inst.Out)
+		case syntax.InstEmptyWidth:
+			if syntax.EmptyOp(inst.Arg)&^context == 0 {
+				visit(inst.Out)
+			}
+		case syntax.InstMatch:
+			match = true
+		case syntax.InstRune, syntax.InstRune1, syntax.InstRuneAny, syntax.InstRuneAnyNotNL:
+			pcs = append(pcs, pc)
+		}
+	}
+	for _, thread := range s.threads {
+		if thread.next == noContext || thread.next == next {
+			visit(thread.pc)
+		}
+	}
+	return pcs, match
+}
+
+// contextRune returns a rune that empty-width assertions treat the same as r.
+func contextRune(r rune) rune {
+	switch {
+	case r == '\n':
+		return '\n'
+	case syntax.IsWordChar(r):
+		return 'a'
+	}
+	return ' '
+}
+
+// newAutomatonEngineGenerator creates a generator that uses the automaton engine, for strings that match pattern
+// (including its lookarounds) and all of args.MustMatch, and don't match any of args.MustNotMatch. pattern has
+// already been translated from args.Dialect, and regexp is pattern without its lookarounds.
+func newAutomatonEngineGenerator(regexp *syntax.Regexp, pattern string, lookarounds []lookaround, args *GeneratorArgs) (*internalGenerator, error) {
+	if args.OutputLengthUnit != Runes {
+		return nil, newError(ErrInvalidArgs, nil, "the automaton engine only supports measuring output length in Runes, not %s",
+			args.OutputLengthUnit)
+	}
+	if err := checkAutomatonArgs(args); err != nil {
+		return nil, err
+	}
+
+	// The generator for the pattern without its lookarounds is used to analyze the pattern.
+	gen, err := newGenerator(regexp, args)
+	if err != nil {
+		return nil, err
+	}
+
+	var main *dfa
+	if len(lookarounds) > 0 {
+		main, err = lookaroundDFA(pattern, lookarounds, args)
+	} else {
+		main, err = compileDFA(regexp, runeInterval{}, args.alphabet)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(main.states) == 0 {
+		return nil, newError(ErrUnsatisfiable, nil, "/%s/ doesn't match any strings", pattern)
+	}
+	start := &intersectionState{automata: []*dfa{main}, negated: []bool{false}, states: []int{0}}
+
+	constraints := []struct {
+		patterns []string
+		negated  bool
+		field    string
+	}{
+		{args.MustMatch, false, "MustMatch"},
+		{args.MustNotMatch, true, "MustNotMatch"},
+	}
+	for _, c := range constraints {
+		for _, constraint := range c.patterns {
+			automaton, err := constraintDFA(constraint, args)
+			if err != nil {
+				return nil, constraintError(c.field, constraint, err)
+			}
+			state := 0
+			if len(automaton.states) == 0 {
+				// Dead from the start.
+				state = -1
+			}
+			start.automata = append(start.automata, automaton)
+			start.negated = append(start.negated, c.negated)
+			start.states = append(start.states, state)
+		}
+	}
+
+	product, err := explore(start, args.alphabet)
+	if err != nil {
+		return nil, err
+	}
+	if len(product.states) == 0 {
+		return nil, newError(ErrUnsatisfiable, nil,
+			"no strings match /%s/ and all of MustMatch, and none of MustNotMatch", pattern)
+	}
+
+	if err := newAutomatonGenerator(gen, product, args); err != nil {
+		return nil, err
+	}
+	if len(lookarounds) > 0 {
+		gen.Name = pattern
+	}
+	return gen, nil
+}
+
+// checkAutomatonArgs returns an ErrUnsupportedOp error if args has settings that apply to individual expressions,
+// which the automaton engine can't honour. The engine is also used for lookarounds, MustMatch and MustNotMatch.
+func checkAutomatonArgs(args *GeneratorArgs) error {
+	const message = "%s isn't supported by the automaton engine, which generates patterns with lookarounds, " +
+		"MustMatch or MustNotMatch"
+	if name := unsupportedAutomatonArg(args); name != "" {
+		return newError(ErrUnsupportedOp, nil, message, name)
+	}
+	return nil
+}
+
+// unsupportedAutomatonArg returns the name of an arg the automaton engine doesn't support, or "" if there's none.
+func unsupportedAutomatonArg(args *GeneratorArgs) string {
+	if args.Universe != nil {
+		return "Universe"
+	}
+	if args.RepeatDistribution != UniformRepeats {
+		return "RepeatDistribution"
+	}
+	for name, group := range args.Groups {
+		if group != (GroupArgs{}) {
+			return "GroupArgs for group " + strconv.Quote(name)
+		}
+	}
+	return ""
+}
+
+// patternDFA returns a dfa that matches the strings that match pattern and satisfy its lookarounds.
+func patternDFA(pattern string, lookarounds []lookaround, args *GeneratorArgs) (*dfa, error) {
+	if len(lookarounds) > 0 {
+		return lookaroundDFA(pattern, lookarounds, args)
+	}
+	regexp, err := syntax.Parse(pattern, args.Flags)
+	if err != nil {
+		return nil, parseError(err)
+	}
+	return compileDFA(regexp, runeInterval{}, args.alphabet)
+}
+
+// Maximum number of entries in the table of string counts used to generate strings from an automaton. This limits
+// the maximum length of generated strings for automata with many states.
+const maxAutomatonTableSize = 1 << 22
+
+// newAutomatonGenerator replaces the GenerateFunc of gen with one that generates strings from automaton.
+// Lengths are chosen uniformly from the lengths automaton can generate between args.MinOutputLength and
+// args.MaxOutputLength. If MaxOutputLength is 0, they're limited to the maximum length of gen, and to
+// MaxUnboundedRepeatCount runes more than its minimum length if it's infinite. Returns an error if MaxOutputLength
+// is longer than the table of string counts can be.
+func newAutomatonGenerator(gen *internalGenerator, automaton *dfa, args *GeneratorArgs) error {
+	lo, hi := args.MinOutputLength, args.MaxOutputLength
+	limit := maxAutomatonTableSize/len(automaton.states) - 1
+	if hi > limit {
+		return newError(ErrInvalidArgs, nil,
+			"MaxOutputLength(%d) is too long for the automaton engine: /%s/ can generate at most %d runes", hi, gen, limit)
+	}
+	if hi == 0 {
+		hi = gen.MaxLength(Runes)
+		if unbounded := gen.MinLength(Runes) + int(args.MaxUnboundedRepeatCount); !gen.IsFinite() && hi > unbounded {
+			hi = unbounded
+		}
+		if hi > limit {
+			hi = limit
+		}
+	}
+	if lo > hi {
+		return newError(ErrInvalidArgs, nil, "MinOutputLength(%d) > MaxOutputLength(%d)", lo, hi)
+	}
+
+	sampler := newDFASampler(automaton)
+	var lengths []int
+	for n := lo; n <= hi; n++ {
+		if sampler.canGenerate(n) {
+			lengths = append(lengths, n)
+		}
+	}
+	if len(lengths) == 0 {
+		return newError(ErrUnsatisfiable, nil, "/%s/ cannot generate strings with lengths between %d and %d", gen, lo, hi)
+	}
+
+	gen.automaton = sampler
+	gen.automatonLengths = lengths
+	gen.GenerateFunc = func(args *GeneratorArgs) string {
+		return sampler.generate(args.rng, lengths[args.rng.Intn(len(lengths))])
+	}
+	return nil
+}
+
+// automatonEntropy returns the entropy of the strings gen generates from its automaton. A length is chosen
+// uniformly, and then a string of that length uniformly, so the most likely strings have the least common length.
+func (gen *internalGenerator) automatonEntropy() Entropy {
+	lengths := gen.automatonLengths
+	logCounts := gen.automaton.extend(lengths[len(lengths)-1])
+
+	choice := math.Log2(float64(len(lengths)))
+	entropy := Entropy{choice, math.Inf(1)}
+	for _, n := range lengths {
+		bits := logCounts[n][0] / math.Ln2
+		entropy.Shannon += bits / float64(len(lengths))
+		entropy.Min = math.Min(entropy.Min, choice+bits)
+	}
+	return entropy
+}
+
+// automatonLength returns the length of the shortest or longest string gen generates from its automaton.
+func (gen *internalGenerator) automatonLength(unit LengthUnit, longest bool) int {
+	lengths := gen.automatonLengths
+	if unit == Runes {
+		if longest {
+			return lengths[len(lengths)-1]
+		}
+		return lengths[0]
+	}
+
+	// Walk the automaton forwards, tracking the fewest or most bytes that reach each state after n runes. Runes in
+	// a transition are encoded in more bytes the larger they are.
+	states := gen.automaton.dfa.states
+	better := func(a, b int) bool {
+		return b < 0 || (longest && a > b) || (!longest && a < b)
+	}
+	reached := make([]int, len(states))
+	for i := range reached {
+		reached[i] = -1
+	}
+	reached[0] = 0
+
+	result := -1
+	for n, i := 0, 0; i < len(lengths); n++ {
+		if n == lengths[i] {
+			for state, bytes := range reached {
+				if bytes >= 0 && states[state].accept && better(bytes, result) {
+					result = bytes
+				}
+			}
+			i++
+		}
+
+		next := make([]int, len(states))
+		for j := range next {
+			next[j] = -1
+		}
+		for state, bytes := range reached {
+			if bytes < 0 {
+				continue
+			}
+			for _, t := range states[state].transitions {
+				r := t.lo
+				if longest {
+					r = t.hi
+				}
+				if length := bytes + encodedRuneLen(r); better(length, next[t.to]) {
+					next[t.to] = length
+				}
+			}
+		}
+		reached = next
+	}
+	return result
+}
+
+// accepts returns true if value satisfies the constraints of gen that aren't represented by its sub-generators
+// (e.g. lookarounds, output length limits, or the regexp passed to NewGeneratorFromRegexp).
+func (gen *internalGenerator) accepts(value string) bool {
+	return (gen.automaton == nil || gen.automaton.dfa.matches(value)) && gen.args.fitsOutputLength(value) &&
+		gen.matchesSource(value)
+}
+
+/*
+dfaSampler generates random strings accepted by a dfa. Strings of a given length are chosen uniformly, using the
+number of accepted strings of each length from each state.
+*/
+type dfaSampler struct {
+	dfa *dfa
+
+	// Locks logCounts while it's extended, so a sampler can be shared by clones.
+	lock sync.Mutex
+	// logCounts[n][s] is the natural log of the number of strings of length n accepted from state s, or -Inf.
+	// Counts grow exponentially with length, so logs are used to avoid overflowing.
+	logCounts [][]float64
+}
+
+func newDFASampler(d *dfa) *dfaSampler {
+	return &dfaSampler{dfa: d}
+}
+
+// canGenerate returns true if the dfa accepts strings of length n.
+func (s *dfaSampler) canGenerate(n int) bool {
+	if len(s.dfa.states) == 0 || n < 0 {
+		return false
+	}
+	return s.canGenerateFrom(0, n)
+}
+
+// canGenerateFrom returns true if state accepts strings of length n.
+func (s *dfaSampler) canGenerateFrom(state, n int) bool {
+	return !math.IsInf(s.extend(n)[n][state], -1)
+}
+
+// extend computes logCounts up to length n, and returns them. Rows are never changed once they're computed, so the
+// returned table can be read without locking.
+func (s *dfaSampler) extend(n int) [][]float64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for length := len(s.logCounts); length <= n; length++ {
+		counts := make([]float64, len(s.dfa.states))
+		for i, state := range s.dfa.states {
+			if length == 0 {
+				counts[i] = math.Inf(-1)
+				if state.accept {
+					counts[i] = 0
+				}
+				continue
+			}
+
+			weights := make([]float64, len(state.transitions))
+			for j, t := range state.transitions {
+				weights[j] = transitionLogWeight(t) + s.logCounts[length-1][t.to]
+			}
+			counts[i] = logSumExp(weights)
+		}
+		s.logCounts = append(s.logCounts, counts)
+	}
+	return s.logCounts
+}
+
+// generate returns a random string of length n, which canGenerate must have returned true for.
+func (s *dfaSampler) generate(rng *rand.Rand, n int) string {
+	return s.generateFrom(rng, 0, n)
+}
+
+// generateFrom returns a random string of length n accepted from state, which canGenerateFrom must have returned
+// true for.
+func (s *dfaSampler) generateFrom(rng *rand.Rand, state, n int) string {
+	logCounts := s.extend(n)
+
+	var result bytes.Buffer
+	for length := n; length > 0; length-- {
+		transitions := s.dfa.states[state].transitions
+		total := logCounts[length][state]
+
+		chosen := -1
+		u := rng.Float64()
+		for j, t := range transitions {
+			weight := transitionLogWeight(t) + logCounts[length-1][t.to]
+			if math.IsInf(weight, -1) {
+				continue
+			}
+			// Rounding errors may leave u slightly positive after the last transition, so default to it.
+			chosen = j
+			if u -= math.Exp(weight - total); u < 0 {
+				break
+			}
+		}
+
+		t := transitions[chosen]
+		result.WriteRune(t.lo + rune(rng.Int63n(int64(t.hi-t.lo)+1)))
+		state = t.to
+	}
+	return result.String()
+}
+
+func transitionLogWeight(t dfaTransition) float64 {
+	return math.Log(float64(t.hi - t.lo + 1))
+}
+
+// logSumExp returns log(sum(exp(x) for x in xs)), without overflowing.
+func logSumExp(xs []float64) float64 {
+	max := math.Inf(-1)
+	for _, x := range xs {
+		max = math.Max(max, x)
+	}
+	if math.IsInf(max, -1) {
+		return max
+	}
+
+	sum := 0.0
+	for _, x := range xs {
+		sum += math.Exp(x - max)
+	}
+	return max + math.Log(sum)
+}
@@ -0,0 +1,201 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"errors"
+	"math/rand"
+	"regexp"
+	"regexp/syntax"
+	"testing"
+	"unicode"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAutomaton(t *testing.T) {
+	t.Parallel()
+
+	compile := func(pattern string) *dfa {
+		re, err := syntax.Parse(pattern, syntax.Perl)
+		So(err, ShouldBeNil)
+		automaton, err := compileDFA(re, runeInterval{}, automatonAlphabet)
+		So(err, ShouldBeNil)
+		return automaton
+	}
+
+	Convey("compileDFA", t, func() {
+		Convey("Matches the same strings as regexp", func() {
+			patterns := []string{
+				`abc`, `a|bc|`, `[a-c]+d?`, `(?i)aBc`, `(?i)k`, `x*`, `(ab){2,3}`, `.\n?`, `(?s).`,
+				`^a$`, `(?m)^a$\n^b$`, `\bfoo\b`, `a\Bb`, `\b`, `[^a]`, `\pL\d`,
+			}
+			inputs := []string{
+				"", "a", "b", "c", "abc", "ABC", "aBc", "bc", "abd", "aabbd", "ccc", "k", "K", "K", "xxxx",
+				"abab", "ababab", "abababab", "a\n", "\n", "é", "a\nb", "foo", "ab", "é1", "1é", " ",
+			}
+			for _, pattern := range patterns {
+				automaton := compile(pattern)
+				matcher := regexp.MustCompile(`\A(?:` + pattern + `)\z`)
+				for _, input := range inputs {
+					So(automaton.matches(input), ShouldEqual, matcher.MatchString(input))
+				}
+			}
+		})
+
+		Convey("Trims patterns that don't match anything", func() {
+			So(compile(`a\bb`).states, ShouldBeEmpty)
+			So(compile(`a^b`).states, ShouldBeEmpty)
+		})
+
+		Convey("Ignores transparent runes in assertions", func() {
+			re, err := syntax.Parse(`a\x{F0000}\bb`, syntax.Perl)
+			So(err, ShouldBeNil)
+			automaton, err := compileDFA(re, runeInterval{0xf0000, 0xf0000}, automatonAlphabet)
+			So(err, ShouldBeNil)
+			So(automaton.states, ShouldBeEmpty)
+
+			re, err = syntax.Parse(`a\x{F0000}\B\b?b`, syntax.Perl)
+			So(err, ShouldBeNil)
+			automaton, err = compileDFA(re, runeInterval{0xf0000, 0xf0000}, automatonAlphabet)
+			So(err, ShouldBeNil)
+			So(automaton.matches("a\U000F0000b"), ShouldBeTrue)
+
+			// Assertions before a transparent rune depend on the rune after it.
+			re, err = syntax.Parse(`\b\x{F0000}a\b\x{F0000}\x{F0000}`, syntax.Perl)
+			So(err, ShouldBeNil)
+			automaton, err = compileDFA(re, runeInterval{0xf0000, 0xf0000}, automatonAlphabet)
+			So(err, ShouldBeNil)
+			So(automaton.matches("\U000F0000a\U000F0000\U000F0000"), ShouldBeTrue)
+
+			re, err = syntax.Parse(`a\b\x{F0000}[ab]`, syntax.Perl)
+			So(err, ShouldBeNil)
+			automaton, err = compileDFA(re, runeInterval{0xf0000, 0xf0000}, automatonAlphabet)
+			So(err, ShouldBeNil)
+			So(automaton.matches("a\U000F0000b"), ShouldBeFalse)
+			So(automaton.states, ShouldBeEmpty)
+		})
+	})
+
+	Convey("splitAlphabet", t, func() {
+		So(splitAlphabet(automatonAlphabet, []rune{'b', 'a', 0xe000, 0x10ffff + 1}), ShouldResemble, []runeInterval{
+			{1, 'a' - 1}, {'a', 'a'}, {'b', 0xd7ff}, {0xe000, 0x10ffff},
+		})
+	})
+
+	Convey("dfaSampler", t, func() {
+		rng := rand.New(rand.NewSource(1))
+
+		Convey("Generates strings of each length uniformly", func() {
+			// 4 strings of length 2: "aa", "ab", "ba", "bb", but 3 branches produce them.
+			sampler := newDFASampler(compile(`a[ab]|b[ab]|bb`))
+			So(sampler.canGenerate(1), ShouldBeFalse)
+			So(sampler.canGenerate(2), ShouldBeTrue)
+
+			counts := make(map[string]int)
+			for i := 0; i < 4000; i++ {
+				counts[sampler.generate(rng, 2)]++
+			}
+			So(counts, ShouldHaveLength, 4)
+			for _, count := range counts {
+				So(count, ShouldBeBetween, 800, 1200)
+			}
+		})
+
+		Convey("Handles long strings", func() {
+			sampler := newDFASampler(compile(`[a-z]*\d`))
+			value := sampler.generate(rng, 5000)
+			So(value, ShouldHaveLength, 5000)
+			So(regexp.MustCompile(`^[a-z]*\d$`).MatchString(value), ShouldBeTrue)
+		})
+	})
+}
+
+func TestAutomatonEngine(t *testing.T) {
+	t.Parallel()
+
+	Convey("AutomatonEngine", t, func() {
+		Convey("Respects assertions", func() {
+			gen, err := NewGenerator(`x(\b|y)z|^a$`, &GeneratorArgs{Flags: syntax.Perl, Engine: AutomatonEngine})
+			So(err, ShouldBeNil)
+			for i := 0; i < SampleSize; i++ {
+				So([]string{"xyz", "a"}, ShouldContain, gen.Generate())
+			}
+		})
+
+		Convey("Respects repeat and output length limits", func() {
+			gen, err := NewGenerator(`[ab]*`, &GeneratorArgs{Engine: AutomatonEngine, MaxUnboundedRepeatCount: 3})
+			So(err, ShouldBeNil)
+			for i := 0; i < SampleSize; i++ {
+				So(len(gen.Generate()), ShouldBeLessThanOrEqualTo, 3)
+			}
+
+			gen, err = NewGenerator(`[ab]*`, &GeneratorArgs{Engine: AutomatonEngine, MinOutputLength: 2, MaxOutputLength: 4})
+			So(err, ShouldBeNil)
+			for i := 0; i < SampleSize; i++ {
+				So(len(gen.Generate()), ShouldBeBetweenOrEqual, 2, 4)
+			}
+
+			value, err := gen.(ExtendedGenerator).GenerateLength(50)
+			So(err, ShouldBeNil)
+			So(value, ShouldHaveLength, 50)
+
+			gen, err = NewGenerator(`x(a*b*)*`, &GeneratorArgs{Engine: AutomatonEngine, MaxUnboundedRepeatCount: 10})
+			So(err, ShouldBeNil)
+			for i := 0; i < SampleSize; i++ {
+				So(len(gen.Generate()), ShouldBeLessThanOrEqualTo, 11)
+			}
+		})
+
+		Convey("Keeps the pattern for analysis", func() {
+			gen, err := NewGenerator(`a[bc]{2}`, &GeneratorArgs{Engine: AutomatonEngine})
+			So(err, ShouldBeNil)
+			tree, err := NewGenerator(`a[bc]{2}`, nil)
+			So(err, ShouldBeNil)
+			So(gen.String(), ShouldEqual, tree.String())
+			So(gen.(ExtendedGenerator).MinLength(Runes), ShouldEqual, 3)
+
+			values, err := gen.(ExtendedGenerator).GenerateUnique(4)
+			So(err, ShouldBeNil)
+			So(values, ShouldHaveLength, 4)
+		})
+
+		Convey("Returns errors", func() {
+			_, err := NewGenerator(`a`, &GeneratorArgs{Engine: Engine(42)})
+			So(errors.Is(err, ErrInvalidArgs), ShouldBeTrue)
+
+			_, err = NewGenerator(`a`, &GeneratorArgs{Engine: AutomatonEngine, OutputLengthUnit: Bytes})
+			So(errors.Is(err, ErrInvalidArgs), ShouldBeTrue)
+
+			_, err = NewGenerator(`a$b`, &GeneratorArgs{Engine: AutomatonEngine})
+			So(errors.Is(err, ErrUnsatisfiable), ShouldBeTrue)
+
+			_, err = NewGenerator(`a*`, &GeneratorArgs{Engine: AutomatonEngine, MaxOutputLength: 1 << 23})
+			So(errors.Is(err, ErrInvalidArgs), ShouldBeTrue)
+
+			for _, args := range []GeneratorArgs{
+				{Flags: syntax.Perl, Engine: AutomatonEngine, Universe: unicode.Latin},
+				{Flags: syntax.Perl, Engine: AutomatonEngine, RepeatDistribution: GeometricRepeats},
+				{Flags: syntax.Perl, Engine: AutomatonEngine, Groups: map[string]GroupArgs{"g": {MaxUnboundedRepeatCount: 2}}},
+				{Flags: syntax.Perl, Universe: unicode.Latin, MustMatch: []string{`a`}},
+			} {
+				_, err = NewGenerator(`(?P<g>a*)`, &args)
+				So(errors.Is(err, ErrUnsupportedOp), ShouldBeTrue)
+			}
+		})
+	})
+}
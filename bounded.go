@@ -0,0 +1,384 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"bytes"
+	"math/rand"
+	"regexp/syntax"
+	"unicode/utf8"
+)
+
+// Number of times a bounded generator will try to generate a string within its bounds before falling back to a
+// string it knows is within them. Strings fall outside the bounds when the walker divides the budget in a way that
+// can't be met (e.g. "(ab)*" can't generate odd lengths), or a capture group handler ignores its generator.
+const maxBoundedAttempts = 100
+
+// boundedWalker generates strings whose total length is within bounds, by dividing the length budget among
+// sub-generators as it goes.
+type boundedWalker struct {
+	unit     LengthUnit
+	min, max map[*internalGenerator]int
+}
+
+/*
+newBoundedGenerator replaces the GenerateFunc of gen with one that only generates strings whose lengths are
+between args.MinOutputLength and args.MaxOutputLength.
+Returns an error if gen can't generate any strings within the bounds.
+
+If the walker doesn't find a string within the bounds after maxBoundedAttempts, a string is generated from a length
+known to be possible, with capture groups generated from their expressions.
+*/
+func newBoundedGenerator(gen *internalGenerator, args *GeneratorArgs) error {
+	unit := args.OutputLengthUnit
+	lo, hi := args.MinOutputLength, args.MaxOutputLength
+	if hi == 0 {
+		hi = LengthOverflow
+	}
+
+	if lo > hi {
+		return newError(ErrInvalidArgs, nil, "MinOutputLength(%d) > MaxOutputLength(%d)", lo, hi)
+	}
+	minLength, maxLength := gen.minLength(unit), gen.maxLength(unit)
+	if minLength > hi {
+		return newError(ErrUnsatisfiable, gen.regexp, "/%s/ cannot generate strings shorter than %d, but MaxOutputLength is %d",
+			gen, minLength, hi)
+	}
+	if maxLength < lo {
+		return newError(ErrUnsatisfiable, gen.regexp, "/%s/ cannot generate strings longer than %d, but MinOutputLength is %d",
+			gen, maxLength, lo)
+	}
+
+	// The shortest and longest strings can always be generated, so the bounds can be met if they include either.
+	// Otherwise hi is less than the maximum length, so the set of lengths up to hi is cheap enough to solve.
+	var fallback func(args *GeneratorArgs) string
+	switch {
+	case lo <= minLength:
+		fallback = func(args *GeneratorArgs) string {
+			return gen.generateExtreme(unit, false, args)
+		}
+	case hi >= maxLength:
+		fallback = func(args *GeneratorArgs) string {
+			return gen.generateExtreme(unit, true, args)
+		}
+	default:
+		solver := newLengthSolver(args, hi)
+		var lengths []int
+		possible := solver.of(gen)
+		for n := lo; n <= hi; n++ {
+			if possible.Bit(n) == 1 {
+				lengths = append(lengths, n)
+			}
+		}
+		if len(lengths) == 0 {
+			return newError(ErrUnsatisfiable, gen.regexp, "/%s/ cannot generate strings with lengths between %d and %d",
+				gen, lo, hi)
+		}
+		fallback = func(args *GeneratorArgs) string {
+			return solver.generate(gen, lengths[args.rng.Intn(len(lengths))], args.withoutCaptureHandlers())
+		}
+	}
+
+	walker := newBoundedWalker(gen, unit)
+	gen.lengthConstrained = true
+	gen.GenerateFunc = func(args *GeneratorArgs) string {
+		for i := 0; i < maxBoundedAttempts; i++ {
+			result := walker.generate(gen, lo, hi, args)
+			if length := measureString(result, unit); length >= lo && length <= hi {
+				return result
+			}
+		}
+		return fallback(args)
+	}
+	return nil
+}
+
+// newBoundedWalker returns a walker for gen and its sub-generators.
+func newBoundedWalker(gen *internalGenerator, unit LengthUnit) *boundedWalker {
+	walker := &boundedWalker{
+		unit: unit,
+		min:  make(map[*internalGenerator]int),
+		max:  make(map[*internalGenerator]int),
+	}
+	walker.measure(gen)
+	return walker
+}
+
+func (w *boundedWalker) measure(gen *internalGenerator) {
+	w.min[gen] = gen.minLength(w.unit)
+	w.max[gen] = gen.maxLength(w.unit)
+	for _, sub := range gen.subs {
+		w.measure(sub)
+	}
+}
+
+// generate returns a string from gen whose length is between lo and hi, if possible, using the rng and provider
+// states in args.
+func (w *boundedWalker) generate(gen *internalGenerator, lo, hi int, args *GeneratorArgs) string {
+	var result bytes.Buffer
+	w.write(&result, gen, lo, hi, args)
+	return result.String()
+}
+
+// write writes a string from gen to result, and returns its length.
+func (w *boundedWalker) write(result *bytes.Buffer, gen *internalGenerator, lo, hi int, args *GeneratorArgs) int {
+	switch {
+	case gen.charClass != nil:
+		var r rune
+		if w.unit == Bytes {
+			r = gen.charClass.randomRuneWithLength(args.rng, lo, hi)
+		} else {
+			r = gen.charClass.GetRuneAt(args.rng.Int31n(gen.charClass.TotalSize))
+		}
+		result.WriteRune(r)
+		return measureRune(r, w.unit)
+
+	case gen.regexp.Op == syntax.OpConcat:
+		return w.writeSequence(result, gen.subs, lo, hi, args)
+
+	case gen.regexp.Op == syntax.OpAlternate:
+		var candidates []*internalGenerator
+		for _, sub := range gen.subs {
+			if w.min[sub] <= hi && w.max[sub] >= lo {
+				candidates = append(candidates, sub)
+			}
+		}
+		if len(candidates) == 0 {
+			candidates = gen.subs
+		}
+		return w.write(result, candidates[args.rng.Intn(len(candidates))], lo, hi, args)
+
+	case gen.regexp.Op == syntax.OpCapture:
+		sub := gen.subs[0]
+		bounded := &boundedSubGenerator{sub.withArgs(sub.args.withStateOf(args)), w, lo, hi}
+		value := handleCapture(sub.args, gen.regexp.Cap-1, gen.regexp.Name, gen.regexp.Sub[0], bounded, args)
+		result.WriteString(value)
+		return measureString(value, w.unit)
+
+	case gen.isRepeat():
+		sub := gen.subs[0]
+		minCount, maxCount := gen.min, gen.max
+		if w.max[sub] > 0 {
+			// ceil(lo / max)
+			if count := (lo + w.max[sub] - 1) / w.max[sub]; count > minCount {
+				minCount = count
+			}
+		}
+		if w.min[sub] > 0 {
+			if count := hi / w.min[sub]; count < maxCount {
+				maxCount = count
+			}
+		}
+		if minCount > maxCount {
+			minCount, maxCount = gen.min, gen.max
+		}
+		count := gen.args.repeatCount(args.rng, minCount, maxCount)
+
+		subs := make([]*internalGenerator, count)
+		for i := range subs {
+			subs[i] = sub
+		}
+		return w.writeSequence(result, subs, lo, hi, args)
+
+	case gen.regexp.Op == syntax.OpLiteral:
+		value := runesToString(gen.regexp.Rune...)
+		result.WriteString(value)
+		return measureString(value, w.unit)
+	}
+
+	// Empty matches and assertions. gen may be the bounded generator itself, so don't call gen.Generate.
+	return 0
+}
+
+// writeSequence writes a string from each of gens in order, dividing the bounds among them.
+func (w *boundedWalker) writeSequence(result *bytes.Buffer, gens []*internalGenerator, lo, hi int, args *GeneratorArgs) int {
+	// The minimum and maximum lengths of the generators after each one.
+	minRest, maxRest := 0, 0
+	for _, gen := range gens {
+		minRest = addLengths(minRest, w.min[gen])
+		maxRest = addLengths(maxRest, w.max[gen])
+	}
+
+	length := 0
+	for _, gen := range gens {
+		minRest -= w.min[gen]
+		if maxRest != LengthOverflow {
+			maxRest -= w.max[gen]
+		}
+
+		subLo, subHi := w.min[gen], w.max[gen]
+		if maxRest != LengthOverflow && lo-length-maxRest > subLo {
+			subLo = lo - length - maxRest
+		}
+		if hi-length-minRest < subHi {
+			subHi = hi - length - minRest
+		}
+		length += w.write(result, gen, subLo, subHi, args)
+	}
+	return length
+}
+
+// generateExtreme returns one of the longest strings gen can generate if longest is true, otherwise one of the
+// shortest, measured in unit. Capture groups are generated from their expressions.
+func (gen *internalGenerator) generateExtreme(unit LengthUnit, longest bool, args *GeneratorArgs) string {
+	var result bytes.Buffer
+	gen.writeExtreme(&result, unit, longest, args)
+	return result.String()
+}
+
+func (gen *internalGenerator) writeExtreme(result *bytes.Buffer, unit LengthUnit, longest bool, args *GeneratorArgs) {
+	extreme := func(gen *internalGenerator) int {
+		if longest {
+			return gen.maxLength(unit)
+		}
+		return gen.minLength(unit)
+	}
+
+	switch {
+	case gen.charClass != nil:
+		if unit == Bytes {
+			length := extreme(gen)
+			result.WriteRune(gen.charClass.randomRuneWithLength(args.rng, length, length))
+		} else {
+			result.WriteRune(gen.charClass.GetRuneAt(args.rng.Int31n(gen.charClass.TotalSize)))
+		}
+
+	case gen.regexp.Op == syntax.OpLiteral:
+		result.WriteString(runesToString(gen.regexp.Rune...))
+
+	case gen.regexp.Op == syntax.OpConcat, gen.regexp.Op == syntax.OpCapture:
+		for _, sub := range gen.subs {
+			sub.writeExtreme(result, unit, longest, args)
+		}
+
+	case gen.regexp.Op == syntax.OpAlternate:
+		var candidates []*internalGenerator
+		for _, sub := range gen.subs {
+			if extreme(sub) == extreme(gen) {
+				candidates = append(candidates, sub)
+			}
+		}
+		candidates[args.rng.Intn(len(candidates))].writeExtreme(result, unit, longest, args)
+
+	case gen.isRepeat():
+		count := gen.min
+		if longest {
+			count = gen.max
+		}
+		for i := 0; i < count; i++ {
+			gen.subs[0].writeExtreme(result, unit, longest, args)
+		}
+	}
+}
+
+// boundedSubGenerator is passed to capture group handlers by bounded generators, so the group is generated
+// within its share of the length budget. Clones generate the group without the budget.
+type boundedSubGenerator struct {
+	*internalGenerator
+	walker *boundedWalker
+	lo, hi int
+}
+
+func (gen *boundedSubGenerator) Generate() string {
+	return gen.walker.generate(gen.internalGenerator, gen.lo, gen.hi, gen.args)
+}
+
+// randomRuneWithLength returns a random rune from the class whose UTF-8 encoding is between lo and hi bytes long.
+// If there are no such runes, returns a random rune from the entire class.
+func (class *tCharClass) randomRuneWithLength(rng *rand.Rand, lo, hi int) rune {
+	if lo > utf8.UTFMax || hi < 1 {
+		return class.GetRuneAt(rng.Int31n(class.TotalSize))
+	}
+
+	// Encoded length increases with rune value, so the runes with lengths in [lo, hi] form a single range.
+	minRune, maxRune := rune(0), rune(utf8.MaxRune)
+	if lo > 1 {
+		minRune = maxRuneWithLength[lo-2] + 1
+	}
+	if hi < utf8.UTFMax {
+		maxRune = maxRuneWithLength[hi-1]
+	}
+
+	var ranges []tCharClassRange
+	var totalSize int32
+	for _, r := range class.Ranges {
+		start, end := r.Start, r.Start+rune(r.Size-1)
+		if start < minRune {
+			start = minRune
+		}
+		if end > maxRune {
+			end = maxRune
+		}
+		if start <= end {
+			ranges = append(ranges, tCharClassRange{start, int32(end-start) + 1})
+			totalSize += int32(end-start) + 1
+		}
+	}
+
+	if totalSize == 0 {
+		return class.GetRuneAt(rng.Int31n(class.TotalSize))
+	}
+	return (&tCharClass{ranges, totalSize}).GetRuneAt(rng.Int31n(totalSize))
+}
+
+// maxRuneWithLength[n-1] is the largest rune whose UTF-8 encoding is n bytes long.
+var maxRuneWithLength = [utf8.UTFMax]rune{0x7f, 0x7ff, 0xffff, utf8.MaxRune}
+
+func measureRune(r rune, unit LengthUnit) int {
+	if unit == Bytes {
+		return encodedRuneLen(r)
+	}
+	return 1
+}
+
+func measureString(s string, unit LengthUnit) int {
+	if unit == Bytes {
+		return len(s)
+	}
+	return utf8.RuneCountInString(s)
+}
+
+// outputSlack returns how much longer than its shortest string gen can generate without exceeding MaxOutputLength,
+// or LengthOverflow if there's no maximum.
+func (gen *internalGenerator) outputSlack() int {
+	if gen.args.MaxOutputLength == 0 {
+		return LengthOverflow
+	}
+	return gen.args.MaxOutputLength - gen.minLength(gen.args.OutputLengthUnit)
+}
+
+// repeatLimit returns the most times gen, which is a repeat, can repeat without using more than slack of the
+// output length.
+func (gen *internalGenerator) repeatLimit(slack int) int {
+	if slack == LengthOverflow {
+		return gen.max
+	}
+	subLength := gen.subs[0].minLength(gen.args.OutputLengthUnit)
+	if subLength == 0 {
+		subLength = 1
+	}
+	if limit := gen.min + slack/subLength; limit < gen.max {
+		return limit
+	}
+	return gen.max
+}
+
+// fitsOutputLength returns true if value is within MinOutputLength and MaxOutputLength.
+func (a *GeneratorArgs) fitsOutputLength(value string) bool {
+	length := measureString(value, a.OutputLengthUnit)
+	return length >= a.MinOutputLength && (a.MaxOutputLength == 0 || length <= a.MaxOutputLength)
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"errors"
+	"fmt"
+	"regexp/syntax"
+	"testing"
+	"unicode/utf8"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBoundedOutputLength(t *testing.T) {
+	t.Parallel()
+
+	Convey("Bounded output length", t, func() {
+		Convey("Respects MaxOutputLength", func() {
+			args := &GeneratorArgs{Flags: syntax.Perl, MaxOutputLength: 20}
+			ConveyGeneratesStringMatching(args, `(\w+ ){1,50}`, `^(\w+ ){1,50}$`)
+			ConveyGeneratesLengthBetween(args, `(\w+ ){1,50}`, 2, 20)
+		})
+
+		Convey("Respects MinOutputLength", func() {
+			args := &GeneratorArgs{Flags: syntax.Perl, MinOutputLength: 30, MaxOutputLength: 40}
+			ConveyGeneratesLengthBetween(args, `[a-z]{1,10}(-[a-z]{1,10}){0,10}`, 30, 40)
+		})
+
+		Convey("Measures bytes", func() {
+			gen, err := NewGenerator(`[a-zé]{1,10}`, &GeneratorArgs{MaxOutputLength: 5, OutputLengthUnit: Bytes})
+			So(err, ShouldBeNil)
+			for i := 0; i < SampleSize; i++ {
+				value := gen.Generate()
+				So(len(value), ShouldBeBetweenOrEqual, 1, 5)
+				So(utf8.ValidString(value), ShouldBeTrue)
+			}
+		})
+
+		Convey("Bounds unique strings", func() {
+			gen, err := NewGenerator(`[ab]{1,6}`, &GeneratorArgs{MaxOutputLength: 2})
+			So(err, ShouldBeNil)
+			values, err := gen.(ExtendedGenerator).GenerateUnique(6)
+			So(err, ShouldBeNil)
+			So(values, ShouldHaveLength, 6)
+			for _, value := range values {
+				So(len(value), ShouldBeLessThanOrEqualTo, 2)
+			}
+		})
+
+		Convey("Bounds coverage and combinations", func() {
+			gen := newTestGenerator(`[a-z]*`, &GeneratorArgs{MaxOutputLength: 5})
+			values, report := gen.GenerateCoverage(0)
+			So(values, ShouldNotBeEmpty)
+			for _, value := range values {
+				So(len(value), ShouldBeLessThanOrEqualTo, 5)
+			}
+			So(report.Uncovered(), ShouldHaveLength, 1)
+			So(report.Uncovered()[0].Choice, ShouldEqual, fmt.Sprintf("%d repetitions", DefaultMaxUnboundedRepeatCount))
+
+			gen = newTestGenerator(`(x|yy)(c|d)*`, &GeneratorArgs{MaxOutputLength: 3})
+			values, err := gen.GenerateCombinations(2)
+			So(err, ShouldBeNil)
+			So(values, ShouldHaveLength, 2)
+			for _, value := range values {
+				So(len(value), ShouldBeLessThanOrEqualTo, 3)
+			}
+		})
+
+		Convey("Generates patterns without sub-expressions", func() {
+			ConveyGeneratesStringMatching(&GeneratorArgs{MaxOutputLength: 5}, `abc`, `^abc$`)
+			ConveyGeneratesStringMatching(&GeneratorArgs{MaxOutputLength: 5}, `[a-z]`, `^[a-z]$`)
+			ConveyGeneratesStringMatching(&GeneratorArgs{MaxOutputLength: 5}, `^`, `^$`)
+		})
+
+		Convey("Returns an error for impossible bounds", func() {
+			_, err := NewGenerator(`[a-z]{5}`, &GeneratorArgs{MaxOutputLength: 3})
+			So(err, ShouldNotBeNil)
+
+			_, err = NewGenerator(`[a-z]{1,5}`, &GeneratorArgs{MinOutputLength: 6})
+			So(err, ShouldNotBeNil)
+
+			_, err = NewGenerator(`[a-z]*`, &GeneratorArgs{MinOutputLength: 5, MaxOutputLength: 4})
+			So(err, ShouldNotBeNil)
+
+			_, err = NewGenerator(`[a-z]*`, &GeneratorArgs{MaxOutputLength: -1})
+			So(errors.Is(err, ErrInvalidArgs), ShouldBeTrue)
+
+			_, err = NewGenerator(`[a-z]*`, &GeneratorArgs{MinOutputLength: -1})
+			So(errors.Is(err, ErrInvalidArgs), ShouldBeTrue)
+		})
+
+		Convey("Returns an error for bounds between lengths the pattern can generate", func() {
+			_, err := NewGenerator(`(ab)*`, &GeneratorArgs{MinOutputLength: 3, MaxOutputLength: 3})
+			So(errors.Is(err, ErrUnsatisfiable), ShouldBeTrue)
+
+			_, err = NewGenerator(`[a-z]{5}|[0-9]{20}`, &GeneratorArgs{MinOutputLength: 6, MaxOutputLength: 10})
+			So(errors.Is(err, ErrUnsatisfiable), ShouldBeTrue)
+
+			_, err = NewGenerator(`a{5}|[0-9]{20}`, &GeneratorArgs{MinOutputLength: 6, MaxOutputLength: 10, OutputLengthUnit: Bytes})
+			So(errors.Is(err, ErrUnsatisfiable), ShouldBeTrue)
+		})
+
+		Convey("Never generates strings outside the bounds", func() {
+			ConveyGeneratesLengthBetween(&GeneratorArgs{MinOutputLength: 3, MaxOutputLength: 5}, `(ab)*`, 3, 5)
+			ConveyGeneratesLengthBetween(&GeneratorArgs{MinOutputLength: 7}, `(ab)*`, 8, DefaultMaxUnboundedRepeatCount*2)
+			ConveyGeneratesLengthBetween(&GeneratorArgs{MinOutputLength: 6, MaxOutputLength: 25}, `[a-z]{5}|[0-9]{20}`, 6, 25)
+			ConveyGeneratesLengthBetween(&GeneratorArgs{MinOutputLength: 2, MaxOutputLength: 3}, `(a|bbbb)(c|dddd)`, 2, 3)
+
+			ignoreGenerator := func(index int, name string, group *syntax.Regexp, generator Generator, args *GeneratorArgs) string {
+				return "much too long"
+			}
+			ConveyGeneratesLengthBetween(&GeneratorArgs{MaxOutputLength: 5, CaptureGroupHandler: ignoreGenerator}, `([a-z]+)`, 0, 5)
+			ConveyGeneratesLengthBetween(&GeneratorArgs{MinOutputLength: 2, MaxOutputLength: 4, CaptureGroupHandler: ignoreGenerator},
+				`([a-z]{1,9})`, 2, 4)
+			ConveyGeneratesLengthBetween(&GeneratorArgs{MinOutputLength: 19, CaptureGroupHandler: ignoreGenerator}, `([a-z]{1,20})`, 19, 20)
+		})
+	})
+}
+
+func ConveyGeneratesLengthBetween(args *GeneratorArgs, pattern string, min, max int) {
+	Convey(fmt.Sprintf("String generated from /%s/ has length between %d and %d", pattern, min, max), func() {
+		gen, err := NewGenerator(pattern, args)
+		So(err, ShouldBeNil)
+		for i := 0; i < SampleSize; i++ {
+			So(utf8.RuneCountInString(gen.Generate()), ShouldBeBetweenOrEqual, min, max)
+		}
+	})
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"container/list"
+	"regexp/syntax"
+	"strconv"
+	"sync"
+)
+
+// DefaultCacheCapacity is the initial capacity of DefaultCache.
+const DefaultCacheCapacity = 256
+
+// DefaultCache holds the generators used by Generate, MustGenerate and GenerateN. They generate from clones of the
+// cached generators, so they don't share random sequences or provider states.
+var DefaultCache = NewGeneratorCache(DefaultCacheCapacity)
+
+/*
+GeneratorCache holds the generators for the most recently used patterns, so they're only parsed and compiled once.
+It's safe for concurrent use.
+
+Cached generators are shared by everyone who gets them from the cache, so their random sequences and provider
+states are shared too. Use Clone to get a generator of your own.
+*/
+type GeneratorCache struct {
+	lock     sync.Mutex
+	capacity int
+	// Most recently used first.
+	entries *list.List
+	index   map[cacheKey]*list.Element
+	stats   CacheStats
+}
+
+// CacheStats counts the lookups in a GeneratorCache.
+type CacheStats struct {
+	// Lookups that found a generator in the cache.
+	Hits uint64
+	// Lookups that created a generator, including ones that failed.
+	Misses uint64
+	// Generators removed to make room for others.
+	Evictions uint64
+	// The number of generators in the cache.
+	Size int
+}
+
+type cacheKey struct {
+	pattern string
+	flags   syntax.Flags
+}
+
+type cacheEntry struct {
+	key       cacheKey
+	generator ExtendedGenerator
+}
+
+// NewGeneratorCache returns an empty cache that holds up to capacity generators. A capacity of 0 disables it.
+func NewGeneratorCache(capacity int) *GeneratorCache {
+	return &GeneratorCache{
+		capacity: capacity,
+		entries:  list.New(),
+		index:    make(map[cacheKey]*list.Element),
+	}
+}
+
+// Generator returns the cached generator for pattern parsed with flags, creating it with default args if it's not
+// in the cache. Errors aren't cached.
+func (c *GeneratorCache) Generator(pattern string, flags syntax.Flags) (ExtendedGenerator, error) {
+	key := cacheKey{pattern, flags}
+
+	c.lock.Lock()
+	if element, ok := c.index[key]; ok {
+		c.stats.Hits++
+		c.entries.MoveToFront(element)
+		c.lock.Unlock()
+		return element.Value.(*cacheEntry).generator, nil
+	}
+	c.stats.Misses++
+	c.lock.Unlock()
+
+	// Compile without holding the lock, so lookups of other patterns don't wait.
+	generator, err := NewGenerator(pattern, &GeneratorArgs{Flags: flags})
+	if err != nil {
+		return nil, err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if element, ok := c.index[key]; ok {
+		// Another goroutine compiled the same pattern first.
+		return element.Value.(*cacheEntry).generator, nil
+	}
+	entry := &cacheEntry{key, generator.(ExtendedGenerator)}
+	if c.capacity > 0 {
+		c.index[key] = c.entries.PushFront(entry)
+		c.evict()
+	}
+	return entry.generator, nil
+}
+
+// SetCapacity changes the number of generators the cache holds, evicting the least recently used ones if there are
+// too many. A capacity of 0 disables the cache.
+func (c *GeneratorCache) SetCapacity(capacity int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.capacity = capacity
+	c.evict()
+}
+
+// Stats returns the statistics of the cache since it was created or last cleared.
+func (c *GeneratorCache) Stats() CacheStats {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	stats := c.stats
+	stats.Size = c.entries.Len()
+	return stats
+}
+
+// Clear removes all the generators from the cache and resets its statistics.
+func (c *GeneratorCache) Clear() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.entries.Init()
+	c.index = make(map[cacheKey]*list.Element)
+	c.stats = CacheStats{}
+}
+
+// evict removes the least recently used generators until the cache is within its capacity.
+func (c *GeneratorCache) evict() {
+	for c.entries.Len() > c.capacity && c.entries.Len() > 0 {
+		entry := c.entries.Remove(c.entries.Back()).(*cacheEntry)
+		delete(c.index, entry.key)
+		c.stats.Evictions++
+	}
+}
+
+/*
+MustGenerate is like Generate, but panics if pattern can't be parsed or generated. It simplifies safe initialization
+of global variables, like regexp.MustCompile.
+*/
+func MustGenerate(pattern string) string {
+	value, err := Generate(pattern)
+	if err != nil {
+		panic("regen: Generate(" + strconv.Quote(pattern) + "): " + err.Error())
+	}
+	return value
+}
+
+// GenerateN returns n random strings that match the regular expression pattern, which is compiled once (see
+// DefaultCache). The strings aren't necessarily distinct: see ExtendedGenerator.GenerateUnique.
+func GenerateN(pattern string, n int) ([]string, error) {
+	if n < 0 {
+		return nil, newError(ErrInvalidArgs, nil, "invalid number of strings %d", n)
+	}
+	generator, err := DefaultCache.Generator(pattern, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	generator = generator.Clone(nil)
+	values := make([]string, n)
+	for i := range values {
+		values[i] = generator.Generate()
+	}
+	return values, nil
+}
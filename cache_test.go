@@ -0,0 +1,174 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGeneratorCache(t *testing.T) {
+	t.Parallel()
+
+	Convey("GeneratorCache", t, func() {
+		cache := NewGeneratorCache(2)
+
+		Convey("Returns the same generator for the same pattern and flags", func() {
+			gen1, err := cache.Generator(`a+`, 0)
+			So(err, ShouldBeNil)
+			gen2, err := cache.Generator(`a+`, 0)
+			So(err, ShouldBeNil)
+			So(gen2, ShouldEqual, gen1)
+			So(cache.Stats(), ShouldResemble, CacheStats{Hits: 1, Misses: 1, Size: 1})
+
+			gen3, err := cache.Generator(`a+`, syntax.Perl)
+			So(err, ShouldBeNil)
+			So(gen3, ShouldNotEqual, gen1)
+			So(cache.Stats(), ShouldResemble, CacheStats{Hits: 1, Misses: 2, Size: 2})
+		})
+
+		Convey("Parses patterns with the flags", func() {
+			gen, err := cache.Generator(`\d`, syntax.Perl)
+			So(err, ShouldBeNil)
+			So(regexp.MustCompile(`^[0-9]$`).MatchString(gen.Generate()), ShouldBeTrue)
+
+			_, err = cache.Generator(`\d`, 0)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Doesn't cache errors", func() {
+			_, err := cache.Generator(`(`, 0)
+			So(err, ShouldNotBeNil)
+			_, err = cache.Generator(`(`, 0)
+			So(err, ShouldNotBeNil)
+			So(cache.Stats(), ShouldResemble, CacheStats{Misses: 2})
+		})
+
+		Convey("Evicts the least recently used generator", func() {
+			genA, _ := cache.Generator(`a`, 0)
+			cache.Generator(`b`, 0)
+			cache.Generator(`a`, 0)
+			cache.Generator(`c`, 0)
+			So(cache.Stats(), ShouldResemble, CacheStats{Hits: 1, Misses: 3, Evictions: 1, Size: 2})
+
+			gen, _ := cache.Generator(`a`, 0)
+			So(gen, ShouldEqual, genA)
+			cache.Generator(`b`, 0)
+			So(cache.Stats().Misses, ShouldEqual, 4)
+		})
+
+		Convey("SetCapacity", func() {
+			cache.Generator(`a`, 0)
+			cache.Generator(`b`, 0)
+
+			Convey("Evicts generators that don't fit", func() {
+				cache.SetCapacity(1)
+				So(cache.Stats(), ShouldResemble, CacheStats{Misses: 2, Evictions: 1, Size: 1})
+				cache.Generator(`b`, 0)
+				So(cache.Stats().Hits, ShouldEqual, 1)
+			})
+
+			Convey("Disables the cache with 0", func() {
+				cache.SetCapacity(0)
+				gen1, err := cache.Generator(`a`, 0)
+				So(err, ShouldBeNil)
+				gen2, _ := cache.Generator(`a`, 0)
+				So(gen2, ShouldNotEqual, gen1)
+				So(cache.Stats(), ShouldResemble, CacheStats{Misses: 4, Evictions: 2})
+			})
+		})
+
+		Convey("Clear", func() {
+			cache.Generator(`a`, 0)
+			cache.Generator(`a`, 0)
+			cache.Clear()
+			So(cache.Stats(), ShouldResemble, CacheStats{})
+		})
+
+		Convey("Is safe for concurrent use", func() {
+			var wg sync.WaitGroup
+			patterns := []string{`a+`, `b+`, `c+`}
+			for i := 0; i < 30; i++ {
+				wg.Add(1)
+				go func(pattern string) {
+					defer wg.Done()
+					for j := 0; j < 50; j++ {
+						gen, err := cache.Generator(pattern, 0)
+						if err != nil || !regexp.MustCompile(`^`+pattern+`$`).MatchString(gen.Clone(nil).Generate()) {
+							t.Errorf("bad generator for /%s/: %v", pattern, err)
+						}
+					}
+				}(patterns[i%len(patterns)])
+			}
+			wg.Wait()
+
+			stats := cache.Stats()
+			So(stats.Hits+stats.Misses, ShouldEqual, 1500)
+			So(stats.Size, ShouldEqual, 2)
+		})
+	})
+}
+
+func TestGenerateFunctions(t *testing.T) {
+	Convey("MustGenerate", t, func() {
+		So(regexp.MustCompile(`^[a-c]{3}$`).MatchString(MustGenerate(`[a-c]{3}`)), ShouldBeTrue)
+		So(func() { MustGenerate(`(`) }, ShouldPanic)
+	})
+
+	Convey("GenerateN", t, func() {
+		values, err := GenerateN(`[a-c]{3}`, 5)
+		So(err, ShouldBeNil)
+		So(values, ShouldHaveLength, 5)
+		for _, value := range values {
+			So(regexp.MustCompile(`^[a-c]{3}$`).MatchString(value), ShouldBeTrue)
+		}
+
+		_, err = GenerateN(`[a-c]`, -1)
+		So(err.(*Error).Kind, ShouldEqual, ErrInvalidArgs)
+		_, err = GenerateN(`(`, 1)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Generate uses DefaultCache", t, func() {
+		pattern := `generate-uses-default-cache[0-9]`
+		before := DefaultCache.Stats()
+		Generate(pattern)
+		Generate(pattern)
+		after := DefaultCache.Stats()
+		So(after.Hits-before.Hits, ShouldBeGreaterThanOrEqualTo, 1)
+	})
+
+	Convey("Generate is safe for concurrent use", t, func() {
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 50; j++ {
+					if value := MustGenerate(`[a-c]{3}`); !regexp.MustCompile(`^[a-c]{3}$`).MatchString(value) {
+						t.Errorf("bad value %q", value)
+					}
+				}
+			}()
+		}
+		wg.Wait()
+	})
+}
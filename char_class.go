@@ -18,6 +18,8 @@ package regen
 
 import (
 	"fmt"
+	"strings"
+	"unicode"
 )
 
 // CharClass represents a regular expression character class as a list of ranges.
@@ -34,12 +36,15 @@ type tCharClassRange struct {
 }
 
 // NewCharClass creates a character class with a single range.
-func newCharClass(start rune, end rune) *tCharClass {
-	charRange := newCharClassRange(start, end)
+func newCharClass(start rune, end rune) (*tCharClass, error) {
+	charRange, err := newCharClassRange(start, end)
+	if err != nil {
+		return nil, err
+	}
 	return &tCharClass{
 		Ranges:    []tCharClassRange{charRange},
 		TotalSize: charRange.Size,
-	}
+	}, nil
 }
 
 /*
@@ -53,11 +58,13 @@ e.g.
 "[a0-9]" -> "aa09" -> a, 0-9
 
 "[^a-z]" -> "…" -> 0-(a-1), (z+1)-(max rune)
+
+Returns an error if a range is invalid, or if the class is empty.
 */
-func parseCharClass(runes []rune) *tCharClass {
+func parseCharClass(runes []rune) (*tCharClass, error) {
 	var totalSize int32
 	numRanges := len(runes) / 2
-	ranges := make([]tCharClassRange, numRanges, numRanges)
+	ranges := make([]tCharClassRange, 0, numRanges)
 
 	for i := 0; i < numRanges; i++ {
 		start := runes[i*2]
@@ -68,15 +75,66 @@ func parseCharClass(runes []rune) *tCharClass {
 			// doesn't make sense to generate null bytes, so all ranges must start at
 			// no less than 1.
 			start = 1
+			if end < start {
+				// The range only contained the null byte.
+				continue
+			}
 		}
 
-		r := newCharClassRange(start, end)
+		r, err := newCharClassRange(start, end)
+		if err != nil {
+			return nil, err
+		}
 
-		ranges[i] = r
+		ranges = append(ranges, r)
 		totalSize += r.Size
 	}
 
-	return &tCharClass{ranges, totalSize}
+	if totalSize == 0 {
+		return nil, newError(ErrUnsatisfiable, nil, "character class doesn't contain any characters (other than the null byte)")
+	}
+	return &tCharClass{ranges, totalSize}, nil
+}
+
+// intersect returns a character class with the runes of class that are also in table, or an error if there are
+// none.
+func (class *tCharClass) intersect(table *unicode.RangeTable) (*tCharClass, error) {
+	var tableRanges []rune
+	add := func(lo, hi, stride rune) {
+		if stride == 1 {
+			tableRanges = append(tableRanges, lo, hi)
+			return
+		}
+		for r := lo; r <= hi; r += stride {
+			tableRanges = append(tableRanges, r, r)
+		}
+	}
+	for _, r16 := range table.R16 {
+		add(rune(r16.Lo), rune(r16.Hi), rune(r16.Stride))
+	}
+	for _, r32 := range table.R32 {
+		add(rune(r32.Lo), rune(r32.Hi), rune(r32.Stride))
+	}
+
+	var runes []rune
+	i := 0
+	for _, r := range class.Ranges {
+		end := r.Start + rune(r.Size-1)
+		for ; i < len(tableRanges) && tableRanges[i] <= end; i += 2 {
+			if lo, hi := maxRune(r.Start, tableRanges[i]), minRune(end, tableRanges[i+1]); lo <= hi {
+				runes = append(runes, lo, hi)
+			}
+			if tableRanges[i+1] > end {
+				// The table range continues into the next range of the class.
+				break
+			}
+		}
+	}
+
+	if len(runes) == 0 {
+		return nil, newError(ErrUnsatisfiable, nil, "character class doesn't contain any characters in the universe")
+	}
+	return parseCharClass(runes)
 }
 
 // GetRuneAt gets a rune from CharClass as a contiguous array of runes.
@@ -94,21 +152,22 @@ func (class *tCharClass) String() string {
 	return fmt.Sprintf("%s", class.Ranges)
 }
 
-func newCharClassRange(start rune, end rune) tCharClassRange {
+func newCharClassRange(start rune, end rune) (tCharClassRange, error) {
 	if start < 1 {
-		panic("char class range cannot contain runes less than 1")
+		return tCharClassRange{}, newError(ErrInvalidArgs, nil,
+			"char class range cannot contain runes less than 1: %U-%U", start, end)
 	}
 
 	size := end - start + 1
 
 	if size < 1 {
-		panic("char class range size must be at least 1")
+		return tCharClassRange{}, newError(ErrInvalidArgs, nil, "char class range is empty: %U-%U", start, end)
 	}
 
 	return tCharClassRange{
 		Start: start,
 		Size:  size,
-	}
+	}, nil
 }
 
 func (r tCharClassRange) String() string {
@@ -118,3 +177,22 @@ func (r tCharClassRange) String() string {
 	return fmt.Sprintf("%s-%s:%d", runesToString(r.Start), runesToString(r.Start+rune(r.Size-1)), r.Size)
 
 }
+
+// pattern returns the range as it would appear in a regular expression character class, e.g. "a-z".
+func (r tCharClassRange) pattern() string {
+	end := r.Start + rune(r.Size-1)
+	if r.Size == 1 {
+		return escapeClassRune(r.Start)
+	}
+	return escapeClassRune(r.Start) + "-" + escapeClassRune(end)
+}
+
+func escapeClassRune(r rune) string {
+	if unicode.IsPrint(r) && r != ' ' && !strings.ContainsRune(`\-[]^`, r) {
+		return string(r)
+	}
+	if unicode.IsPrint(r) {
+		return `\` + string(r)
+	}
+	return fmt.Sprintf(`\x{%x}`, r)
+}
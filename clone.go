@@ -0,0 +1,95 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"math/rand"
+)
+
+// newRand returns a random number generator with an xorShift64Source seeded from seedSource, or from the default
+// source if seedSource is nil.
+func newRand(seedSource rand.Source) *rand.Rand {
+	var seed int64
+	if nil == seedSource {
+		seed = rand.Int63()
+	} else {
+		seed = seedSource.Int63()
+	}
+	rngSource := xorShift64Source(seed)
+	return rand.New(&rngSource)
+}
+
+/*
+Clone returns a generator for the same pattern and args, with its own random number generator seeded from source
+(or the default source, if source is nil) and its own provider states (see Stateful). It shares the tree of
+generators and all the tables computed for it with gen, so it's much cheaper than NewGenerator.
+
+A generator and its clones don't share any mutable state except those tables, which are locked while they're
+computed, so clones can generate in parallel. Generators passed to a CaptureGroupHandler can be cloned too, and
+their clones generate the group.
+*/
+func (gen *internalGenerator) Clone(source rand.Source) ExtendedGenerator {
+	args := *gen.args
+	args.rng = newRand(source)
+	args.state = newGeneratorState()
+	return gen.withArgs(&args)
+}
+
+// WithSeed returns a clone of gen seeded from rand.NewSource(seed). It generates the same strings as a generator
+// created with that RngSource by NewGenerator.
+func (gen *internalGenerator) WithSeed(seed int64) ExtendedGenerator {
+	return gen.Clone(rand.NewSource(seed))
+}
+
+// withArgs returns a copy of gen that generates using the rng and provider states in args, or gen itself if they're
+// already its args.
+func (gen *internalGenerator) withArgs(args *GeneratorArgs) *internalGenerator {
+	if gen.args == args {
+		return gen
+	}
+	clone := *gen
+	clone.args = args
+	clone.original = gen.node()
+	return &clone
+}
+
+// node returns the generator gen was copied from by withArgs, or gen itself, so copies can be looked up in tables
+// keyed by generator.
+func (gen *internalGenerator) node() *internalGenerator {
+	if gen.original != nil {
+		return gen.original
+	}
+	return gen
+}
+
+// withStateOf returns a copy of a with the rng and provider states of args, or a itself if it already has them.
+func (a *GeneratorArgs) withStateOf(args *GeneratorArgs) *GeneratorArgs {
+	if a.rng == args.rng && a.state == args.state {
+		return a
+	}
+	clone := *a
+	clone.rng = args.rng
+	clone.state = args.state
+	return &clone
+}
+
+// withoutCaptureHandlers returns a copy of a that generates capture groups from their expressions.
+func (a *GeneratorArgs) withoutCaptureHandlers() *GeneratorArgs {
+	clone := *a
+	clone.customCaptureGroups = false
+	return &clone
+}
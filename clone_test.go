@@ -0,0 +1,150 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"math/rand"
+	"regexp"
+	"regexp/syntax"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestClone(t *testing.T) {
+	t.Parallel()
+
+	generate := func(gen Generator, n int) []string {
+		values := make([]string, n)
+		for i := range values {
+			values[i] = gen.Generate()
+		}
+		return values
+	}
+
+	Convey("Clone", t, func() {
+		Convey("Generates the same strings as a new generator with the same seed", func() {
+			for _, args := range []*GeneratorArgs{
+				{},
+				{MaxOutputLength: 20},
+				{UniformOutputLength: true, MaxOutputLength: 20},
+				{Engine: AutomatonEngine},
+			} {
+				args.RngSource = rand.NewSource(1)
+				gen, err := NewGenerator(`[a-z]{1,30}[0-9]?`, args)
+				So(err, ShouldBeNil)
+
+				args.RngSource = rand.NewSource(42)
+				expected, err := NewGenerator(`[a-z]{1,30}[0-9]?`, args)
+				So(err, ShouldBeNil)
+
+				So(generate(gen.(ExtendedGenerator).WithSeed(42), 20), ShouldResemble, generate(expected, 20))
+			}
+		})
+
+		Convey("Is independent of the original", func() {
+			gen, err := NewGenerator(`[a-z]{10}`, &GeneratorArgs{RngSource: rand.NewSource(1)})
+			So(err, ShouldBeNil)
+			expected, err := NewGenerator(`[a-z]{10}`, &GeneratorArgs{RngSource: rand.NewSource(1)})
+			So(err, ShouldBeNil)
+
+			clone := gen.(ExtendedGenerator).Clone(nil)
+			for i := 0; i < 10; i++ {
+				clone.Generate()
+				So(gen.Generate(), ShouldEqual, expected.Generate())
+			}
+
+			value, err := clone.GenerateLength(10)
+			So(err, ShouldBeNil)
+			So(value, ShouldHaveLength, 10)
+		})
+
+		Convey("Has its own provider states", func() {
+			providers := NewProviderRegistry()
+			providers.Register("id", Counter(1))
+			gen, err := NewGenerator(`(?P<id>\d+)`, &GeneratorArgs{Flags: syntax.Perl, Providers: providers})
+			So(err, ShouldBeNil)
+
+			So(generate(gen, 3), ShouldResemble, []string{"1", "2", "3"})
+			clone := gen.(ExtendedGenerator).WithSeed(1)
+			So(generate(clone, 2), ShouldResemble, []string{"1", "2"})
+			So(generate(gen, 1), ShouldResemble, []string{"4"})
+
+			clone.ResetState()
+			So(generate(clone, 1), ShouldResemble, []string{"1"})
+			So(generate(gen, 1), ShouldResemble, []string{"5"})
+		})
+
+		Convey("Can be used concurrently", func() {
+			providers := NewProviderRegistry()
+			providers.Register("id", Counter(1))
+			for _, args := range []*GeneratorArgs{
+				{},
+				{MaxOutputLength: 12},
+				{UniformOutputLength: true, MaxOutputLength: 20},
+				{Engine: AutomatonEngine},
+				{Providers: providers},
+			} {
+				args.Flags = syntax.Perl
+				args.MaxUnboundedRepeatCount = 8
+				gen, err := NewGenerator(`[a-z]+@(?P<id>[0-9]{2})\.com`, args)
+				So(err, ShouldBeNil)
+				matcher := regexp.MustCompile(`^[a-z]+@[0-9]{2}\.com$`)
+
+				var wg sync.WaitGroup
+				results := make([][]string, 8)
+				for i := range results {
+					wg.Add(1)
+					go func(i int) {
+						defer wg.Done()
+						clone := gen.(ExtendedGenerator).WithSeed(int64(i))
+						results[i] = generate(clone, 100)
+						clone.GenerateLength(10)
+					}(i)
+				}
+				wg.Wait()
+
+				for i, values := range results {
+					So(values, ShouldResemble, generate(gen.(ExtendedGenerator).WithSeed(int64(i)), 100))
+					for _, value := range values {
+						So(matcher.MatchString(value), ShouldBeTrue)
+					}
+				}
+			}
+		})
+
+		Convey("Clones generators passed to capture group handlers", func() {
+			var group Generator
+			gen, err := NewGenerator(`x([a-z]{3})`, &GeneratorArgs{
+				CaptureGroupHandler: func(index int, name string, re *syntax.Regexp, generator Generator, args *GeneratorArgs) string {
+					group = generator
+					return generator.Generate()
+				},
+			})
+			So(err, ShouldBeNil)
+			gen.Generate()
+
+			clone := group.(ExtendedGenerator).WithSeed(1)
+			matcher := regexp.MustCompile(`^[a-z]{3}$`)
+			for _, value := range generate(clone, 10) {
+				So(matcher.MatchString(value), ShouldBeTrue)
+			}
+			So(generate(group.(ExtendedGenerator).WithSeed(1), 10), ShouldResemble, generate(clone.WithSeed(1), 10))
+		})
+	})
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Command regen generates random strings from regular expressions.
+
+Usage:
+	regen [flags] pattern
+	regen diff [flags] old new
+
+Flags:
+	-n int        number of strings to generate (default 1)
+	-perl         parse pattern as regexp.Compile does (default true; -perl=false for POSIX syntax)
+	-seed int     seed for the random number generator (default time-based)
+	-max uint     maximum number of repetitions for unbounded repeats (default 4096)
+	-entropy      print the entropy of the pattern instead of generating strings
+
+The diff subcommand prints the shortest strings matched by only one of two patterns, in each direction, or that
+the patterns are equivalent. Like diff, it exits with status 0 if the patterns are equivalent and 1 if they
+aren't. Flags:
+	-n int        maximum number of strings to print in each direction (default 5)
+	-perl         parse patterns as regexp.Compile does (default true)
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/zach-klippenstein/goregen"
+)
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "diff" {
+		diff(os.Args[2:])
+		return
+	}
+
+	count := flag.Int("n", 1, "number of strings to generate")
+	perl := flag.Bool("perl", true, "parse pattern as regexp.Compile does (-perl=false for POSIX syntax)")
+	seed := flag.Int64("seed", time.Now().UnixNano(), "seed for the random number generator")
+	maxRepeat := flag.Uint("max", regen.DefaultMaxUnboundedRepeatCount, "maximum number of repetitions for unbounded repeats")
+	entropy := flag.Bool("entropy", false, "print the entropy of the pattern instead of generating strings")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: regen [flags] pattern")
+		fmt.Fprintln(os.Stderr, "       regen diff [flags] old new")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	args := &regen.GeneratorArgs{
+		RngSource:               rand.NewSource(*seed),
+		MaxUnboundedRepeatCount: *maxRepeat,
+		Compatibility:           compatibility(*perl),
+	}
+
+	generator, err := regen.NewGenerator(flag.Arg(0), args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if *entropy {
+		bits, err := generator.(regen.ExtendedGenerator).Entropy()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Println(bits)
+		return
+	}
+
+	for i := 0; i < *count; i++ {
+		fmt.Println(generator.Generate())
+	}
+}
+
+func diff(arguments []string) {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	count := flags.Int("n", 5, "maximum number of strings to print in each direction")
+	perl := flags.Bool("perl", true, "parse patterns as regexp.Compile does (-perl=false for POSIX syntax)")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: regen diff [flags] old new")
+		flags.PrintDefaults()
+	}
+	flags.Parse(arguments)
+
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	args := &regen.GeneratorArgs{Compatibility: compatibility(*perl)}
+
+	result, err := regen.DiffPatterns(flags.Arg(0), flags.Arg(1), args, *count)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	fmt.Println(result)
+	if !result.Equivalent() {
+		os.Exit(1)
+	}
+}
+
+// compatibility returns the Compatibility selected by the -perl flag.
+func compatibility(perl bool) regen.Compatibility {
+	if perl {
+		return regen.RegexpCompatible
+	}
+	return regen.FlagsCompatible
+}
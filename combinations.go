@@ -0,0 +1,338 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"bytes"
+	"regexp/syntax"
+)
+
+/*
+GenerateCombinations returns a small set of strings that together cover every combination of strength choices
+from different choice points. Choice points are alternations (e.g. "GET|POST") and optional expressions
+(e.g. "(\?fmt=json)?") that are not inside a repetition. A strength of 2 generates pairwise combinations.
+
+Choice points nested inside another choice point are only combined with the choices that make them reachable.
+E.g. for "(yes(red|blue)|no)(up|down)", the combination (no, red) is impossible, so it isn't required, and at
+strength 3 "no" is combined with just "up" and "down".
+
+All other parts of the expression are generated randomly. Capture groups that contain choice points are generated
+from their expressions, ignoring any CaptureGroupHandler or Providers. Combinations are only covered by strings that
+satisfy the generator's lookarounds, constraints and output length limits, and combinations that no such string was
+found for are left out.
+
+Returns an error if strength is less than 1.
+*/
+func (gen *internalGenerator) GenerateCombinations(strength int) ([]string, error) {
+	if strength < 1 {
+		return nil, newError(ErrInvalidArgs, nil, "combination strength must be at least 1, was %d", strength)
+	}
+
+	c := newCombinations(gen)
+	if strength > len(c.points) {
+		strength = len(c.points)
+	}
+	c.addTuples(strength, 0, nil)
+
+	var results []string
+	for {
+		assignment, seed := c.nextAssignment()
+		if assignment == nil {
+			break
+		}
+
+		if result, ok := c.generate(gen, assignment); ok {
+			results = append(results, result)
+			c.cover(assignment)
+		} else {
+			seed.dropped = true
+		}
+	}
+
+	// An expression without choice points still generates a string.
+	if len(results) == 0 {
+		results = append(results, gen.GenerateFunc(gen.args))
+	}
+	return results, nil
+}
+
+// choicePoint is an alternation or optional expression that makes a single choice per generated string.
+type choicePoint struct {
+	gen     *internalGenerator
+	choices int
+	// The choices of enclosing points required for this point to be generated.
+	requires map[int]int
+}
+
+// choice is a single choice at a choice point.
+type choice struct {
+	point, value int
+}
+
+type combinationTuple struct {
+	choices []choice
+	covered bool
+	// True if no string that covers the tuple was accepted by the generator.
+	dropped bool
+}
+
+type combinations struct {
+	// The args of the generator the combinations are generated by.
+	args *GeneratorArgs
+	// Generates the parts of the string without choice points within their share of MaxOutputLength, if it's set.
+	bounded *boundedWalker
+	// How much longer than the shortest string the generated strings can be.
+	slack   int
+	points  []choicePoint
+	indices map[*internalGenerator]int
+	tuples  []*combinationTuple
+}
+
+func newCombinations(gen *internalGenerator) *combinations {
+	c := &combinations{args: gen.args, indices: make(map[*internalGenerator]int), slack: gen.outputSlack()}
+	c.addPoints(gen, map[int]int{})
+	if c.slack != LengthOverflow {
+		c.bounded = newBoundedWalker(gen, gen.args.OutputLengthUnit)
+	}
+	return c
+}
+
+// addPoints adds the choice points in gen in depth-first order, so enclosing points come first.
+func (c *combinations) addPoints(gen *internalGenerator, requires map[int]int) {
+	addPoint := func(choices int) int {
+		c.indices[gen] = len(c.points)
+		c.points = append(c.points, choicePoint{gen, choices, requires})
+		return len(c.points) - 1
+	}
+	requiring := func(point, value int) map[int]int {
+		nested := map[int]int{point: value}
+		for p, v := range requires {
+			nested[p] = v
+		}
+		return nested
+	}
+
+	switch gen.regexp.Op {
+	case syntax.OpAlternate:
+		point := addPoint(len(gen.subs))
+		for i, sub := range gen.subs {
+			c.addPoints(sub, requiring(point, i))
+		}
+
+	case syntax.OpQuest:
+		point := addPoint(2)
+		c.addPoints(gen.subs[0], requiring(point, 1))
+
+	case syntax.OpConcat, syntax.OpCapture:
+		for _, sub := range gen.subs {
+			c.addPoints(sub, requires)
+		}
+	}
+}
+
+// addTuples adds every feasible combination of strength choices, from points after start. Combinations of fewer
+// choices are added if no other point can be added to them, so choices that exclude nested points (e.g. "no" in
+// "(yes(red|blue)|no)") are still covered when strength is as high as the number of points.
+func (c *combinations) addTuples(strength, start int, prefix []choice) {
+	if c.merge(nil, prefix...) == nil {
+		return
+	}
+	if len(prefix) == strength || (len(prefix) > 0 && !c.canExtend(prefix)) {
+		tuple := &combinationTuple{choices: append([]choice(nil), prefix...)}
+		c.tuples = append(c.tuples, tuple)
+		return
+	}
+
+	for point := start; point < len(c.points); point++ {
+		for value := 0; value < c.points[point].choices; value++ {
+			c.addTuples(strength, point+1, append(prefix, choice{point, value}))
+		}
+	}
+}
+
+// canExtend returns true if a choice at a point that isn't in prefix is feasible with it.
+func (c *combinations) canExtend(prefix []choice) bool {
+	used := make(map[int]bool, len(prefix))
+	for _, ch := range prefix {
+		used[ch.point] = true
+	}
+	for point := range c.points {
+		if used[point] {
+			continue
+		}
+		for value := 0; value < c.points[point].choices; value++ {
+			if c.merge(nil, append(prefix, choice{point, value})...) != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// merge returns assignment with choices and the choices they require added, or nil if they conflict.
+func (c *combinations) merge(assignment map[int]int, choices ...choice) map[int]int {
+	merged := make(map[int]int, len(assignment)+len(choices))
+	for point, value := range assignment {
+		merged[point] = value
+	}
+
+	set := func(point, value int) bool {
+		if existing, ok := merged[point]; ok && existing != value {
+			return false
+		}
+		merged[point] = value
+		return true
+	}
+
+	for _, ch := range choices {
+		if !set(ch.point, ch.value) {
+			return nil
+		}
+		for point, value := range c.points[ch.point].requires {
+			if !set(point, value) {
+				return nil
+			}
+		}
+	}
+	return merged
+}
+
+// isActive returns true if point is generated under assignment.
+func (c *combinations) isActive(point int, assignment map[int]int) bool {
+	for p, v := range c.points[point].requires {
+		if value, ok := assignment[p]; !ok || value != v {
+			return false
+		}
+	}
+	return true
+}
+
+// covers returns true if tuple is generated under assignment.
+func (c *combinations) covers(tuple *combinationTuple, assignment map[int]int) bool {
+	for _, ch := range tuple.choices {
+		if value, ok := assignment[ch.point]; !ok || value != ch.value || !c.isActive(ch.point, assignment) {
+			return false
+		}
+	}
+	return true
+}
+
+// nextAssignment greedily chooses a value for every active choice point that covers as many uncovered tuples as
+// possible, starting from the first uncovered tuple, which it also returns. Returns nil when all tuples are covered
+// or dropped.
+func (c *combinations) nextAssignment() (map[int]int, *combinationTuple) {
+	var seed *combinationTuple
+	for _, tuple := range c.tuples {
+		if !tuple.covered && !tuple.dropped {
+			seed = tuple
+			break
+		}
+	}
+	if seed == nil {
+		return nil, nil
+	}
+
+	assignment := c.merge(nil, seed.choices...)
+	for point := range c.points {
+		if _, ok := assignment[point]; ok || !c.isActive(point, assignment) {
+			continue
+		}
+
+		best, bestCount := 0, -1
+		for value := 0; value < c.points[point].choices; value++ {
+			candidate := c.merge(assignment, choice{point, value})
+			count := 0
+			for _, tuple := range c.tuples {
+				if !tuple.covered && c.covers(tuple, candidate) {
+					count++
+				}
+			}
+			if count > bestCount {
+				best, bestCount = value, count
+			}
+		}
+		assignment[point] = best
+	}
+	return assignment, seed
+}
+
+// cover marks the tuples covered by assignment as covered.
+func (c *combinations) cover(assignment map[int]int) {
+	for _, tuple := range c.tuples {
+		if !tuple.covered && c.covers(tuple, assignment) {
+			tuple.covered = true
+		}
+	}
+}
+
+// generate returns a string from gen using the choices in assignment, and true if gen accepts it. The other parts of
+// the string are generated again up to maxCombinationAttempts times until it's accepted.
+func (c *combinations) generate(gen *internalGenerator, assignment map[int]int) (string, bool) {
+	for i := 0; i < maxCombinationAttempts; i++ {
+		var result bytes.Buffer
+		c.walk(&result, gen, assignment)
+		if gen.accepts(result.String()) {
+			return result.String(), true
+		}
+	}
+	return "", false
+}
+
+// Number of strings GenerateCombinations generates for an assignment of choices before dropping it.
+const maxCombinationAttempts = 100
+
+// walk writes a string to result from gen, using the choices in assignment.
+func (c *combinations) walk(result *bytes.Buffer, gen *internalGenerator, assignment map[int]int) {
+	if point, ok := c.indices[gen]; ok {
+		value := assignment[point]
+		switch gen.regexp.Op {
+		case syntax.OpAlternate:
+			c.walk(result, gen.subs[value], assignment)
+		case syntax.OpQuest:
+			if value == 1 {
+				c.walk(result, gen.subs[0], assignment)
+			}
+		}
+		return
+	}
+
+	if gen.regexp.Op == syntax.OpConcat || (gen.regexp.Op == syntax.OpCapture && c.containsPoints(gen)) {
+		for _, sub := range gen.subs {
+			c.walk(result, sub, assignment)
+		}
+		return
+	}
+	if c.bounded != nil {
+		// Let each part use all the slack, and rely on generate to try again if they use too much between them.
+		minLength := c.bounded.min[gen]
+		result.WriteString(c.bounded.generate(gen, minLength, minLength+c.slack, c.args))
+		return
+	}
+	result.WriteString(gen.GenerateFunc(c.args))
+}
+
+func (c *combinations) containsPoints(gen *internalGenerator) bool {
+	if _, ok := c.indices[gen]; ok {
+		return true
+	}
+	for _, sub := range gen.subs {
+		if c.containsPoints(sub) {
+			return true
+		}
+	}
+	return false
+}
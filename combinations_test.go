@@ -0,0 +1,131 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"math/rand"
+	"regexp"
+	"regexp/syntax"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGenerateCombinations(t *testing.T) {
+	t.Parallel()
+
+	const pattern = `(GET|POST|PUT|DELETE) /(users|orders|items)\?fmt=(json|xml|csv)`
+
+	generate := func(pattern string, strength int) []string {
+		gen, err := NewGenerator(pattern, &GeneratorArgs{
+			RngSource: rand.NewSource(0),
+			Flags:     syntax.Perl,
+		})
+		So(err, ShouldBeNil)
+
+		values, err := gen.(ExtendedGenerator).GenerateCombinations(strength)
+		So(err, ShouldBeNil)
+
+		matcher := regexp.MustCompile(`^(?:` + pattern + `)$`)
+		for _, value := range values {
+			So(matcher.MatchString(value), ShouldBeTrue)
+		}
+		return values
+	}
+
+	// pairs returns the set of pairs of submatches in values.
+	pairs := func(pattern string, values []string) map[[2]string]bool {
+		matcher := regexp.MustCompile(`^(?:` + pattern + `)$`)
+		result := make(map[[2]string]bool)
+		for _, value := range values {
+			groups := matcher.FindStringSubmatch(value)[1:]
+			for i := range groups {
+				for j := i + 1; j < len(groups); j++ {
+					result[[2]string{groups[i], groups[j]}] = true
+				}
+			}
+		}
+		return result
+	}
+
+	Convey("GenerateCombinations", t, func() {
+		Convey("Covers every pair", func() {
+			values := generate(pattern, 2)
+			So(pairs(pattern, values), ShouldHaveLength, 4*3+4*3+3*3)
+
+			// Full enumeration needs 36 strings.
+			So(len(values), ShouldBeLessThan, 20)
+		})
+
+		Convey("Covers every triple", func() {
+			values := generate(pattern, 3)
+			So(values, ShouldHaveLength, 4*3*3)
+		})
+
+		Convey("Covers optional expressions", func() {
+			values := generate(`(foo|bar)(x)?`, 2)
+			So(values, ShouldHaveLength, 4)
+		})
+
+		Convey("Only combines nested choices when they're reachable", func() {
+			const pattern = `(yes(red|blue)|no)(up|down)`
+			covered := pairs(pattern, generate(pattern, 2))
+			So(covered, ShouldContainKey, [2]string{"red", "up"})
+			So(covered, ShouldContainKey, [2]string{"red", "down"})
+			So(covered, ShouldContainKey, [2]string{"blue", "up"})
+			So(covered, ShouldContainKey, [2]string{"blue", "down"})
+			So(covered, ShouldContainKey, [2]string{"no", "up"})
+			So(covered, ShouldContainKey, [2]string{"no", "down"})
+		})
+
+		Convey("Covers every alternative when strength is at least the number of choice points", func() {
+			// The first alternation is parsed as GET|P(?:OST|UT)|DELETE, which has a nested choice point.
+			matcher := regexp.MustCompile(`^(?:` + pattern + `)$`)
+			for _, strength := range []int{4, 5, 10} {
+				values := generate(pattern, strength)
+				seen := make(map[string]bool)
+				for _, value := range values {
+					for _, group := range matcher.FindStringSubmatch(value)[1:] {
+						seen[group] = true
+					}
+				}
+				for _, alternative := range []string{"GET", "POST", "PUT", "DELETE", "users", "orders", "items", "json", "xml", "csv"} {
+					So(seen, ShouldContainKey, alternative)
+				}
+				So(values, ShouldHaveLength, 4*3*3)
+			}
+		})
+
+		Convey("Drops combinations that lookarounds reject", func() {
+			gen := newTestGenerator(`^(?!xx)(x|yy)(x|yy)$`, &GeneratorArgs{RngSource: rand.NewSource(0), Flags: syntax.Perl})
+			values, err := gen.GenerateCombinations(2)
+			So(err, ShouldBeNil)
+			So(values, ShouldHaveLength, 3)
+			So(values, ShouldNotContain, "xx")
+		})
+
+		Convey("Generates a string without choice points", func() {
+			So(generate(`[a-z]{3}`, 2), ShouldHaveLength, 1)
+		})
+
+		Convey("Fails on invalid strength", func() {
+			gen, _ := NewGenerator(pattern, &GeneratorArgs{Flags: syntax.Perl})
+			_, err := gen.(ExtendedGenerator).GenerateCombinations(0)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
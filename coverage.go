@@ -0,0 +1,312 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"bytes"
+	"fmt"
+	"regexp/syntax"
+)
+
+// CoverageGoal is a single choice made by a generator that a set of strings should exercise.
+type CoverageGoal struct {
+	// The expression that makes the choice.
+	Expr string
+	// Describes the choice, e.g. "branch 2 /bar/", "range [a-z]", or "3 repetitions".
+	Choice  string
+	Covered bool
+}
+
+func (g CoverageGoal) String() string {
+	return fmt.Sprintf("/%s/ %s", g.Expr, g.Choice)
+}
+
+// CoverageReport describes which choices of a generator were exercised by the strings returned from GenerateCoverage.
+type CoverageReport struct {
+	Goals []CoverageGoal
+}
+
+// Covered returns the number of goals that were exercised.
+func (r *CoverageReport) Covered() int {
+	count := 0
+	for _, goal := range r.Goals {
+		if goal.Covered {
+			count++
+		}
+	}
+	return count
+}
+
+// Uncovered returns the goals that were not exercised.
+func (r *CoverageReport) Uncovered() []CoverageGoal {
+	var goals []CoverageGoal
+	for _, goal := range r.Goals {
+		if !goal.Covered {
+			goals = append(goals, goal)
+		}
+	}
+	return goals
+}
+
+func (r *CoverageReport) String() string {
+	var buffer bytes.Buffer
+	fmt.Fprintf(&buffer, "covered %d/%d goals", r.Covered(), len(r.Goals))
+	for _, goal := range r.Uncovered() {
+		fmt.Fprintf(&buffer, "\n  not covered: %s", goal)
+	}
+	return buffer.String()
+}
+
+/*
+GenerateCoverage returns a small set of strings that together exercise every choice the generator can make:
+every branch of every alternation, every range of every character class, and the minimum and maximum count of
+every repetition (including both the empty and non-empty choices of "?").
+
+Strings are generated greedily, each one exercising as many uncovered choices as possible. If maxStrings is
+greater than 0, at most that many strings are generated and the report will list the choices that weren't
+exercised.
+
+Choices are only covered by strings that satisfy the generator's lookarounds, constraints and output length limits.
+Repeats are limited to fit MaxOutputLength, so their maximum counts may not be covered. Strings that don't,
+or that don't cover any new choices, are dropped, and the next ones are generated with some choices made randomly,
+until 100 strings in a row have been dropped. Choices that weren't covered by then are listed in the report.
+
+Capture groups are generated from their expressions, ignoring any CaptureGroupHandler or Providers.
+*/
+func (gen *internalGenerator) GenerateCoverage(maxStrings int) ([]string, *CoverageReport) {
+	walker := newCoverageWalker(gen)
+
+	var results []string
+	for misses := 0; misses < maxCoverageMisses && walker.uncovered(gen) > 0; {
+		if maxStrings > 0 && len(results) >= maxStrings {
+			break
+		}
+		var result bytes.Buffer
+		walker.randomize = misses > 0
+		walker.walk(&result, gen)
+		if gen.accepts(result.String()) && walker.commit() > 0 {
+			results = append(results, result.String())
+			misses = 0
+		} else {
+			walker.discard()
+			misses++
+		}
+	}
+
+	return results, walker.report()
+}
+
+// Number of generated strings in a row GenerateCoverage will drop before giving up on the choices it hasn't
+// covered.
+const maxCoverageMisses = 100
+
+// coverageKey identifies a single coverage goal of a generator.
+type coverageKey struct {
+	gen *internalGenerator
+	// Branch index for alternations, range index for character classes, and count for repetitions.
+	choice int
+}
+
+type coverageWalker struct {
+	args    *GeneratorArgs
+	keys    []coverageKey
+	goals   map[coverageKey]*CoverageGoal
+	choices map[*internalGenerator][]int
+	// The goals exercised by the string being generated, which are covered once it's accepted.
+	pending map[coverageKey]bool
+	// If true, choices are made randomly half the time instead of preferring uncovered ones.
+	randomize bool
+	// How much longer than the shortest string the generated strings can be, so repeats are limited to fit
+	// MaxOutputLength.
+	slack int
+}
+
+func newCoverageWalker(gen *internalGenerator) *coverageWalker {
+	walker := &coverageWalker{
+		args:    gen.args,
+		goals:   make(map[coverageKey]*CoverageGoal),
+		choices: make(map[*internalGenerator][]int),
+		pending: make(map[coverageKey]bool),
+		slack:   gen.outputSlack(),
+	}
+	walker.addGoals(gen)
+	return walker
+}
+
+func (w *coverageWalker) addGoals(gen *internalGenerator) {
+	addGoal := func(choice int, format string, args ...interface{}) {
+		key := coverageKey{gen, choice}
+		if _, ok := w.goals[key]; !ok {
+			w.keys = append(w.keys, key)
+			w.choices[gen] = append(w.choices[gen], choice)
+			w.goals[key] = &CoverageGoal{Expr: gen.String(), Choice: fmt.Sprintf(format, args...)}
+		}
+	}
+
+	switch {
+	case gen.charClass != nil:
+		for i, r := range gen.charClass.Ranges {
+			addGoal(i, "range [%s]", r.pattern())
+		}
+
+	case gen.regexp.Op == syntax.OpAlternate:
+		for i, sub := range gen.subs {
+			addGoal(i, "branch %d /%s/", i, sub)
+		}
+
+	case gen.regexp.Op == syntax.OpQuest:
+		addGoal(0, "empty")
+		addGoal(1, "non-empty")
+
+	case gen.isRepeat():
+		addGoal(gen.min, "%d repetitions", gen.min)
+		addGoal(gen.max, "%d repetitions", gen.max)
+	}
+
+	for _, sub := range gen.subs {
+		w.addGoals(sub)
+	}
+}
+
+// uncovered returns the number of uncovered goals in gen and its sub-generators.
+func (w *coverageWalker) uncovered(gen *internalGenerator) int {
+	count := 0
+	for _, choice := range w.choices[gen] {
+		if !w.isCovered(gen, choice) {
+			count++
+		}
+	}
+	for _, sub := range gen.subs {
+		count += w.uncovered(sub)
+	}
+	return count
+}
+
+// isCovered returns true if choice has been exercised, including by the string being generated, or isn't a goal.
+func (w *coverageWalker) isCovered(gen *internalGenerator, choice int) bool {
+	key := coverageKey{gen, choice}
+	goal, ok := w.goals[key]
+	return !ok || goal.Covered || w.pending[key]
+}
+
+// cover records that the string being generated exercises choice, if it's an uncovered goal.
+func (w *coverageWalker) cover(gen *internalGenerator, choice int) {
+	key := coverageKey{gen, choice}
+	if goal, ok := w.goals[key]; ok && !goal.Covered {
+		w.pending[key] = true
+	}
+}
+
+// commit covers the new goals exercised by the string that was just generated, and returns the number of them.
+func (w *coverageWalker) commit() int {
+	count := len(w.pending)
+	for key := range w.pending {
+		w.goals[key].Covered = true
+	}
+	w.discard()
+	return count
+}
+
+// discard forgets the goals exercised by the string that was just generated.
+func (w *coverageWalker) discard() {
+	w.pending = make(map[coverageKey]bool)
+}
+
+// random returns true if the walker should make a random choice instead of preferring uncovered ones.
+func (w *coverageWalker) random() bool {
+	return w.randomize && w.args.rng.Intn(2) == 0
+}
+
+// walk writes a string to result from gen, preferring uncovered choices.
+func (w *coverageWalker) walk(result *bytes.Buffer, gen *internalGenerator) {
+	switch {
+	case gen.charClass != nil:
+		i := 0
+		for i < len(gen.charClass.Ranges)-1 && w.isCovered(gen, i) {
+			i++
+		}
+		if w.isCovered(gen, i) || w.random() {
+			i = w.args.rng.Intn(len(gen.charClass.Ranges))
+		}
+		w.cover(gen, i)
+
+		r := gen.charClass.Ranges[i]
+		result.WriteRune(r.Start + w.args.rng.Int31n(r.Size))
+
+	case gen.regexp.Op == syntax.OpLiteral:
+		result.WriteString(runesToString(gen.regexp.Rune...))
+
+	case gen.regexp.Op == syntax.OpConcat, gen.regexp.Op == syntax.OpCapture:
+		for _, sub := range gen.subs {
+			w.walk(result, sub)
+		}
+
+	case gen.regexp.Op == syntax.OpAlternate:
+		// Choose the branch that leads to the most uncovered goals.
+		best, bestCount := 0, -1
+		for i, sub := range gen.subs {
+			count := w.uncovered(sub)
+			if !w.isCovered(gen, i) {
+				count++
+			}
+			if count > bestCount {
+				best, bestCount = i, count
+			}
+		}
+		if w.random() {
+			best = w.args.rng.Intn(len(gen.subs))
+		}
+		w.cover(gen, best)
+		w.walk(result, gen.subs[best])
+
+	case gen.isRepeat():
+		var count int
+		switch {
+		case !w.isCovered(gen, gen.max):
+			count = gen.max
+		case !w.isCovered(gen, gen.min):
+			count = gen.min
+		case w.uncovered(gen.subs[0]) > 0 && gen.max > 0:
+			count = gen.min
+			if count == 0 {
+				count = 1
+			}
+		default:
+			count = gen.min
+		}
+		limit := gen.repeatLimit(w.slack)
+		if count > limit {
+			count = limit
+		}
+		if w.random() {
+			count = gen.min + w.args.rng.Intn(limit-gen.min+1)
+		}
+		w.cover(gen, count)
+
+		for i := 0; i < count; i++ {
+			w.walk(result, gen.subs[0])
+		}
+	}
+}
+
+func (w *coverageWalker) report() *CoverageReport {
+	report := &CoverageReport{Goals: make([]CoverageGoal, len(w.keys))}
+	for i, key := range w.keys {
+		report.Goals[i] = *w.goals[key]
+	}
+	return report
+}
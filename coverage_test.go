@@ -0,0 +1,142 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"math/rand"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGenerateCoverage(t *testing.T) {
+	t.Parallel()
+
+	generateCoverage := func(pattern string, maxStrings int) ([]string, *CoverageReport) {
+		gen, err := NewGenerator(pattern, &GeneratorArgs{
+			RngSource:               rand.NewSource(0),
+			MaxUnboundedRepeatCount: 5,
+		})
+		So(err, ShouldBeNil)
+
+		values, report := gen.(ExtendedGenerator).GenerateCoverage(maxStrings)
+		matcher := regexp.MustCompile(`^(?:` + pattern + `)$`)
+		for _, value := range values {
+			So(matcher.MatchString(value), ShouldBeTrue)
+		}
+		return values, report
+	}
+
+	Convey("GenerateCoverage", t, func() {
+		Convey("Covers every branch", func() {
+			values, report := generateCoverage(`GET|POST|PUT|DELETE|PATCH`, 0)
+			So(values, ShouldHaveLength, 5)
+			So(report.Uncovered(), ShouldBeEmpty)
+		})
+
+		Convey("Covers every range", func() {
+			values, report := generateCoverage(`[a-cx0-9]`, 0)
+			So(values, ShouldHaveLength, 3)
+			So(report.Uncovered(), ShouldBeEmpty)
+
+			var matched [3]bool
+			for _, value := range values {
+				switch {
+				case value >= "a" && value <= "c":
+					matched[0] = true
+				case value == "x":
+					matched[1] = true
+				case value >= "0" && value <= "9":
+					matched[2] = true
+				}
+			}
+			So(matched, ShouldResemble, [3]bool{true, true, true})
+		})
+
+		Convey("Covers repeat bounds", func() {
+			// Simplifies to aa(a(a)?)?, so every count is covered.
+			values, report := generateCoverage(`a{2,4}`, 0)
+			So(values, ShouldResemble, []string{"aaaa", "aa", "aaa"})
+			So(report.Uncovered(), ShouldBeEmpty)
+
+			values, _ = generateCoverage(`a?`, 0)
+			So(values, ShouldResemble, []string{"a", ""})
+
+			values, _ = generateCoverage(`a*`, 0)
+			So(values, ShouldResemble, []string{"aaaaa", ""})
+		})
+
+		Convey("Covers combinations in few strings", func() {
+			values, report := generateCoverage(`(a|b|c)-(x|y)(z)?`, 0)
+			So(len(values), ShouldBeLessThanOrEqualTo, 3)
+			So(report.Uncovered(), ShouldBeEmpty)
+		})
+
+		Convey("Only covers goals with strings that satisfy lookarounds", func() {
+			// checkReport checks that the report says a range of \w is covered exactly when one of values uses it.
+			checkReport := func(values []string, report *CoverageReport) {
+				for _, goal := range report.Goals {
+					if !strings.HasPrefix(goal.Choice, "range ") {
+						continue
+					}
+					class := regexp.MustCompile(strings.TrimPrefix(goal.Choice, "range "))
+					used := false
+					for _, value := range values {
+						used = used || class.MatchString(value)
+					}
+					So(goal.Covered, ShouldEqual, used)
+				}
+			}
+			generate := func(pattern string) ([]string, *CoverageReport) {
+				values, report := newTestGenerator(pattern, &GeneratorArgs{
+					RngSource: rand.NewSource(0),
+					Flags:     syntax.Perl,
+				}).GenerateCoverage(0)
+				checkReport(values, report)
+				return values, report
+			}
+
+			values, report := generate(`^(?=.*\d)(?=.*[A-Z])\w{8}$`)
+			So(values, ShouldNotBeEmpty)
+			for _, value := range values {
+				So(value, ShouldHaveLength, 8)
+				So(regexp.MustCompile(`\d`).MatchString(value), ShouldBeTrue)
+				So(regexp.MustCompile(`[A-Z]`).MatchString(value), ShouldBeTrue)
+			}
+			So(report.Uncovered(), ShouldBeEmpty)
+
+			values, report = generate(`^(?!.*_)\w{2}$`)
+			So(values, ShouldNotBeEmpty)
+			// \w{2} is simplified to \w\w, so each \w has a [_] range.
+			So(report.Uncovered(), ShouldHaveLength, 2)
+			for _, goal := range report.Uncovered() {
+				So(goal.Choice, ShouldEqual, "range [_]")
+			}
+		})
+
+		Convey("Reports uncovered goals", func() {
+			values, report := generateCoverage(`red|green|blue`, 2)
+			So(values, ShouldHaveLength, 2)
+			So(report.Covered(), ShouldEqual, 2)
+			So(report.Uncovered(), ShouldHaveLength, 1)
+			So(report.String(), ShouldEqual, "covered 2/3 goals\n  not covered: /red|green|blue/ branch 2 /blue/")
+		})
+	})
+}
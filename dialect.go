@@ -0,0 +1,663 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Dialect is the syntax patterns passed to NewGenerator are written in.
+type Dialect int
+
+const (
+	// RE2 is the syntax accepted by regexp/syntax, parsed with GeneratorArgs.Flags.
+	RE2 Dialect = iota
+	// PCRE is Perl-compatible regular expression syntax, as used by nginx and PHP.
+	PCRE
+	// JavaScript is ECMA-262 regular expression syntax (with the u flag), as used by JSON Schema.
+	JavaScript
+	// POSIXExtended is POSIX extended regular expression syntax with the GNU extensions, as used by grep -E.
+	// \< and \> are translated into lookarounds, so patterns with them are generated by AutomatonEngine.
+	POSIXExtended
+)
+
+var dialectNames = map[Dialect]string{
+	RE2:           "RE2",
+	PCRE:          "PCRE",
+	JavaScript:    "JavaScript",
+	POSIXExtended: "POSIX ERE",
+}
+
+func (d Dialect) String() string {
+	if name, ok := dialectNames[d]; ok {
+		return name
+	}
+	return fmt.Sprintf("Dialect(%d)", int(d))
+}
+
+/*
+Translate rewrites a pattern written in d into an equivalent pattern for regexp/syntax, to be parsed with
+syntax.Perl. RE2 patterns are returned unchanged.
+
+Escapes and classes Go doesn't have are expanded into classes (e.g. PCRE's \h, JavaScript's \s, which includes
+Unicode spaces), and syntax Go writes differently is rewritten (e.g. JavaScript's \u{1F600} and (?<name>...), and
+POSIX's \< and [[=a=]]). Constructs that can't be expressed as a regular language, such as backreferences,
+recursion and atomic groups, return an ErrUnsupportedOp Error with the Offset of the construct in pattern. Other
+syntax errors are left for syntax.Parse to report.
+*/
+func (d Dialect) Translate(pattern string) (string, error) {
+	result, _, err := d.translate(pattern)
+	return result, err
+}
+
+// translate is Translate, and also returns the offset of the first POSIX \< or \> in pattern, or -1.
+func (d Dialect) translate(pattern string) (string, int, error) {
+	if d == RE2 {
+		return pattern, -1, nil
+	}
+	if _, ok := dialectNames[d]; !ok {
+		return "", -1, newError(ErrInvalidArgs, nil, "invalid dialect %s", d)
+	}
+
+	t := &dialectTranslator{dialect: d, pattern: pattern, wordAnchor: -1}
+	for t.pos < len(pattern) {
+		if err := t.translateNext(); err != nil {
+			return "", -1, err
+		}
+	}
+	return t.result.String(), t.wordAnchor, nil
+}
+
+// checkWordAnchor returns an ErrUnsupportedOp error if args can't be used with the POSIX \< or \> at offset in
+// pattern. They're translated into lookarounds, so the pattern is generated by the automaton engine.
+func checkWordAnchor(pattern string, offset int, args *GeneratorArgs) error {
+	name := unsupportedAutomatonArg(args)
+	switch {
+	case name != "":
+	case args.customCaptureGroups:
+		name = "CaptureGroupHandler or Providers"
+	case args.OutputLengthUnit != Runes:
+		name = "OutputLengthUnit " + args.OutputLengthUnit.String()
+	default:
+		return nil
+	}
+	expr := pattern[offset : offset+2]
+	return &Error{
+		Kind:   ErrUnsupportedOp,
+		Expr:   expr,
+		Offset: offset,
+		Msg:    fmt.Sprintf("%s is generated by the automaton engine, which doesn't support %s", expr, name),
+	}
+}
+
+// Rune ranges of the classes translated into explicit classes, as pairs of inclusive bounds.
+var (
+	pcreHorizontalSpace = []rune{'\t', '\t', ' ', ' ', 0xa0, 0xa0, 0x1680, 0x1680, 0x180e, 0x180e, 0x2000, 0x200a,
+		0x202f, 0x202f, 0x205f, 0x205f, 0x3000, 0x3000}
+	pcreVerticalSpace = []rune{'\n', '\r', 0x85, 0x85, 0x2028, 0x2029}
+	pcreSpace         = []rune{'\t', '\r', ' ', ' '}
+	jsSpace           = []rune{'\t', '\r', ' ', ' ', 0xa0, 0xa0, 0x1680, 0x1680, 0x2000, 0x200a, 0x2028, 0x2029,
+		0x202f, 0x202f, 0x205f, 0x205f, 0x3000, 0x3000, 0xfeff, 0xfeff}
+)
+
+const (
+	pcreLinebreak   = `(?:\r\n|[\n\v\f\r\x{85}\x{2028}\x{2029}])`
+	jsDot           = `[^\n\r\x{2028}\x{2029}]`
+	jsEmptyClass    = `[^\x00-\x{10FFFF}]`
+	jsNegEmptyClass = `(?s:.)`
+)
+
+// GNU anchors, written \< \> \` and \'.
+var posixAnchors = map[rune]string{'<': `\b(?=\w)`, '>': `\b(?<=\w)`, '`': `\A`, '\'': `\z`}
+
+// Quantifiers with explicit bounds, e.g. {3}, {2,} and {,5} (POSIX only).
+var intervalPattern = regexp.MustCompile(`^\{(\d*)(,\d*)?\}`)
+
+// JavaScript property names are qualified by the property, which Go leaves out.
+var jsPropertyPrefixes = []string{"General_Category=", "gc=", "Script=", "sc=", "Script_Extensions=", "scx="}
+
+type dialectTranslator struct {
+	dialect Dialect
+	pattern string
+	// Byte offset of the next character to translate.
+	pos    int
+	result bytes.Buffer
+	// Byte offset of the first \< or \>, which are translated into lookarounds, or -1.
+	wordAnchor int
+}
+
+func (t *dialectTranslator) translateNext() error {
+	c := t.pattern[t.pos]
+	switch {
+	case c == '\\':
+		replacement, err := t.escape(false)
+		if err != nil {
+			return err
+		}
+		t.result.WriteString(replacement)
+
+	case c == '[':
+		return t.charClass()
+
+	case c == '(':
+		return t.group()
+
+	case c == '.' && t.dialect == JavaScript:
+		t.pos++
+		t.result.WriteString(jsDot)
+
+	case c == '*' || c == '+' || c == '?':
+		t.pos++
+		t.result.WriteByte(c)
+		return t.quantifierSuffix()
+
+	case c == '{':
+		match := intervalPattern.FindStringSubmatch(t.pattern[t.pos:])
+		if match == nil || (match[1] == "" && (match[2] == "" || t.dialect != POSIXExtended)) {
+			// Not a quantifier, so it's a literal.
+			t.pos++
+			t.result.WriteString(`\{`)
+			return nil
+		}
+		t.pos += len(match[0])
+		if match[1] == "" {
+			// GNU extension: {,n} is {0,n}.
+			t.result.WriteString("{0" + match[2] + "}")
+		} else {
+			t.result.WriteString(match[0])
+		}
+		return t.quantifierSuffix()
+
+	default:
+		t.pos++
+		t.result.WriteByte(c)
+	}
+	return nil
+}
+
+// quantifierSuffix checks the character after a quantifier. Possessive quantifiers (e.g. a*+) can make patterns
+// fail to match strings their greedy versions match, so they aren't supported.
+func (t *dialectTranslator) quantifierSuffix() error {
+	if t.dialect == PCRE && strings.HasPrefix(t.pattern[t.pos:], "+") {
+		return t.unsupported(t.pos-1, t.pos+1, "possessive quantifier")
+	}
+	return nil
+}
+
+// escape translates the escape sequence at t.pos and advances past it.
+func (t *dialectTranslator) escape(inClass bool) (string, error) {
+	start := t.pos
+	if start+1 >= len(t.pattern) {
+		// Leave trailing backslashes for syntax.Parse to report.
+		t.pos++
+		return `\`, nil
+	}
+	c, size := utf8.DecodeRuneInString(t.pattern[start+1:])
+	t.pos += 1 + size
+	sequence := t.pattern[start:t.pos]
+
+	if !isAlnum(c) {
+		if c >= utf8.RuneSelf {
+			// Go only allows escaping ASCII punctuation.
+			return string(c), nil
+		}
+		if c == '<' || c == '>' || c == '`' || c == '\'' {
+			if t.dialect == POSIXExtended && !inClass {
+				if (c == '<' || c == '>') && t.wordAnchor < 0 {
+					t.wordAnchor = start
+				}
+				return posixAnchors[c], nil
+			}
+			return string(c), nil
+		}
+		return sequence, nil
+	}
+
+	if c >= '1' && c <= '9' {
+		return "", t.unsupported(start, t.pos, "backreference")
+	}
+
+	switch t.dialect {
+	case PCRE:
+		return t.pcreEscape(c, start, inClass)
+	case JavaScript:
+		return t.jsEscape(c, start, inClass)
+	}
+	return t.posixEscape(c, start)
+}
+
+func (t *dialectTranslator) pcreEscape(c rune, start int, inClass bool) (string, error) {
+	switch c {
+	case 'h', 'H':
+		return formatClass(pcreHorizontalSpace, c == 'H', inClass), nil
+	case 'v', 'V':
+		return formatClass(pcreVerticalSpace, c == 'V', inClass), nil
+	case 's', 'S':
+		// Unlike Go, PCRE's \s includes \v.
+		return formatClass(pcreSpace, c == 'S', inClass), nil
+	case 'R', 'N', 'Z':
+		if inClass {
+			return "", t.unsupported(start, t.pos, "escape in character class")
+		}
+		switch c {
+		case 'R':
+			return pcreLinebreak, nil
+		case 'N':
+			if strings.HasPrefix(t.pattern[t.pos:], "{") {
+				return "", t.unsupported(start, t.pos+1, "named character")
+			}
+			return `[^\n]`, nil
+		}
+		return `(?:\n?\z)`, nil
+	case 'e':
+		return `\x1b`, nil
+	case 'c':
+		return t.controlEscape(start)
+	case 'o':
+		end := strings.IndexByte(t.pattern[t.pos:], '}')
+		if !strings.HasPrefix(t.pattern[t.pos:], "{") || end < 0 {
+			return "", t.unsupported(start, t.pos, "escape")
+		}
+		digits := t.pattern[t.pos+1 : t.pos+end]
+		t.pos += end + 1
+		code, err := strconv.ParseUint(digits, 8, 32)
+		if err != nil || code > unicode.MaxRune {
+			return "", t.unsupported(start, t.pos, "octal escape")
+		}
+		return fmt.Sprintf(`\x{%x}`, code), nil
+	case 'Q':
+		return t.quote(inClass), nil
+	case 'E':
+		// PCRE ignores \E outside of \Q...\E.
+		return "", nil
+	case 'b':
+		if inClass {
+			// Backspace.
+			return `\x08`, nil
+		}
+		return `\b`, nil
+	case 'a', 'f', 'n', 'r', 't', 'x', '0', 'd', 'D', 'w', 'W', 'B', 'A', 'z', 'p', 'P':
+		return t.pattern[start:t.pos], nil
+	case 'g', 'k':
+		return "", t.unsupported(start, t.pos, "backreference")
+	}
+	return "", t.unsupported(start, t.pos, "escape")
+}
+
+func (t *dialectTranslator) jsEscape(c rune, start int, inClass bool) (string, error) {
+	switch c {
+	case 's', 'S':
+		return formatClass(jsSpace, c == 'S', inClass), nil
+	case 'u':
+		return t.unicodeEscape(start)
+	case 'c':
+		return t.controlEscape(start)
+	case '0':
+		if t.pos < len(t.pattern) && t.pattern[t.pos] >= '0' && t.pattern[t.pos] <= '9' {
+			return "", t.unsupported(start, t.pos+1, "octal escape")
+		}
+		return `\x00`, nil
+	case 'b':
+		if inClass {
+			// Backspace.
+			return `\x08`, nil
+		}
+		return `\b`, nil
+	case 'p', 'P':
+		end := strings.IndexByte(t.pattern[t.pos:], '}')
+		if !strings.HasPrefix(t.pattern[t.pos:], "{") || end < 0 {
+			return "", t.unsupported(start, t.pos, "escape")
+		}
+		name := t.pattern[t.pos+1 : t.pos+end]
+		t.pos += end + 1
+		for _, prefix := range jsPropertyPrefixes {
+			name = strings.TrimPrefix(name, prefix)
+		}
+		return `\` + string(c) + "{" + name + "}", nil
+	case 'd', 'D', 'w', 'W', 'B', 'f', 'n', 'r', 't', 'v', 'x':
+		return t.pattern[start:t.pos], nil
+	case 'k':
+		return "", t.unsupported(start, t.pos, "backreference")
+	}
+	return "", t.unsupported(start, t.pos, "escape")
+}
+
+func (t *dialectTranslator) posixEscape(c rune, start int) (string, error) {
+	switch c {
+	case 'd', 'D', 'w', 'W', 's', 'S', 'b', 'B':
+		return t.pattern[start:t.pos], nil
+	}
+	return "", t.unsupported(start, t.pos, "escape")
+}
+
+// controlEscape translates the control character escape \cX, where X is a letter.
+func (t *dialectTranslator) controlEscape(start int) (string, error) {
+	if t.pos >= len(t.pattern) || !isASCIILetter(rune(t.pattern[t.pos])) {
+		return "", t.unsupported(start, t.pos, "escape")
+	}
+	code := unicode.ToUpper(rune(t.pattern[t.pos])) ^ 0x40
+	t.pos++
+	return fmt.Sprintf(`\x%02x`, code), nil
+}
+
+// unicodeEscape translates the JavaScript escapes \u{X...} and \uXXXX. Surrogate pairs written as two \uXXXX
+// escapes are combined into one rune.
+func (t *dialectTranslator) unicodeEscape(start int) (string, error) {
+	code, ok := t.unicodeCodeUnit()
+	if !ok {
+		return "", t.unsupported(start, t.pos, "escape")
+	}
+	if code >= 0xd800 && code <= 0xdbff && strings.HasPrefix(t.pattern[t.pos:], `\u`) {
+		next := t.pos
+		t.pos += 2
+		low, ok := t.unicodeCodeUnit()
+		if ok && low >= 0xdc00 && low <= 0xdfff {
+			code = 0x10000 + (code-0xd800)<<10 + (low - 0xdc00)
+		} else {
+			t.pos = next
+		}
+	}
+	if code >= 0xd800 && code <= 0xdfff {
+		return "", t.unsupported(start, t.pos, "lone surrogate")
+	}
+	return fmt.Sprintf(`\x{%x}`, code), nil
+}
+
+// unicodeCodeUnit parses the hex digits of a \u escape at t.pos.
+func (t *dialectTranslator) unicodeCodeUnit() (rune, bool) {
+	rest := t.pattern[t.pos:]
+	digits := rest
+	if strings.HasPrefix(rest, "{") {
+		end := strings.IndexByte(rest, '}')
+		if end < 0 {
+			return 0, false
+		}
+		digits = rest[1:end]
+		t.pos += end + 1
+	} else {
+		if len(rest) < 4 {
+			return 0, false
+		}
+		digits = rest[:4]
+		t.pos += 4
+	}
+	code, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil || code > unicode.MaxRune {
+		return 0, false
+	}
+	return rune(code), true
+}
+
+// quote translates a PCRE \Q...\E quote, with t.pos just after the \Q.
+func (t *dialectTranslator) quote(inClass bool) string {
+	text := t.pattern[t.pos:]
+	if end := strings.Index(text, `\E`); end >= 0 {
+		text = text[:end]
+		t.pos += 2
+	}
+	t.pos += len(text)
+
+	if !inClass {
+		return regexp.QuoteMeta(text)
+	}
+	var result bytes.Buffer
+	for _, r := range text {
+		result.WriteString(escapeClassRune(r))
+	}
+	return result.String()
+}
+
+// charClass translates the character class at t.pos.
+func (t *dialectTranslator) charClass() error {
+	t.pos++
+	negated := strings.HasPrefix(t.pattern[t.pos:], "^")
+	if negated {
+		t.pos++
+	}
+
+	if t.dialect == JavaScript && strings.HasPrefix(t.pattern[t.pos:], "]") {
+		// [] matches nothing, and [^] matches anything.
+		t.pos++
+		if negated {
+			t.result.WriteString(jsNegEmptyClass)
+		} else {
+			t.result.WriteString(jsEmptyClass)
+		}
+		return nil
+	}
+
+	if negated {
+		t.result.WriteString("[^")
+	} else {
+		t.result.WriteString("[")
+	}
+	if strings.HasPrefix(t.pattern[t.pos:], "]") {
+		// A ] at the start of a class is a literal.
+		t.pos++
+		t.result.WriteString(`\]`)
+	}
+
+	for t.pos < len(t.pattern) {
+		rest := t.pattern[t.pos:]
+		switch {
+		case rest[0] == ']':
+			t.pos++
+			t.result.WriteByte(']')
+			return nil
+
+		case rest[0] == '\\' && t.dialect == POSIXExtended:
+			// Backslashes are literals in POSIX classes.
+			t.pos++
+			t.result.WriteString(`\\`)
+
+		case rest[0] == '\\':
+			replacement, err := t.escape(true)
+			if err != nil {
+				return err
+			}
+			t.result.WriteString(replacement)
+
+		case strings.HasPrefix(rest, "[:") && t.dialect != JavaScript:
+			end := strings.Index(rest, ":]")
+			if end < 0 {
+				t.pos++
+				t.result.WriteString(`\[`)
+				continue
+			}
+			name := rest[2:end]
+			if name == "<" || name == ">" {
+				return t.unsupported(t.pos, t.pos+end+2, "word boundary")
+			}
+			t.pos += end + 2
+			t.result.WriteString(rest[:end+2])
+
+		case (strings.HasPrefix(rest, "[=") || strings.HasPrefix(rest, "[.")) && t.dialect == POSIXExtended:
+			// Equivalence classes and collating symbols. Only single characters, which stand for themselves, are
+			// supported.
+			end := strings.Index(rest[2:], rest[1:2]+"]")
+			if end < 0 {
+				t.pos++
+				t.result.WriteString(`\[`)
+				continue
+			}
+			element := rest[2 : 2+end]
+			if utf8.RuneCountInString(element) != 1 {
+				return t.unsupported(t.pos, t.pos+end+4, "collating element")
+			}
+			t.pos += end + 4
+			r, _ := utf8.DecodeRuneInString(element)
+			t.result.WriteString(escapeClassRune(r))
+
+		case rest[0] == '[':
+			t.pos++
+			t.result.WriteString(`\[`)
+
+		default:
+			t.pos++
+			t.result.WriteByte(rest[0])
+		}
+	}
+	// Leave unterminated classes for syntax.Parse to report.
+	return nil
+}
+
+// group translates the start of the group at t.pos.
+func (t *dialectTranslator) group() error {
+	start := t.pos
+	rest := t.pattern[start:]
+	if t.dialect == POSIXExtended || !strings.HasPrefix(rest, "(?") {
+		if t.dialect == PCRE && strings.HasPrefix(rest, "(*") {
+			return t.unsupported(start, start+2, "backtracking control verb")
+		}
+		t.pos++
+		t.result.WriteByte('(')
+		return nil
+	}
+
+	switch {
+	case strings.HasPrefix(rest, "(?=") || strings.HasPrefix(rest, "(?!") || strings.HasPrefix(rest, "(?:"):
+		t.pos += 3
+		t.result.WriteString(rest[:3])
+	case strings.HasPrefix(rest, "(?<=") || strings.HasPrefix(rest, "(?<!"):
+		t.pos += 4
+		t.result.WriteString(rest[:4])
+	case strings.HasPrefix(rest, "(?<"):
+		t.pos += 3
+		t.result.WriteString("(?P<")
+	case strings.HasPrefix(rest, "(?P<") && t.dialect == PCRE:
+		t.pos += 4
+		t.result.WriteString("(?P<")
+	case strings.HasPrefix(rest, "(?'") && t.dialect == PCRE:
+		end := strings.IndexByte(rest[3:], '\'')
+		if end < 0 {
+			return t.unsupported(start, start+3, "group")
+		}
+		t.pos += 3 + end + 1
+		t.result.WriteString("(?P<" + rest[3:3+end] + ">")
+	case strings.HasPrefix(rest, "(?#") && t.dialect == PCRE:
+		end := strings.IndexByte(rest, ')')
+		if end < 0 {
+			return t.unsupported(start, len(t.pattern), "unterminated comment")
+		}
+		t.pos += end + 1
+	case strings.HasPrefix(rest, "(?|") && t.dialect == PCRE:
+		// Branch reset groups only change how groups are numbered.
+		t.pos += 3
+		t.result.WriteString("(?:")
+	case strings.HasPrefix(rest, "(?>"):
+		return t.unsupported(start, start+3, "atomic group")
+	case strings.HasPrefix(rest, "(?("):
+		return t.unsupported(start, start+3, "conditional")
+	case strings.HasPrefix(rest, "(?P=") || strings.HasPrefix(rest, "(?P>"):
+		return t.unsupported(start, start+4, "backreference")
+	default:
+		return t.groupFlags()
+	}
+	return nil
+}
+
+// groupFlags translates a group that sets flags, e.g. (?i) or (?s-m:...).
+func (t *dialectTranslator) groupFlags() error {
+	start := t.pos
+	supported := "ims"
+	if t.dialect == PCRE {
+		supported = "imsU"
+	}
+	for i := start + 2; i < len(t.pattern); i++ {
+		c := t.pattern[i]
+		switch {
+		case c == ':' || c == ')':
+			t.pos = i + 1
+			t.result.WriteString(t.pattern[start:t.pos])
+			return nil
+		case c == '-':
+		case isASCIILetter(rune(c)) && strings.IndexByte(supported, c) >= 0:
+		case isASCIILetter(rune(c)):
+			return t.unsupported(start, i+1, "flag")
+		default:
+			// Recursion, e.g. (?R), (?1) and (?&name), and other syntax.
+			return t.unsupported(start, i+1, "group")
+		}
+	}
+	return t.unsupported(start, len(t.pattern), "group")
+}
+
+func (t *dialectTranslator) unsupported(start, end int, construct string) *Error {
+	expr := t.pattern[start:end]
+	return &Error{
+		Kind:   ErrUnsupportedOp,
+		Expr:   expr,
+		Offset: start,
+		Msg:    fmt.Sprintf("cannot translate %s pattern: %s not supported: %s", t.dialect, construct, expr),
+	}
+}
+
+// formatClass formats a class from pairs of inclusive bounds. If inClass is true, it returns the ranges to be
+// written inside another class.
+func formatClass(ranges []rune, negated, inClass bool) string {
+	if negated && inClass {
+		ranges = complementRanges(ranges)
+		negated = false
+	}
+
+	var result bytes.Buffer
+	if negated {
+		result.WriteString("[^")
+	} else if !inClass {
+		result.WriteString("[")
+	}
+	for i := 0; i < len(ranges); i += 2 {
+		result.WriteString(escapeClassRune(ranges[i]))
+		if ranges[i+1] != ranges[i] {
+			result.WriteString("-" + escapeClassRune(ranges[i+1]))
+		}
+	}
+	if !inClass {
+		result.WriteString("]")
+	}
+	return result.String()
+}
+
+// complementRanges returns the ranges of the runes not in ranges, which must be sorted.
+func complementRanges(ranges []rune) []rune {
+	var result []rune
+	next := rune(0)
+	for i := 0; i < len(ranges); i += 2 {
+		if ranges[i] > next {
+			result = append(result, next, ranges[i]-1)
+		}
+		next = ranges[i+1] + 1
+	}
+	if next <= unicode.MaxRune {
+		result = append(result, next, unicode.MaxRune)
+	}
+	return result
+}
+
+func isAlnum(r rune) bool {
+	return isASCIILetter(r) || (r >= '0' && r <= '9')
+}
+
+func isASCIILetter(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
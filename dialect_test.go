@@ -0,0 +1,199 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"errors"
+	"regexp"
+	"regexp/syntax"
+	"testing"
+	"unicode"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// dialectCase is a pattern in a dialect, and a Go pattern that matches the same strings.
+type dialectCase struct {
+	pattern string
+	matches string
+}
+
+// dialectErrorCase is a pattern in a dialect that can't be translated, and the construct that should be reported.
+type dialectErrorCase struct {
+	pattern string
+	expr    string
+	offset  int
+}
+
+var dialectConformance = map[Dialect][]dialectCase{
+	PCRE: {
+		{`\d+\h\d+`, `^\d+[\t \x{a0}\x{1680}\x{180e}\x{2000}-\x{200a}\x{202f}\x{205f}\x{3000}]\d+$`},
+		{`a\Rb`, `^a(\r\n|[\n\v\f\r\x{85}\x{2028}\x{2029}])b$`},
+		{`[\H\d]`, `^[^\t \x{a0}\x{1680}\x{180e}\x{2000}-\x{200a}\x{202f}\x{205f}\x{3000}]$`},
+		{`x\vy`, `^x[\n-\r\x{85}\x{2028}\x{2029}]y$`},
+		{`a\s\S`, `^a[\t-\r ][^\t-\r ]$`},
+		{`abc\Z`, `^abc\n?$`},
+		{`\N\e\cA`, `^.\x1b\x01$`},
+		{`\o{101}\x{42}`, `^AB$`},
+		{`\Q.*\E+`, `^\.\*+$`},
+		{`[[:alpha:][:^digit:]]{3}`, `^[[:alpha:][:^digit:]]{3}$`},
+		{`(?<year>\d{4})-(?'month'\d\d)`, `^\d{4}-\d\d$`},
+		{`(?|a|b)(?#comment)c`, `^[ab]c$`},
+		{`(?i)ab`, `^(?i)ab$`},
+		{`[]a]`, `^[\]a]$`},
+	},
+	JavaScript: {
+		{`\u{1F600}A`, `^\x{1F600}A$`},
+		{`😀`, `^\x{1F600}$`},
+		{`a\sb`, `^a[\t-\r \x{a0}\x{1680}\x{2000}-\x{200a}\x{2028}\x{2029}\x{202f}\x{205f}\x{3000}\x{feff}]b$`},
+		{`.`, `^[^\n\r\x{2028}\x{2029}]$`},
+		{`[^]`, `^(?s:.)$`},
+		{`(?<name>a)b`, `^ab$`},
+		{`[\b]\cJ\0`, `^\x08\n\x00$`},
+		{`\p{Script=Greek}\p{gc=Lu}`, `^\p{Greek}\p{Lu}$`},
+		{`[[a]`, `^[\[a]$`},
+		{`a{,2}`, `^a\{,2\}$`},
+		{`\/\d{2,3}`, `^/\d{2,3}$`},
+	},
+	POSIXExtended: {
+		{`[[:alpha:]]+[[:digit:]]`, `^[[:alpha:]]+[[:digit:]]$`},
+		{`[\d]`, `^[\\d]$`},
+		{`[[=a=][.-.]]`, `^[a-]$`},
+		{`\<a+\>`, `^a+$`},
+		{`x{,2}y{1,}`, `^x{0,2}y+$`},
+		{`\d\w\s`, `^\d\w\s$`},
+		{"\\`a\\'", `^a$`},
+		{`(a|b)*c`, `^(a|b)*c$`},
+	},
+}
+
+var dialectErrors = map[Dialect][]dialectErrorCase{
+	PCRE: {
+		{`(a)\1`, `\1`, 3},
+		{`(?<n>a)\k<n>`, `\k`, 7},
+		{`a*+`, `*+`, 1},
+		{`a{2}+`, `}+`, 3},
+		{`(?>a)`, `(?>`, 0},
+		{`(a(?1)?)`, `(?1`, 2},
+		{`(?(1)a|b)`, `(?(`, 0},
+		{`(*UTF8)a`, `(*`, 0},
+		{`(?x) a`, `(?x`, 0},
+		{`a\G`, `\G`, 1},
+		{`[\R]`, `\R`, 1},
+		{`[[:<:]]`, `[:<:]`, 1},
+	},
+	JavaScript: {
+		{`(a)\1`, `\1`, 3},
+		{`(?<n>a)\k<n>`, `\k`, 7},
+		{`\uD83D`, `\uD83D`, 0},
+		{`\01`, `\01`, 0},
+		{`\h`, `\h`, 0},
+		{`(?x:a)`, `(?x`, 0},
+		{`(?>a)`, `(?>`, 0},
+	},
+	POSIXExtended: {
+		{`(a)\1`, `\1`, 3},
+		{`[[.ch.]]`, `[.ch.]`, 1},
+		{`\h`, `\h`, 0},
+	},
+}
+
+func TestDialects(t *testing.T) {
+	t.Parallel()
+
+	Convey("Dialects", t, func() {
+		for _, dialect := range []Dialect{PCRE, JavaScript, POSIXExtended} {
+			dialect := dialect
+
+			Convey(dialect.String(), func() {
+				Convey("Generates matching strings", func() {
+					for _, test := range dialectConformance[dialect] {
+						gen, err := NewGenerator(test.pattern, &GeneratorArgs{Dialect: dialect, MaxUnboundedRepeatCount: 5})
+						So(err, ShouldBeNil)
+						matches := regexp.MustCompile(test.matches)
+						for i := 0; i < SampleSize; i++ {
+							value := gen.Generate()
+							So(matches.MatchString(value), ShouldBeTrue)
+						}
+					}
+				})
+
+				Convey("Reports unsupported constructs", func() {
+					for _, test := range dialectErrors[dialect] {
+						_, err := NewGenerator(test.pattern, &GeneratorArgs{Dialect: dialect})
+						So(errors.Is(err, ErrUnsupportedOp), ShouldBeTrue)
+						var regenErr *Error
+						So(errors.As(err, &regenErr), ShouldBeTrue)
+						So(regenErr.Expr, ShouldEqual, test.expr)
+						So(regenErr.Offset, ShouldEqual, test.offset)
+						So(err.Error(), ShouldContainSubstring, dialect.String())
+					}
+				})
+			})
+		}
+
+		Convey("RE2 patterns aren't translated", func() {
+			translated, err := RE2.Translate(`\h`)
+			So(err, ShouldBeNil)
+			So(translated, ShouldEqual, `\h`)
+
+			_, err = NewGenerator(`\d`, &GeneratorArgs{})
+			So(errors.Is(err, ErrParse), ShouldBeTrue)
+		})
+
+		Convey("Translates to Go syntax", func() {
+			translated, err := JavaScript.Translate(`(?<a>\u{41})[^\s]`)
+			So(err, ShouldBeNil)
+			So(translated, ShouldEqual, `(?P<a>\x{41})[^\x{9}-\x{d}\ \x{a0}\x{1680}\x{2000}-\x{200a}\x{2028}-\x{2029}\x{202f}\x{205f}\x{3000}\x{feff}]`)
+
+			translated, err = PCRE.Translate(`\h+\R`)
+			So(err, ShouldBeNil)
+			So(translated, ShouldEqual, `[\x{9}\ \x{a0}\x{1680}\x{180e}\x{2000}-\x{200a}\x{202f}\x{205f}\x{3000}]+`+pcreLinebreak)
+		})
+
+		Convey("Reports args the automaton engine can't use with word anchors", func() {
+			handler := func(index int, name string, group *syntax.Regexp, generator Generator, args *GeneratorArgs) string {
+				return "1"
+			}
+			_, err := NewGenerator(`x([0-9])\>`, &GeneratorArgs{Dialect: POSIXExtended, CaptureGroupHandler: handler})
+			So(errors.Is(err, ErrUnsupportedOp), ShouldBeTrue)
+			var regenErr *Error
+			So(errors.As(err, &regenErr), ShouldBeTrue)
+			So(regenErr.Expr, ShouldEqual, `\>`)
+			So(regenErr.Offset, ShouldEqual, 8)
+			So(err.Error(), ShouldContainSubstring, "CaptureGroupHandler")
+
+			_, err = NewGenerator(`\<[a-z]+`, &GeneratorArgs{Dialect: POSIXExtended, Universe: unicode.Latin})
+			So(errors.As(err, &regenErr), ShouldBeTrue)
+			So(regenErr.Expr, ShouldEqual, `\<`)
+			So(err.Error(), ShouldContainSubstring, "Universe")
+
+			_, err = NewGenerator("[\\<]\\`", &GeneratorArgs{Dialect: POSIXExtended, RepeatDistribution: GeometricRepeats})
+			So(err, ShouldBeNil)
+		})
+
+		Convey("Leaves syntax errors to the parser", func() {
+			_, err := NewGenerator(`(a`, &GeneratorArgs{Dialect: PCRE})
+			So(errors.Is(err, ErrParse), ShouldBeTrue)
+		})
+
+		Convey("Returns an error for invalid dialects", func() {
+			_, err := NewGenerator(`a`, &GeneratorArgs{Dialect: Dialect(42)})
+			So(errors.Is(err, ErrInvalidArgs), ShouldBeTrue)
+		})
+	})
+}
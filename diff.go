@@ -0,0 +1,266 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"unicode"
+)
+
+// Diff is the difference between the strings matched by two patterns.
+type Diff struct {
+	// Strings matched by the old pattern but not the new one, shortest first.
+	OnlyOld []string
+	// Strings matched by the new pattern but not the old one, shortest first.
+	OnlyNew []string
+}
+
+// Equivalent returns true if the patterns match exactly the same strings.
+func (d *Diff) Equivalent() bool {
+	return len(d.OnlyOld) == 0 && len(d.OnlyNew) == 0
+}
+
+// String returns the witnesses in each direction, or a statement that the patterns are equivalent.
+func (d *Diff) String() string {
+	if d.Equivalent() {
+		return "patterns are equivalent"
+	}
+	var result bytes.Buffer
+	for _, side := range []struct {
+		name      string
+		witnesses []string
+	}{{"old", d.OnlyOld}, {"new", d.OnlyNew}} {
+		if len(side.witnesses) == 0 {
+			continue
+		}
+		if result.Len() > 0 {
+			result.WriteByte('\n')
+		}
+		result.WriteString("only matched by " + side.name + ":")
+		for _, witness := range side.witnesses {
+			result.WriteString("\n  " + strconv.Quote(witness))
+		}
+	}
+	return result.String()
+}
+
+/*
+DiffPatterns returns up to max witnesses in each direction: strings that match one pattern but not the other. Both
+patterns are parsed with args.Flags and args.Dialect, and must match entire strings. If args is nil, default
+values are used. Other args are ignored.
+
+Witnesses are the shortest strings that distinguish the patterns. Where many runes would do, witnesses use
+letters and digits where possible, e.g. "a" rather than "\x01". If the Diff is Equivalent, the patterns match the
+same strings.
+*/
+func DiffPatterns(oldPattern, newPattern string, inputArgs *GeneratorArgs, max int) (*Diff, error) {
+	args := GeneratorArgs{}
+	if inputArgs != nil {
+		args = *inputArgs
+	}
+	if err := args.initialize(); err != nil {
+		return nil, err
+	}
+	if max <= 0 {
+		return nil, newError(ErrInvalidArgs, nil, "invalid number of witnesses %d", max)
+	}
+	args.alphabet = diffAlphabet
+
+	oldDFA, err := constraintDFA(oldPattern, &args)
+	if err != nil {
+		return nil, constraintError("old", oldPattern, err)
+	}
+	newDFA, err := constraintDFA(newPattern, &args)
+	if err != nil {
+		return nil, constraintError("new", newPattern, err)
+	}
+
+	diff := &Diff{}
+	if diff.OnlyOld, err = witnesses(oldDFA, newDFA, args.alphabet, max); err != nil {
+		return nil, err
+	}
+	if diff.OnlyNew, err = witnesses(newDFA, oldDFA, args.alphabet, max); err != nil {
+		return nil, err
+	}
+	return diff, nil
+}
+
+// witnesses returns up to max of the shortest strings over alphabet accepted by a but not b, or an error if there are
+// some but they're too long to find.
+func witnesses(a, b *dfa, alphabet []runeInterval, max int) ([]string, error) {
+	start := &intersectionState{automata: []*dfa{a, b}, negated: []bool{false, true}, states: []int{0, 0}}
+	if len(a.states) == 0 {
+		return nil, nil
+	}
+	if len(b.states) == 0 {
+		start.states[1] = -1
+	}
+	difference, err := explore(start, alphabet)
+	if err != nil || len(difference.states) == 0 {
+		return nil, err
+	}
+
+	result := newDFASampler(difference).shortest(0, max)
+	if len(result) == 0 {
+		// The difference isn't empty, but its strings are longer than the table of string counts can be.
+		return nil, newError(ErrUnsupportedOp, nil,
+			"patterns are too complex to compare: they differ on strings longer than %d runes",
+			maxAutomatonTableSize/len(difference.states))
+	}
+	return result, nil
+}
+
+// shortest returns up to max of the shortest strings accepted from state, in order of length.
+func (s *dfaSampler) shortest(state, max int) []string {
+	// A finite language has no strings longer than the number of states. In an infinite one, every string at least
+	// that long can be pumped, so there's another string at most that much longer.
+	limit := len(s.dfa.states) * (max + 1)
+	if tableLimit := maxAutomatonTableSize / len(s.dfa.states); limit > tableLimit {
+		limit = tableLimit
+	}
+
+	var result []string
+	for n := 0; n <= limit && len(result) < max; n++ {
+		if s.canGenerateFrom(state, n) {
+			result = s.enumerate(result, state, n, nil, max, false)
+		}
+	}
+	return result
+}
+
+// enumerate appends strings of length n accepted from state, which must accept some, to result, until result has
+// max strings. prefix is the string that led to state. If everyRune is false, strings use one representative rune
+// of each transition, in order of the transitions. Otherwise they use every rune, readable runes first.
+func (s *dfaSampler) enumerate(result []string, state, n int, prefix []rune, max int, everyRune bool) []string {
+	if n == 0 {
+		return append(result, string(prefix))
+	}
+
+	type step struct {
+		r  rune
+		to int
+	}
+	var steps []step
+	for _, t := range s.dfa.states[state].transitions {
+		if !s.canGenerateFrom(t.to, n-1) {
+			continue
+		}
+		if !everyRune {
+			steps = append(steps, step{representativeRune(t.lo, t.hi), t.to})
+			continue
+		}
+		for _, r := range intervalRunes(t.lo, t.hi, max-len(result)) {
+			steps = append(steps, step{r, t.to})
+		}
+	}
+	if everyRune {
+		sort.SliceStable(steps, func(i, j int) bool {
+			return runeRank(steps[i].r) < runeRank(steps[j].r)
+		})
+	}
+
+	for _, step := range steps {
+		if len(result) >= max {
+			break
+		}
+		result = s.enumerate(result, step.to, n-1, append(prefix, step.r), max, everyRune)
+	}
+	return result
+}
+
+// Runes preferred as examples of intervals, in order.
+var representativeRunes = []*unicode.RangeTable{
+	unicode.Lower, unicode.Digit, unicode.Upper, unicode.Punct, unicode.Symbol, unicode.Letter, unicode.Mark,
+	unicode.Number, unicode.Space,
+}
+
+// representativeRune returns a readable rune from the interval [lo, hi], preferring ASCII.
+func representativeRune(lo, hi rune) rune {
+	for _, limit := range []rune{unicode.MaxASCII, hi} {
+		for _, table := range representativeRunes {
+			if r, ok := firstRuneIn(table, lo, minRune(hi, limit)); ok {
+				return r
+			}
+		}
+	}
+	return lo
+}
+
+// runeRank returns the index of the first of representativeRunes that contains r if it's ASCII, so lower ranks are
+// more readable, or len(representativeRunes) otherwise.
+func runeRank(r rune) int {
+	if r <= unicode.MaxASCII {
+		for i, table := range representativeRunes {
+			if unicode.Is(table, r) {
+				return i
+			}
+		}
+	}
+	return len(representativeRunes)
+}
+
+// intervalRunes returns up to max distinct runes from the interval [lo, hi]: the ASCII ones representativeRune
+// prefers first, in the same order, then the rest in order.
+func intervalRunes(lo, hi rune, max int) []rune {
+	var runes []rune
+	seen := make(map[rune]bool)
+	add := func(r rune) bool {
+		if !seen[r] {
+			seen[r] = true
+			runes = append(runes, r)
+		}
+		return len(runes) >= max
+	}
+
+	for _, table := range representativeRunes {
+		for r := lo; r <= minRune(hi, unicode.MaxASCII); r++ {
+			if unicode.Is(table, r) && add(r) {
+				return runes
+			}
+		}
+	}
+	for r := lo; r <= hi; r++ {
+		if add(r) {
+			return runes
+		}
+	}
+	return runes
+}
+
+// firstRuneIn returns the smallest rune in table in the interval [lo, hi].
+func firstRuneIn(table *unicode.RangeTable, lo, hi rune) (rune, bool) {
+	check := func(start, end, stride rune) (rune, bool) {
+		r := maxRune(lo, start)
+		if offset := (r - start) % stride; offset != 0 {
+			r += stride - offset
+		}
+		return r, r <= end && r <= hi
+	}
+	for _, r16 := range table.R16 {
+		if r, ok := check(rune(r16.Lo), rune(r16.Hi), rune(r16.Stride)); ok {
+			return r, true
+		}
+	}
+	for _, r32 := range table.R32 {
+		if r, ok := check(rune(r32.Lo), rune(r32.Hi), rune(r32.Stride)); ok {
+			return r, true
+		}
+	}
+	return 0, false
+}
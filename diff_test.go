@@ -0,0 +1,123 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"errors"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDiffPatterns(t *testing.T) {
+	t.Parallel()
+
+	Convey("DiffPatterns", t, func() {
+		Convey("Finds witnesses in both directions, shortest first", func() {
+			diff, err := DiffPatterns(`[a-z]{1,8}`, `[a-z0-9]{2,8}`, nil, 3)
+			So(err, ShouldBeNil)
+			So(diff.Equivalent(), ShouldBeFalse)
+			So(diff.OnlyOld, ShouldResemble, []string{"a"})
+			So(diff.OnlyNew, ShouldResemble, []string{"00", "0a", "a0"})
+		})
+
+		Convey("Witnesses distinguish the patterns", func() {
+			oldPattern, newPattern := `\w+@\w+\.com`, `[a-z]+@(gmail|example)\.com`
+			diff, err := DiffPatterns(oldPattern, newPattern, &GeneratorArgs{Flags: syntax.Perl}, 10)
+			So(err, ShouldBeNil)
+			So(diff.OnlyOld, ShouldHaveLength, 10)
+			So(diff.OnlyNew, ShouldBeEmpty)
+
+			old, new := regexp.MustCompile(`^(?:`+oldPattern+`)$`), regexp.MustCompile(`^(?:`+newPattern+`)$`)
+			for i, witness := range diff.OnlyOld {
+				So(old.MatchString(witness), ShouldBeTrue)
+				So(new.MatchString(witness), ShouldBeFalse)
+				if i > 0 {
+					So(len(witness), ShouldBeGreaterThanOrEqualTo, len(diff.OnlyOld[i-1]))
+				}
+			}
+			So(diff.OnlyOld[0], ShouldEqual, "0@0.com")
+		})
+
+		Convey("Reports equivalent patterns", func() {
+			diff, err := DiffPatterns(`a|b|c`, `[a-c]`, nil, 5)
+			So(err, ShouldBeNil)
+			So(diff.Equivalent(), ShouldBeTrue)
+			So(diff.String(), ShouldEqual, "patterns are equivalent")
+
+			diff, err = DiffPatterns(`(?i)abc`, `[aA][bB][cC]`, &GeneratorArgs{Flags: syntax.Perl}, 5)
+			So(err, ShouldBeNil)
+			So(diff.Equivalent(), ShouldBeTrue)
+
+			diff, err = DiffPatterns(`x*`, `(x|xx)*`, nil, 5)
+			So(err, ShouldBeNil)
+			So(diff.Equivalent(), ShouldBeTrue)
+		})
+
+		Convey("Distinguishes the null byte", func() {
+			diff, err := DiffPatterns(`a|\x00`, `a`, &GeneratorArgs{Flags: syntax.Perl}, 5)
+			So(err, ShouldBeNil)
+			So(diff.OnlyOld, ShouldResemble, []string{"\x00"})
+			So(diff.OnlyNew, ShouldBeEmpty)
+
+			diff, err = DiffPatterns(`.`, `[\x01-\x{10FFFF}]`, &GeneratorArgs{Flags: syntax.Perl | syntax.DotNL}, 5)
+			So(err, ShouldBeNil)
+			So(diff.OnlyOld, ShouldResemble, []string{"\x00"})
+		})
+
+		Convey("Returns an error if witnesses are too long to find", func() {
+			_, err := DiffPatterns(strings.Repeat("a", 3000), `b`, &GeneratorArgs{Flags: syntax.Perl}, 1)
+			So(errors.Is(err, ErrUnsupportedOp), ShouldBeTrue)
+		})
+
+		Convey("Handles patterns that match nothing", func() {
+			diff, err := DiffPatterns(`[^\x00-\x{10FFFF}]`, `ab?`, &GeneratorArgs{Flags: syntax.Perl}, 5)
+			So(err, ShouldBeNil)
+			So(diff.OnlyOld, ShouldBeEmpty)
+			So(diff.OnlyNew, ShouldResemble, []string{"a", "ab"})
+		})
+
+		Convey("Supports lookarounds and dialects", func() {
+			diff, err := DiffPatterns(`\d{3}`, `(?!0)\d{3}`, &GeneratorArgs{Flags: syntax.Perl}, 1)
+			So(err, ShouldBeNil)
+			So(diff.OnlyOld, ShouldResemble, []string{"000"})
+			So(diff.OnlyNew, ShouldBeEmpty)
+
+			diff, err = DiffPatterns(`a\h`, `a[ \t]`, &GeneratorArgs{Dialect: PCRE}, 1)
+			So(err, ShouldBeNil)
+			So(diff.OnlyOld, ShouldHaveLength, 1)
+			So(diff.OnlyNew, ShouldBeEmpty)
+		})
+
+		Convey("Formats witnesses", func() {
+			diff := &Diff{OnlyOld: []string{"a"}, OnlyNew: []string{"\n", "bb"}}
+			So(diff.String(), ShouldEqual, "only matched by old:\n  \"a\"\nonly matched by new:\n  \"\\n\"\n  \"bb\"")
+		})
+
+		Convey("Returns errors", func() {
+			_, err := DiffPatterns(`a(`, `a`, nil, 1)
+			So(errors.Is(err, ErrParse), ShouldBeTrue)
+			So(err.Error(), ShouldStartWith, "old pattern /a(/: ")
+
+			_, err = DiffPatterns(`a`, `a`, nil, 0)
+			So(errors.Is(err, ErrInvalidArgs), ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,270 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"regexp"
+	"regexp/syntax"
+	"unicode/utf8"
+)
+
+const (
+	// The number of matches GenerateDocument embeds if DocumentArgs.Matches is 0.
+	DefaultDocumentMatches = 10
+	// The fraction of a document's runes that belong to matches if DocumentArgs.Density is 0.
+	DefaultDocumentDensity = 0.2
+	// Lowercase letters, with spaces about as common as in English text.
+	DefaultDocumentBackground = "abcdefghijklmnopqrstuvwxyz     "
+
+	// The number of times each piece of noise is regenerated before giving up.
+	maxDocumentAttempts = 100
+	// The most runes of noise written between two matches, so tiny densities don't produce unbounded documents.
+	maxDocumentNoise = 1 << 16
+)
+
+// DocumentArgs configures GenerateDocument.
+type DocumentArgs struct {
+	// The number of matches to embed. Default is DefaultDocumentMatches, so 0 can't be requested: without matches,
+	// there's no noise either, and the document would be empty.
+	Matches int
+
+	// The fraction of the runes of the document that belong to matches, on average. Must be in (0, 1]. Very small
+	// densities are limited by writing at most 65536 runes of noise between matches.
+	// Default is DefaultDocumentDensity.
+	Density float64
+
+	// One of these strings, chosen at random, is written before and after each match. Include "" to allow matches
+	// next to noise. Default is a single space.
+	Separators []string
+
+	// The runes noise is chosen from, uniformly. Repeat a rune to make it more common.
+	// Default is DefaultDocumentBackground.
+	Background string
+}
+
+func (a *DocumentArgs) initialize() error {
+	if a.Matches == 0 {
+		a.Matches = DefaultDocumentMatches
+	}
+	if a.Density == 0 {
+		a.Density = DefaultDocumentDensity
+	}
+	if a.Separators == nil {
+		a.Separators = []string{" "}
+	}
+	if a.Background == "" {
+		a.Background = DefaultDocumentBackground
+	}
+
+	if a.Matches < 0 {
+		return newError(ErrInvalidArgs, nil, "invalid number of matches %d", a.Matches)
+	}
+	if a.Density < 0 || a.Density > 1 || math.IsNaN(a.Density) {
+		return newError(ErrInvalidArgs, nil, "density %v is not in (0, 1]", a.Density)
+	}
+	if len(a.Separators) == 0 {
+		return newError(ErrInvalidArgs, nil, "no separators")
+	}
+	if !utf8.ValidString(a.Background) {
+		return newError(ErrInvalidArgs, nil, "background %q is not valid UTF-8", a.Background)
+	}
+	return nil
+}
+
+// Document is text with matches of a pattern embedded in noise.
+type Document struct {
+	Text string
+	// The byte offsets of the start and end of each match in Text, in the form returned by
+	// regexp.FindAllStringIndex.
+	Matches [][]int
+}
+
+// String returns the text of the document.
+func (d *Document) String() string {
+	return d.Text
+}
+
+/*
+GenerateDocument returns text with args.Matches generated strings embedded in noise, for testing code that searches
+for the pattern. If args is nil, default values are used.
+
+Before and after each match is a random separator, and between matches is noise made of args.Background runes. The
+length of each piece of noise is chosen so that args.Density of the document is made of matches, on average.
+
+The document is checked with regexp.FindAllStringIndex, which must return exactly the embedded matches: noise that
+matches the pattern by accident, or makes a match longer, is regenerated along with the match after it. Matches
+that regexp would find differently, e.g. "ab" for the pattern a|ab, are regenerated too. An error is returned if
+that fails repeatedly, e.g. if the pattern matches the empty string, or the separators don't separate matches from
+noise. Patterns with lookarounds can't be checked, and return an error.
+
+While the document is built, only the text from the latest match on is checked, so building takes time linear in
+its length. The whole document is checked once it's complete, and an error is returned in the rare case that new
+text changed an earlier match.
+*/
+func (gen *internalGenerator) GenerateDocument(inputArgs *DocumentArgs) (*Document, error) {
+	args := DocumentArgs{}
+	if inputArgs != nil {
+		args = *inputArgs
+	}
+	if err := args.initialize(); err != nil {
+		return nil, err
+	}
+	matcher := gen.source
+	if matcher == nil {
+		if gen.regexp.String() != gen.Name {
+			return nil, newError(ErrUnsupportedOp, nil, "documents can't be checked for matches of /%s/", gen)
+		}
+		var err error
+		if matcher, err = regexp.Compile(gen.regexp.String()); err != nil {
+			return nil, newError(ErrUnsupportedOp, nil, "documents can't be checked for matches of /%s/: %s", gen, err)
+		}
+	}
+
+	builder := &documentBuilder{
+		gen:         gen,
+		args:        &args,
+		matcher:     matcher,
+		background:  []rune(args.Background),
+		incremental: !dependsOnStartContext(matcher),
+	}
+	for i := 0; i < args.Matches; i++ {
+		if err := builder.embed(); err != nil {
+			return nil, err
+		}
+	}
+	return builder.finish()
+}
+
+type documentBuilder struct {
+	gen        *internalGenerator
+	args       *DocumentArgs
+	matcher    *regexp.Regexp
+	background []rune
+	// Whether matches can be found by scanning from the latest match, rather than from the start of the document.
+	incremental bool
+	text        bytes.Buffer
+	matches     [][]int
+	// The length of the latest match, in runes, which the length of noise is based on.
+	lastLength int
+}
+
+// embed appends noise, then a generated match surrounded by separators.
+func (b *documentBuilder) embed() error {
+	return b.append(func(text *bytes.Buffer) []int {
+		match := b.gen.GenerateFunc(b.gen.args)
+		b.lastLength = utf8.RuneCountInString(match)
+		b.noise(text)
+		text.WriteString(b.separator())
+		start := text.Len()
+		text.WriteString(match)
+		end := text.Len()
+		text.WriteString(b.separator())
+		return []int{start, end}
+	})
+}
+
+// finish appends the noise after the last match, and checks the whole document.
+func (b *documentBuilder) finish() (*Document, error) {
+	err := b.append(func(text *bytes.Buffer) []int {
+		b.noise(text)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if b.incremental && !b.matchesExactly(b.matcher.FindAllIndex(b.text.Bytes(), -1), b.matches) {
+		return nil, newError(ErrUnsatisfiable, nil, "can't embed matches of /%s/ without them changing each other", b.gen)
+	}
+	return &Document{Text: b.text.String(), Matches: b.matches}, nil
+}
+
+// append appends the text written by write, which returns the offsets of the match it writes, if any. Until the
+// text from the latest match on contains exactly the expected matches, write is retried.
+func (b *documentBuilder) append(write func(text *bytes.Buffer) []int) error {
+	length := b.text.Len()
+	// Matches before the latest one are found the same way, as long as the new text doesn't change them.
+	confirmed, start := b.matches, 0
+	if !b.incremental {
+		confirmed = nil
+	} else if n := len(b.matches); n > 0 {
+		confirmed, start = b.matches[:n-1], b.matches[n-1][0]
+	}
+
+	for attempt := 0; attempt < maxDocumentAttempts; attempt++ {
+		b.text.Truncate(length)
+		matches := b.matches
+		if span := write(&b.text); span != nil {
+			matches = append(matches[:len(matches):len(matches)], span)
+		}
+
+		found := b.matcher.FindAllIndex(b.text.Bytes()[start:], -1)
+		for _, span := range found {
+			span[0] += start
+			span[1] += start
+		}
+		if b.matchesExactly(append(confirmed[:len(confirmed):len(confirmed)], found...), matches) {
+			b.matches = matches
+			return nil
+		}
+	}
+	return newError(ErrUnsatisfiable, nil, "can't embed matches of /%s/ in noise without accidental matches", b.gen)
+}
+
+func (b *documentBuilder) matchesExactly(found, matches [][]int) bool {
+	return reflect.DeepEqual(found, matches) || (len(found) == 0 && len(matches) == 0)
+}
+
+// noise writes random background runes. Lengths are uniform in [0, 2n], where n runes of noise per match gives
+// the document the requested density, up to maxDocumentNoise.
+func (b *documentBuilder) noise(text *bytes.Buffer) {
+	rng := b.gen.args.rng
+	mean := float64(b.lastLength) * (1 - b.args.Density) / b.args.Density
+	length := rng.Intn(int(math.Min(2*mean, maxDocumentNoise)) + 1)
+	for i := 0; i < length; i++ {
+		text.WriteRune(b.background[rng.Intn(len(b.background))])
+	}
+}
+
+func (b *documentBuilder) separator() string {
+	return b.args.Separators[b.gen.args.rng.Intn(len(b.args.Separators))]
+}
+
+// dependsOnStartContext returns whether matcher can match differently in a suffix of a string than in the whole
+// string, because it looks at the text before a match. Patterns that can't be parsed are assumed to.
+func dependsOnStartContext(matcher *regexp.Regexp) bool {
+	re, err := syntax.Parse(matcher.String(), syntax.Perl)
+	if err != nil {
+		return true
+	}
+	var walk func(re *syntax.Regexp) bool
+	walk = func(re *syntax.Regexp) bool {
+		switch re.Op {
+		case syntax.OpBeginLine, syntax.OpBeginText, syntax.OpWordBoundary, syntax.OpNoWordBoundary:
+			return true
+		}
+		for _, sub := range re.Sub {
+			if walk(sub) {
+				return true
+			}
+		}
+		return false
+	}
+	return walk(re)
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"errors"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGenerateDocument(t *testing.T) {
+	t.Parallel()
+
+	Convey("GenerateDocument", t, func() {
+		Convey("Embeds matches at their offsets", func() {
+			pattern := `[a-z]+@[a-z]+\.com`
+			gen, err := NewGenerator(pattern, &GeneratorArgs{Flags: syntax.Perl, MaxUnboundedRepeatCount: 8})
+			So(err, ShouldBeNil)
+			matcher := regexp.MustCompile(pattern)
+			for i := 0; i < 20; i++ {
+				doc, err := gen.(ExtendedGenerator).GenerateDocument(nil)
+				So(err, ShouldBeNil)
+				So(doc.Matches, ShouldHaveLength, DefaultDocumentMatches)
+				So(matcher.FindAllStringIndex(doc.Text, -1), ShouldResemble, doc.Matches)
+				for _, span := range doc.Matches {
+					So(matcher.MatchString(doc.Text[span[0]:span[1]]), ShouldBeTrue)
+				}
+			}
+		})
+
+		Convey("Uses the background and separators", func() {
+			gen, err := NewGenerator(`[0-9]{3}`, nil)
+			So(err, ShouldBeNil)
+			doc, err := gen.(ExtendedGenerator).GenerateDocument(&DocumentArgs{Matches: 5, Density: 0.1, Separators: []string{"<", ">"}, Background: "é"})
+			So(err, ShouldBeNil)
+			So(doc.Matches, ShouldHaveLength, 5)
+			So(strings.Trim(doc.Text, "é<>0123456789"), ShouldBeEmpty)
+			for _, span := range doc.Matches {
+				So(strings.ContainsAny(doc.Text[span[0]-1:span[0]], "<>"), ShouldBeTrue)
+				So(strings.ContainsAny(doc.Text[span[1]:span[1]+1], "<>"), ShouldBeTrue)
+			}
+		})
+
+		Convey("Controls density", func() {
+			gen, err := NewGenerator(`x{10}`, nil)
+			So(err, ShouldBeNil)
+			doc, err := gen.(ExtendedGenerator).GenerateDocument(&DocumentArgs{Matches: 200, Density: 1})
+			So(err, ShouldBeNil)
+			So(doc.Text, ShouldEqual, strings.Repeat(" xxxxxxxxxx ", 200))
+
+			doc, err = gen.(ExtendedGenerator).GenerateDocument(&DocumentArgs{Matches: 200, Density: 0.5, Separators: []string{""}, Background: "y"})
+			So(err, ShouldBeNil)
+			So(len(doc.Text), ShouldBeBetween, 3000, 5000)
+
+			doc, err = gen.(ExtendedGenerator).GenerateDocument(&DocumentArgs{Matches: 2, Density: 1e-20})
+			So(err, ShouldBeNil)
+			So(doc.Matches, ShouldHaveLength, 2)
+			So(utf8.RuneCountInString(doc.Text), ShouldBeLessThanOrEqualTo, 3*maxDocumentNoise+2*12)
+		})
+
+		Convey("Avoids accidental matches", func() {
+			gen, err := NewGenerator(`a|ab`, nil)
+			So(err, ShouldBeNil)
+			doc, err := gen.(ExtendedGenerator).GenerateDocument(&DocumentArgs{Separators: []string{""}, Background: "abc"})
+			So(err, ShouldBeNil)
+			So(regexp.MustCompile(`a|ab`).FindAllStringIndex(doc.Text, -1), ShouldResemble, doc.Matches)
+			for _, span := range doc.Matches {
+				So(doc.Text[span[0]:span[1]], ShouldEqual, "a")
+			}
+		})
+
+		Convey("Builds long documents", func() {
+			for _, pattern := range []string{`[a-z]{2,4}`, `\b[a-z]{2,4}\b`} {
+				gen, err := NewGenerator(pattern, &GeneratorArgs{Flags: syntax.Perl})
+				So(err, ShouldBeNil)
+				doc, err := gen.(ExtendedGenerator).GenerateDocument(&DocumentArgs{Matches: 1000, Background: "0123456789"})
+				So(err, ShouldBeNil)
+				So(doc.Matches, ShouldHaveLength, 1000)
+				So(regexp.MustCompile(pattern).FindAllStringIndex(doc.Text, -1), ShouldResemble, doc.Matches)
+			}
+			So(dependsOnStartContext(regexp.MustCompile(`[a-z]+$`)), ShouldBeFalse)
+			So(dependsOnStartContext(regexp.MustCompile(`(?m)^[a-z]+`)), ShouldBeTrue)
+		})
+
+		Convey("Returns errors", func() {
+			gen, err := NewGenerator(`a*`, nil)
+			So(err, ShouldBeNil)
+			_, err = gen.(ExtendedGenerator).GenerateDocument(nil)
+			So(errors.Is(err, ErrUnsatisfiable), ShouldBeTrue)
+
+			gen, err = NewGenerator(`^a$`, nil)
+			So(err, ShouldBeNil)
+			_, err = gen.(ExtendedGenerator).GenerateDocument(&DocumentArgs{Matches: 2})
+			So(errors.Is(err, ErrUnsatisfiable), ShouldBeTrue)
+			_, err = gen.(ExtendedGenerator).GenerateDocument(&DocumentArgs{Density: 2})
+			So(errors.Is(err, ErrInvalidArgs), ShouldBeTrue)
+			_, err = gen.(ExtendedGenerator).GenerateDocument(&DocumentArgs{Separators: []string{}})
+			So(errors.Is(err, ErrInvalidArgs), ShouldBeTrue)
+
+			gen, err = NewGenerator(`a(?=b)b`, &GeneratorArgs{Flags: syntax.Perl})
+			So(err, ShouldBeNil)
+			_, err = gen.(ExtendedGenerator).GenerateDocument(nil)
+			So(errors.Is(err, ErrUnsupportedOp), ShouldBeTrue)
+		})
+	})
+}
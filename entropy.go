@@ -0,0 +1,118 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"fmt"
+	"math"
+	"regexp/syntax"
+)
+
+/*
+Entropy describes the randomness of the strings produced by a generator, in bits.
+
+Both values are computed from the choices the generator makes: alternation branches are chosen uniformly, as are
+runes from character classes, and repeat counts are chosen from RepeatDistribution. They are exact if every string
+can only be generated by one sequence of choices. Otherwise (e.g. "a?a?", which generates "a" two ways) they
+overestimate the randomness of the output. Generators that use AutomatonEngine (including patterns with
+lookarounds, MustMatch or MustNotMatch) choose a length and then a string of that length uniformly, so their
+entropy is exact.
+*/
+type Entropy struct {
+	// Shannon entropy: the average number of bits of randomness per string.
+	Shannon float64
+	// Min-entropy: the number of bits of randomness in the most likely string. This is the measure to use for
+	// security, since an attacker will guess the most likely strings first.
+	Min float64
+}
+
+func (e Entropy) String() string {
+	return fmt.Sprintf("Shannon entropy: %.2f bits, min-entropy: %.2f bits", e.Shannon, e.Min)
+}
+
+/*
+Entropy estimates the randomness of the strings produced by the generator.
+Capture groups are measured from their expressions, ignoring any CaptureGroupHandler or Providers.
+
+Returns an ErrUnsupportedOp error if the generator uses TreeEngine and MinOutputLength, MaxOutputLength or
+UniformOutputLength is set, since the choices it makes to fit the lengths aren't measured.
+*/
+func (gen *internalGenerator) Entropy() (Entropy, error) {
+	if gen.lengthConstrained {
+		return Entropy{}, newError(ErrUnsupportedOp, nil,
+			"cannot measure the entropy of /%s/: output length limits are only measured by AutomatonEngine", gen)
+	}
+	return gen.entropy(), nil
+}
+
+func (gen *internalGenerator) entropy() Entropy {
+	switch {
+	case gen.automaton != nil:
+		return gen.automatonEntropy()
+
+	case gen.charClass != nil:
+		bits := math.Log2(float64(gen.charClass.TotalSize))
+		return Entropy{bits, bits}
+
+	case gen.regexp.Op == syntax.OpConcat, gen.regexp.Op == syntax.OpCapture:
+		var entropy Entropy
+		for _, sub := range gen.subs {
+			subEntropy := sub.entropy()
+			entropy.Shannon += subEntropy.Shannon
+			entropy.Min += subEntropy.Min
+		}
+		return entropy
+
+	case gen.regexp.Op == syntax.OpAlternate:
+		// Each branch is chosen with probability 1/n.
+		choice := math.Log2(float64(len(gen.subs)))
+		entropy := Entropy{choice, math.Inf(1)}
+		for _, sub := range gen.subs {
+			subEntropy := sub.entropy()
+			entropy.Shannon += subEntropy.Shannon / float64(len(gen.subs))
+			entropy.Min = math.Min(entropy.Min, choice+subEntropy.Min)
+		}
+		return entropy
+
+	case gen.isRepeat():
+		choice, minChoice, meanCount := gen.repeatCountEntropy()
+		subEntropy := gen.subs[0].entropy()
+		return Entropy{
+			Shannon: choice + subEntropy.Shannon*meanCount,
+			Min:     minChoice + subEntropy.Min*float64(gen.min),
+		}
+	}
+	return Entropy{}
+}
+
+// repeatCountEntropy returns the Shannon entropy and min-entropy of the repeat count chosen by gen, which is a
+// repeat, and its mean. The most likely string is assumed to repeat the minimum number of times.
+func (gen *internalGenerator) repeatCountEntropy() (shannon, min, mean float64) {
+	extra := gen.max - gen.min
+	if gen.args.RepeatDistribution == GeometricRepeats {
+		// Count min+i is chosen with probability 2^-(i+1), except max, which takes the remaining 2^-extra.
+		if extra == 0 {
+			return 0, 0, float64(gen.min)
+		}
+		tail := math.Exp2(-float64(extra))
+		return 2 - 2*tail, 1, float64(gen.min) + 1 - tail
+	}
+
+	// Each count is chosen with probability 1/(extra+1).
+	bits := math.Log2(float64(extra + 1))
+	return bits, bits, float64(gen.min+gen.max) / 2
+}
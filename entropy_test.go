@@ -0,0 +1,121 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp/syntax"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func ExampleEntropy() {
+	generator, _ := NewGenerator(`[A-Z]{4}-[0-9]{6}`, nil)
+
+	entropy, _ := generator.(ExtendedGenerator).Entropy()
+	fmt.Println(entropy)
+
+	// Output:
+	// Shannon entropy: 38.73 bits, min-entropy: 38.73 bits
+}
+
+func TestEntropy(t *testing.T) {
+	t.Parallel()
+
+	measure := func(gen Generator, err error) Entropy {
+		So(err, ShouldBeNil)
+		entropy, err := gen.(ExtendedGenerator).Entropy()
+		So(err, ShouldBeNil)
+		return entropy
+	}
+	entropy := func(pattern string) Entropy {
+		return measure(NewGenerator(pattern, &GeneratorArgs{MaxUnboundedRepeatCount: 3}))
+	}
+
+	Convey("Entropy", t, func() {
+		Convey("Uniform patterns have equal Shannon and min-entropy", func() {
+			e := entropy(`[0-9a-f]{32}`)
+			So(e.Shannon, ShouldAlmostEqual, 128, 1e-9)
+			So(e.Min, ShouldAlmostEqual, 128, 1e-9)
+		})
+
+		Convey("Literals have no entropy", func() {
+			So(entropy(`abc`), ShouldResemble, Entropy{})
+		})
+
+		Convey("Alternation is weighted by branch", func() {
+			// "xyz" is generated half the time, and each of the 256 two-digit hex strings 1/512 of the time.
+			e := entropy(`xyz|[0-9a-f]{2}`)
+			So(e.Shannon, ShouldAlmostEqual, 1+0.5*8, 1e-9)
+			So(e.Min, ShouldAlmostEqual, 1, 1e-9)
+		})
+
+		Convey("Repeat counts contribute entropy", func() {
+			// Counts 0-3 are equally likely, and the empty string is the most likely.
+			e := entropy(`[ab]*`)
+			So(e.Shannon, ShouldAlmostEqual, 2+1.5, 1e-9)
+			So(e.Min, ShouldAlmostEqual, 2, 1e-9)
+		})
+
+		Convey("Geometric repeat counts contribute less entropy", func() {
+			// Counts 0, 1, 2 and 3 are chosen with probabilities 1/2, 1/4, 1/8 and 1/8.
+			e := measure(NewGenerator(`[ab]*`, &GeneratorArgs{MaxUnboundedRepeatCount: 3, RepeatDistribution: GeometricRepeats}))
+			So(e.Shannon, ShouldAlmostEqual, 1.75+(0.25+2*0.125+3*0.125), 1e-9)
+			So(e.Min, ShouldAlmostEqual, 1, 1e-9)
+
+			e = measure(NewGenerator(`(?P<x>[ab]*)c*`, &GeneratorArgs{
+				Flags:                   syntax.Perl,
+				MaxUnboundedRepeatCount: 3,
+				Groups:                  map[string]GroupArgs{"x": {RepeatDistribution: GeometricRepeats}},
+			}))
+			So(e.Shannon, ShouldAlmostEqual, 1.75+0.875+2, 1e-9)
+		})
+
+		Convey("Is measured from the automaton when there are constraints", func() {
+			So(measure(NewGenerator(`[ab]{4}`, &GeneratorArgs{MustNotMatch: []string{`.*b.*`}})), ShouldResemble, Entropy{})
+
+			// 36^2 - 26^2 strings contain a digit.
+			e := measure(NewGenerator(`(?=.*[0-9])[a-z0-9]{2}`, &GeneratorArgs{Flags: syntax.Perl}))
+			So(e.Shannon, ShouldAlmostEqual, math.Log2(620), 1e-9)
+
+			// Lengths 0-3 are equally likely, and then each string of that length.
+			e = measure(NewGenerator(`[ab]*`, &GeneratorArgs{MaxUnboundedRepeatCount: 3, Engine: AutomatonEngine}))
+			So(e.Shannon, ShouldAlmostEqual, 2+1.5, 1e-9)
+			So(e.Min, ShouldAlmostEqual, 2, 1e-9)
+
+			e = measure(NewGenerator(`[ab]*`, &GeneratorArgs{MaxOutputLength: 3, Engine: AutomatonEngine}))
+			So(e.Shannon, ShouldAlmostEqual, 2+1.5, 1e-9)
+		})
+
+		Convey("Fails on output length limits it can't measure", func() {
+			for _, args := range []*GeneratorArgs{{MaxOutputLength: 3}, {MinOutputLength: 1}, {UniformOutputLength: true}} {
+				gen, err := NewGenerator(`[ab]{0,5}`, args)
+				So(err, ShouldBeNil)
+				_, err = gen.(ExtendedGenerator).Entropy()
+				So(errors.Is(err, ErrUnsupportedOp), ShouldBeTrue)
+			}
+		})
+
+		Convey("Is less than the log of the language size for non-uniform patterns", func() {
+			e := entropy(`xyz|[0-9a-f]{2}`)
+			So(e.Shannon, ShouldBeLessThan, math.Log2(257))
+		})
+	})
+}
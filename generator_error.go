@@ -17,22 +17,101 @@ limitations under the License.
 package regen
 
 import (
+	"errors"
 	"fmt"
+	"regexp/syntax"
+	"strings"
 )
 
-// Error returned by a generatorFactory if the AST is invalid.
-type tGeneratorError struct {
-	ErrorStr string
-	Cause    error
+// Kinds of Error. Use errors.Is to check the kind of an error returned by this package.
+var (
+	// ErrParse is the kind of error returned when a pattern can't be parsed. The Cause is a *syntax.Error.
+	ErrParse = errors.New("regen: invalid pattern")
+	// ErrUnsupportedOp is the kind of error returned when a pattern contains an expression strings can't be
+	// generated from.
+	ErrUnsupportedOp = errors.New("regen: unsupported expression")
+	// ErrUnicodeGroups is the kind of error returned when GeneratorArgs.Flags contains syntax.UnicodeGroups without
+	// syntax.Perl.
+	ErrUnicodeGroups = errors.New("regen: UnicodeGroups not supported")
+	// ErrInvalidArgs is the kind of error returned when GeneratorArgs, or the arguments to a method, are invalid.
+	ErrInvalidArgs = errors.New("regen: invalid arguments")
+	// ErrUnsatisfiable is the kind of error returned when the generator can't produce strings that satisfy the
+	// requested constraints (e.g. a length or a number of unique strings).
+	ErrUnsatisfiable = errors.New("regen: unsatisfiable constraints")
+)
+
+/*
+Error is the type of all errors returned by this package. Use errors.As to get an Error, e.g. to highlight the
+part of the pattern that caused it:
+
+	var regenErr *regen.Error
+	if errors.As(err, &regenErr) && regenErr.Offset >= 0 {
+		highlight(pattern[regenErr.Offset : regenErr.Offset+len(regenErr.Expr)])
+	}
+*/
+type Error struct {
+	// Kind is one of the Err* variables, e.g. ErrUnsupportedOp.
+	Kind error
+	// Expr is the sub-pattern that caused the error, or empty if the error isn't caused by a specific sub-pattern.
+	Expr string
+	// Offset is the byte offset of Expr in the pattern passed to NewGenerator, or -1 if it's unknown.
+	Offset int
+	// Msg describes the error.
+	Msg string
+	// Cause is the underlying error, if any.
+	Cause error
+}
+
+func newError(kind error, expr *syntax.Regexp, format string, args ...interface{}) *Error {
+	err := &Error{Kind: kind, Offset: -1, Msg: fmt.Sprintf(format, args...)}
+	if expr != nil {
+		err.Expr = expr.String()
+	}
+	return err
+}
+
+// at sets the Expr of err to expr, and returns err.
+func (err *Error) at(expr *syntax.Regexp) *Error {
+	err.Expr = expr.String()
+	return err
+}
+
+// parseError wraps an error returned by syntax.Parse.
+func parseError(cause error) *Error {
+	err := &Error{Kind: ErrParse, Offset: -1, Cause: cause}
+	if syntaxErr, ok := cause.(*syntax.Error); ok {
+		err.Expr = syntaxErr.Expr
+	}
+	return err
+}
+
+func (err *Error) Error() string {
+	switch {
+	case err.Cause == nil:
+		return err.Msg
+	case err.Msg == "":
+		return err.Cause.Error()
+	}
+	return err.Msg + ": " + err.Cause.Error()
+}
+
+// Is returns true if target is the Kind of err.
+func (err *Error) Is(target error) bool {
+	return target == err.Kind
 }
 
-func generatorError(cause error, format string, args ...interface{}) error {
-	return &tGeneratorError{fmt.Sprintf(format, args...), cause}
+func (err *Error) Unwrap() error {
+	return err.Cause
 }
 
-func (err *tGeneratorError) Error() string {
-	if err.Cause != nil {
-		return fmt.Sprintf("%s\ncaused by %s", err.ErrorStr, err.Cause.Error())
+// locate sets the Offset of err to the position of its Expr in pattern, if it appears exactly once. Exprs from the
+// parsed tree are formatted by syntax.Regexp.String, so they're only found if the pattern wrote them the same way,
+// and an Expr that appears more than once can't be told apart from its copies.
+func locate(err error, pattern string) error {
+	if regenErr, ok := err.(*Error); ok && regenErr.Expr != "" && regenErr.Offset < 0 {
+		if offset := strings.Index(pattern, regenErr.Expr); offset == strings.LastIndex(pattern, regenErr.Expr) {
+			regenErr.Offset = offset
+		}
 	}
-	return err.ErrorStr
+	return err
 }
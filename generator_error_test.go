@@ -18,22 +18,103 @@ package regen
 
 import (
 	"errors"
+	"fmt"
+	"regexp/syntax"
 	"testing"
+	"unicode"
 
 	. "github.com/smartystreets/goconvey/convey"
 )
 
 func TestGeneratorError(t *testing.T) {
-	Convey("GeneratorError", t, func() {
+	Convey("Error", t, func() {
 
-		Convey("Handles nil cause", func() {
-			err := generatorError(nil, "msg")
-			So(err.Error(), ShouldEqual, "msg")
+		Convey("Formats", func() {
+			err := newError(ErrInvalidArgs, nil, "msg %s", "arg")
+			So(err.Error(), ShouldEqual, "msg arg")
+			So(err.Offset, ShouldEqual, -1)
 		})
 
-		Convey("Formats", func() {
-			err := generatorError(errors.New("cause"), "msg %s", "arg")
-			So(err.Error(), ShouldEqual, "msg arg\ncaused by cause")
+		Convey("Formats cause", func() {
+			err := &Error{Kind: ErrInvalidArgs, Msg: "msg", Cause: errors.New("cause")}
+			So(err.Error(), ShouldEqual, "msg: cause")
+
+			err = &Error{Kind: ErrInvalidArgs, Cause: errors.New("cause")}
+			So(err.Error(), ShouldEqual, "cause")
+		})
+
+		Convey("Supports errors.Is and errors.As", func() {
+			cause := errors.New("cause")
+			var err error = &Error{Kind: ErrInvalidArgs, Cause: cause}
+			So(errors.Is(err, ErrInvalidArgs), ShouldBeTrue)
+			So(errors.Is(err, ErrUnsupportedOp), ShouldBeFalse)
+			So(errors.Is(err, cause), ShouldBeTrue)
+
+			var regenErr *Error
+			So(errors.As(fmt.Errorf("wrapped: %w", err), &regenErr), ShouldBeTrue)
+			So(regenErr, ShouldEqual, err)
+		})
+
+		Convey("Parse errors", func() {
+			_, err := NewGenerator(`ab(c`, nil)
+			So(errors.Is(err, ErrParse), ShouldBeTrue)
+
+			var syntaxErr *syntax.Error
+			So(errors.As(err, &syntaxErr), ShouldBeTrue)
+			So(syntaxErr.Code, ShouldEqual, syntax.ErrMissingParen)
+
+			var regenErr *Error
+			So(errors.As(err, &regenErr), ShouldBeTrue)
+			So(regenErr.Expr, ShouldEqual, "ab(c")
+			So(regenErr.Offset, ShouldEqual, 0)
+
+			_, err = NewGenerator(`abc[z-a]`, nil)
+			So(errors.As(err, &regenErr), ShouldBeTrue)
+			So(regenErr.Expr, ShouldEqual, "z-a")
+			So(regenErr.Offset, ShouldEqual, 4)
+		})
+
+		Convey("Unicode groups", func() {
+			_, err := NewGenerator(`\pL`, &GeneratorArgs{Flags: syntax.UnicodeGroups})
+			So(errors.Is(err, ErrUnicodeGroups), ShouldBeTrue)
+		})
+
+		Convey("Invalid args", func() {
+			gen, err := NewGenerator(`a|b`, nil)
+			So(err, ShouldBeNil)
+			_, err = gen.(ExtendedGenerator).GenerateCombinations(0)
+			So(errors.Is(err, ErrInvalidArgs), ShouldBeTrue)
+		})
+
+		Convey("Unsupported ops", func() {
+			_, err := newGenerator(&syntax.Regexp{Op: syntax.OpConcat, Sub: []*syntax.Regexp{
+				{Op: syntax.OpLiteral, Rune: []rune("xy")},
+				{Op: syntax.Op(255)},
+			}}, &GeneratorArgs{})
+			So(errors.Is(err, ErrUnsupportedOp), ShouldBeTrue)
+		})
+
+		Convey("Locates sub-patterns", func() {
+			_, err := NewGenerator(`xy(ab|[^\x00-\x{10FFFF}])`, nil)
+			So(errors.Is(err, ErrUnsatisfiable), ShouldBeTrue)
+
+			var regenErr *Error
+			So(errors.As(err, &regenErr), ShouldBeTrue)
+			So(regenErr.Expr, ShouldEqual, `[^\x00-\x{10FFFF}]`)
+			So(regenErr.Offset, ShouldEqual, 6)
+		})
+
+		Convey("Doesn't locate sub-patterns that appear more than once", func() {
+			_, err := NewGenerator(`[a-z](?P<greek>[a-z])`, &GeneratorArgs{
+				Flags:  syntax.Perl,
+				Groups: map[string]GroupArgs{"greek": {Universe: unicode.Greek}},
+			})
+			So(errors.Is(err, ErrUnsatisfiable), ShouldBeTrue)
+
+			var regenErr *Error
+			So(errors.As(err, &regenErr), ShouldBeTrue)
+			So(regenErr.Expr, ShouldEqual, `[a-z]`)
+			So(regenErr.Offset, ShouldEqual, -1)
 		})
 	})
 }
@@ -0,0 +1,113 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"fmt"
+	"math/rand"
+	"regexp/syntax"
+	"unicode"
+)
+
+// RepeatDistribution is the distribution of the number of times repeats (e.g. "x*" and "x{2,5}") are generated.
+type RepeatDistribution int
+
+const (
+	// UniformRepeats chooses every number of repetitions between the bounds with equal probability.
+	UniformRepeats RepeatDistribution = iota
+	// GeometricRepeats favours short repeats: after the minimum, each further repetition is generated with
+	// probability 1/2, up to the maximum.
+	GeometricRepeats
+)
+
+var repeatDistributionNames = map[RepeatDistribution]string{
+	UniformRepeats:   "UniformRepeats",
+	GeometricRepeats: "GeometricRepeats",
+}
+
+func (d RepeatDistribution) String() string {
+	if name, ok := repeatDistributionNames[d]; ok {
+		return name
+	}
+	return fmt.Sprintf("RepeatDistribution(%d)", int(d))
+}
+
+/*
+GroupArgs override GeneratorArgs for the expression inside a named capture group, e.g. `(?P<query>.*)`. Groups
+nested inside the group inherit the overrides, and can override them again. Zero values inherit the setting from
+the enclosing group, or from GeneratorArgs.
+
+GroupArgs aren't supported by AutomatonEngine, which is also used for lookarounds, MustMatch and MustNotMatch.
+*/
+type GroupArgs struct {
+	MaxUnboundedRepeatCount uint
+	MinUnboundedRepeatCount uint
+
+	// If not nil, replaces GeneratorArgs.Universe.
+	Universe *unicode.RangeTable
+
+	// If not UniformRepeats, replaces GeneratorArgs.RepeatDistribution.
+	RepeatDistribution RepeatDistribution
+}
+
+// groupArgs returns the args for the expression inside the named capture group, or args itself if the group has
+// no overrides.
+func (a *GeneratorArgs) groupArgs(group *syntax.Regexp) (*GeneratorArgs, error) {
+	overrides, ok := a.Groups[group.Name]
+	if !ok || group.Name == "" {
+		return a, nil
+	}
+
+	args := *a
+	if overrides.MaxUnboundedRepeatCount > 0 {
+		args.MaxUnboundedRepeatCount = overrides.MaxUnboundedRepeatCount
+	}
+	if overrides.MinUnboundedRepeatCount > 0 {
+		args.MinUnboundedRepeatCount = overrides.MinUnboundedRepeatCount
+	}
+	if overrides.Universe != nil {
+		args.Universe = overrides.Universe
+	}
+	if overrides.RepeatDistribution != UniformRepeats {
+		args.RepeatDistribution = overrides.RepeatDistribution
+	}
+
+	if args.MinUnboundedRepeatCount > args.MaxUnboundedRepeatCount {
+		return nil, newError(ErrInvalidArgs, group, "group %s: MinUnboundedRepeatCount(%d) > MaxUnboundedRepeatCount(%d)",
+			group.Name, args.MinUnboundedRepeatCount, args.MaxUnboundedRepeatCount)
+	}
+	if err := args.capUnboundedRepeats(); err != nil {
+		return nil, err.(*Error).at(group)
+	}
+	if _, ok := repeatDistributionNames[args.RepeatDistribution]; !ok {
+		return nil, newError(ErrInvalidArgs, group, "group %s: invalid RepeatDistribution %d",
+			group.Name, args.RepeatDistribution)
+	}
+	return &args, nil
+}
+
+// repeatCount returns a number of repetitions in [min, max] for a repeat generated with args, chosen using rng.
+func (a *GeneratorArgs) repeatCount(rng *rand.Rand, min, max int) int {
+	if a.RepeatDistribution == GeometricRepeats {
+		n := min
+		for n < max && rng.Intn(2) == 0 {
+			n++
+		}
+		return n
+	}
+	return min + rng.Intn(max-min+1)
+}
@@ -0,0 +1,197 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"errors"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"testing"
+	"unicode"
+	"unicode/utf8"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var printableASCII = &unicode.RangeTable{R16: []unicode.Range16{{Lo: ' ', Hi: '~', Stride: 1}}}
+
+func TestGroupArgs(t *testing.T) {
+	t.Parallel()
+
+	Convey("Groups", t, func() {
+		Convey("Override args inside named groups", func() {
+			pattern := `(?P<path>(/[a-z]+)*)\?(?P<q>.*)`
+			gen, err := NewGenerator(pattern, &GeneratorArgs{
+				Flags:                   syntax.Perl,
+				MaxUnboundedRepeatCount: 20,
+				Groups: map[string]GroupArgs{
+					"path": {MinUnboundedRepeatCount: 1, MaxUnboundedRepeatCount: 3},
+					"q":    {MaxUnboundedRepeatCount: 5, Universe: printableASCII},
+				},
+			})
+			So(err, ShouldBeNil)
+
+			matcher := regexp.MustCompile(`^(?P<path>(/[a-z]+)*)\?(?P<q>.*)$`)
+			longestWord := 0
+			for i := 0; i < SampleSize; i++ {
+				value := gen.Generate()
+				groups := matcher.FindStringSubmatch(value)
+				So(groups, ShouldNotBeNil)
+				path, query := groups[1], groups[3]
+
+				So(strings.Count(path, "/"), ShouldBeBetweenOrEqual, 1, 3)
+				for _, word := range strings.Split(path, "/")[1:] {
+					if len(word) > longestWord {
+						longestWord = len(word)
+					}
+				}
+				So(utf8.RuneCountInString(query), ShouldBeLessThanOrEqualTo, 5)
+				for _, r := range query {
+					So(r, ShouldBeBetweenOrEqual, ' ', '~')
+				}
+			}
+			// Nested repeats inherit the group's limits, not the ones of GeneratorArgs.
+			So(longestWord, ShouldEqual, 3)
+		})
+
+		Convey("Are inherited and overridden by nested groups", func() {
+			gen, err := NewGenerator(`(?P<outer>a*(?P<inner>b*))`, &GeneratorArgs{
+				Flags: syntax.Perl,
+				Groups: map[string]GroupArgs{
+					"outer": {MaxUnboundedRepeatCount: 2},
+					"inner": {MinUnboundedRepeatCount: 4, MaxUnboundedRepeatCount: 6},
+				},
+			})
+			So(err, ShouldBeNil)
+			matcher := regexp.MustCompile(`^a{0,2}b{4,6}$`)
+			for i := 0; i < SampleSize; i++ {
+				So(matcher.MatchString(gen.Generate()), ShouldBeTrue)
+			}
+		})
+
+		Convey("Are visible to analysis", func() {
+			gen, err := NewGenerator(`x(?P<digits>\d*)`, &GeneratorArgs{
+				Flags:  syntax.Perl,
+				Groups: map[string]GroupArgs{"digits": {MaxUnboundedRepeatCount: 2}},
+			})
+			So(err, ShouldBeNil)
+			So(gen.(ExtendedGenerator).MaxLength(Runes), ShouldEqual, 3)
+
+			values, err := gen.(ExtendedGenerator).GenerateUnique(111)
+			So(err, ShouldBeNil)
+			So(values, ShouldHaveLength, 111)
+			_, err = gen.(ExtendedGenerator).GenerateUnique(112)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Are passed to capture group handlers and providers", func() {
+			var universe *unicode.RangeTable
+			gen, err := NewGenerator(`x(?P<id>[0-9]{4})`, &GeneratorArgs{
+				Flags:    syntax.Perl,
+				Universe: unicode.Latin,
+				Groups:   map[string]GroupArgs{"id": {Universe: unicode.Digit}},
+				CaptureGroupHandler: func(index int, name string, group *syntax.Regexp, generator Generator, args *GeneratorArgs) string {
+					universe = args.Universe
+					return generator.Generate()
+				},
+			})
+			So(err, ShouldBeNil)
+			gen.Generate()
+			So(universe, ShouldEqual, unicode.Digit)
+
+			providers := NewProviderRegistry()
+			providers.Register("id", Counter(1))
+			gen, err = NewGenerator(`x(?P<id>[0-9]{4})`, &GeneratorArgs{
+				Flags:     syntax.Perl,
+				Universe:  unicode.Latin,
+				Groups:    map[string]GroupArgs{"id": {Universe: unicode.Digit}},
+				Providers: providers,
+			})
+			So(err, ShouldBeNil)
+			So(gen.Generate(), ShouldEqual, "x0001")
+			So(gen.Generate(), ShouldEqual, "x0002")
+		})
+
+		Convey("Return errors for invalid overrides", func() {
+			_, err := NewGenerator(`(?P<a>x*)`, &GeneratorArgs{
+				Flags:  syntax.Perl,
+				Groups: map[string]GroupArgs{"a": {MinUnboundedRepeatCount: 5, MaxUnboundedRepeatCount: 2}},
+			})
+			So(errors.Is(err, ErrInvalidArgs), ShouldBeTrue)
+
+			_, err = NewGenerator(`(?P<a>x*)`, &GeneratorArgs{
+				Flags:  syntax.Perl,
+				Groups: map[string]GroupArgs{"a": {RepeatDistribution: RepeatDistribution(42)}},
+			})
+			So(errors.Is(err, ErrInvalidArgs), ShouldBeTrue)
+		})
+	})
+
+	Convey("Universe", t, func() {
+		Convey("Restricts character classes", func() {
+			gen, err := NewGenerator(`.[^a-z]é`, &GeneratorArgs{Flags: syntax.Perl, Universe: unicode.Latin})
+			So(err, ShouldBeNil)
+			matcher := regexp.MustCompile(`^\p{Latin}[^a-z\P{Latin}]é$`)
+			for i := 0; i < SampleSize; i++ {
+				So(matcher.MatchString(gen.Generate()), ShouldBeTrue)
+			}
+		})
+
+		Convey("Handles tables with strides", func() {
+			gen, err := NewGenerator(`[\x{100}-\x{17f}]`, &GeneratorArgs{Universe: unicode.Upper})
+			So(err, ShouldBeNil)
+			for i := 0; i < SampleSize; i++ {
+				r, _ := utf8.DecodeRuneInString(gen.Generate())
+				So(unicode.IsUpper(r), ShouldBeTrue)
+			}
+		})
+
+		Convey("Returns an error if a class is empty", func() {
+			_, err := NewGenerator(`ab[0-9]`, &GeneratorArgs{Universe: unicode.Letter})
+			So(errors.Is(err, ErrUnsatisfiable), ShouldBeTrue)
+			var regenErr *Error
+			So(errors.As(err, &regenErr), ShouldBeTrue)
+			So(regenErr.Expr, ShouldEqual, `[0-9]`)
+			So(regenErr.Offset, ShouldEqual, 2)
+		})
+	})
+
+	Convey("RepeatDistribution", t, func() {
+		Convey("GeometricRepeats favours short repeats", func() {
+			for _, args := range []*GeneratorArgs{
+				{RepeatDistribution: GeometricRepeats},
+				{RepeatDistribution: GeometricRepeats, MaxOutputLength: 1000},
+			} {
+				gen, err := NewGenerator(`a{2,100}`, args)
+				So(err, ShouldBeNil)
+				total := 0
+				for i := 0; i < SampleSize; i++ {
+					value := gen.Generate()
+					So(len(value), ShouldBeBetweenOrEqual, 2, 100)
+					total += len(value)
+				}
+				So(float64(total)/SampleSize, ShouldBeBetween, 2.5, 3.5)
+			}
+		})
+
+		Convey("Returns an error if invalid", func() {
+			_, err := NewGenerator(`a*`, &GeneratorArgs{RepeatDistribution: RepeatDistribution(42)})
+			So(errors.Is(err, ErrInvalidArgs), ShouldBeTrue)
+		})
+	})
+}
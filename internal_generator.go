@@ -19,8 +19,10 @@ package regen
 import (
 	"bytes"
 	"fmt"
-	"math"
+	"regexp"
 	"regexp/syntax"
+	"sync"
+	"unicode"
 )
 
 // generatorFactory is a function that creates a random string generator from a regular expression AST.
@@ -45,6 +47,7 @@ func init() {
 		syntax.OpConcat:         opConcat,
 		syntax.OpAlternate:      opAlternate,
 		syntax.OpCapture:        opCapture,
+		syntax.OpNoMatch:        opNoMatch,
 		syntax.OpBeginLine:      noop,
 		syntax.OpEndLine:        noop,
 		syntax.OpBeginText:      noop,
@@ -55,18 +58,73 @@ func init() {
 }
 
 type internalGenerator struct {
-	Name         string
-	GenerateFunc func() string
+	Name string
+	// Generates a string using the rng and provider states in args, which belong to the generator returned by
+	// NewGenerator or Clone that is generating. Other settings are taken from the args the generator was created with.
+	GenerateFunc func(args *GeneratorArgs) string
+
+	// The args the generator was created with.
+	args *GeneratorArgs
+
+	// The simplified expression the generator was created from.
+	regexp *syntax.Regexp
+	// The regexp passed to NewGeneratorFromRegexp, if any.
+	source *regexp.Regexp
+	// A leftmost-longest copy of source, to check it matches generated strings entirely.
+	sourceMatcher *regexp.Regexp
+	// Generators for the sub-expressions of regexp, if any.
+	subs []*internalGenerator
+	// The runes generated by generators for single characters.
+	charClass *tCharClass
+	// The bounds on the number of times subs[0] is generated by repeating generators.
+	min, max int
+	// True if max was limited by MaxUnboundedRepeatCount.
+	unbounded bool
+
+	// If not nil, strings are generated from this automaton instead of the sub-generators (e.g. for lookarounds).
+	automaton *dfaSampler
+	// The lengths of the strings generated from automaton, in increasing order.
+	automatonLengths []int
+	// True if GenerateFunc was replaced by one that bounds the lengths of the strings generated from the
+	// sub-generators (see newBoundedGenerator and newUniformLengthGenerator).
+	lengthConstrained bool
+
+	// Tables computed the first time they're needed, shared with clones.
+	tables *lazyTables
+	// If gen is a clone, the generator in the tree it was copied from.
+	original *internalGenerator
+}
+
+// lazyTables are the tables a generator computes the first time they're needed. They're shared by a generator and
+// its clones, and only change while locked.
+type lazyTables struct {
+	lock sync.Mutex
+	// Used by GenerateLength and UniformOutputLength.
+	solver *lengthSolver
+	// Used by GenerateWithPrefix and Complete.
+	completer *dfaSampler
 }
 
 func (gen *internalGenerator) Generate() string {
-	return gen.GenerateFunc()
+	return gen.GenerateFunc(gen.args)
 }
 
 func (gen *internalGenerator) String() string {
 	return gen.Name
 }
 
+func (gen *internalGenerator) ResetState() {
+	gen.args.state.reset()
+}
+
+func (gen *internalGenerator) SaveState() *GeneratorState {
+	return gen.args.state.save()
+}
+
+func (gen *internalGenerator) RestoreState(state *GeneratorState) {
+	gen.args.state.restore(state)
+}
+
 // Create a new generator for each expression in regexps.
 func newGenerators(regexps []*syntax.Regexp, args *GeneratorArgs) ([]*internalGenerator, error) {
 	generators := make([]*internalGenerator, len(regexps), len(regexps))
@@ -89,45 +147,63 @@ func newGenerator(regexp *syntax.Regexp, args *GeneratorArgs) (generator *intern
 
 	factory, ok := generatorFactories[simplified.Op]
 	if ok {
-		return factory(simplified, args)
+		generator, err = factory(simplified, args)
+		if err != nil {
+			return nil, err
+		}
+		generator.args = args
+		generator.regexp = simplified
+		generator.tables = &lazyTables{}
+		return generator, nil
 	}
 
-	return nil, fmt.Errorf("invalid generator pattern: /%s/ as /%s/\n%s",
-		regexp, simplified, inspectRegexpToString(simplified))
+	return nil, newError(ErrUnsupportedOp, regexp, "cannot generate strings from /%s/: %s not supported",
+		regexp, simplified.Op)
 }
 
 // Generator that does nothing.
 func noop(regexp *syntax.Regexp, args *GeneratorArgs) (*internalGenerator, error) {
-	return &internalGenerator{regexp.String(), func() string {
+	return &internalGenerator{Name: regexp.String(), GenerateFunc: func(args *GeneratorArgs) string {
 		return ""
 	}}, nil
 }
 
 func opEmptyMatch(regexp *syntax.Regexp, args *GeneratorArgs) (*internalGenerator, error) {
 	enforceOp(regexp, syntax.OpEmptyMatch)
-	return &internalGenerator{regexp.String(), func() string {
+	return &internalGenerator{Name: regexp.String(), GenerateFunc: func(args *GeneratorArgs) string {
 		return ""
 	}}, nil
 }
 
+// Patterns that can't match anything, e.g. "[^\x00-\x{10FFFF}]", can't generate anything either.
+func opNoMatch(regexp *syntax.Regexp, args *GeneratorArgs) (*internalGenerator, error) {
+	enforceOp(regexp, syntax.OpNoMatch)
+	return nil, newError(ErrUnsatisfiable, regexp, "/%s/ doesn't match any strings", regexp)
+}
+
 func opLiteral(regexp *syntax.Regexp, args *GeneratorArgs) (*internalGenerator, error) {
 	enforceOp(regexp, syntax.OpLiteral)
-	return &internalGenerator{regexp.String(), func() string {
+	return &internalGenerator{Name: regexp.String(), GenerateFunc: func(args *GeneratorArgs) string {
 		return runesToString(regexp.Rune...)
 	}}, nil
 }
 
 func opAnyChar(regexp *syntax.Regexp, args *GeneratorArgs) (*internalGenerator, error) {
 	enforceOp(regexp, syntax.OpAnyChar)
-	return &internalGenerator{regexp.String(), func() string {
-		return runesToString(rune(args.rng.Int31()))
-	}}, nil
+	charClass, err := newCharClass(1, unicode.MaxRune)
+	if err != nil {
+		return nil, err
+	}
+	return createCharClassGenerator(regexp, charClass, args)
 }
 
 func opAnyCharNotNl(regexp *syntax.Regexp, args *GeneratorArgs) (*internalGenerator, error) {
 	enforceOp(regexp, syntax.OpAnyCharNotNL)
-	charClass := newCharClass(1, rune(math.MaxInt32))
-	return createCharClassGenerator(regexp.String(), charClass, args)
+	charClass, err := parseCharClass([]rune{1, '\n' - 1, '\n' + 1, unicode.MaxRune})
+	if err != nil {
+		return nil, err
+	}
+	return createCharClassGenerator(regexp, charClass, args)
 }
 
 func opQuest(regexp *syntax.Regexp, args *GeneratorArgs) (*internalGenerator, error) {
@@ -154,8 +230,11 @@ func opRepeat(regexp *syntax.Regexp, args *GeneratorArgs) (*internalGenerator, e
 // classes that respect it.
 func opCharClass(regexp *syntax.Regexp, args *GeneratorArgs) (*internalGenerator, error) {
 	enforceOp(regexp, syntax.OpCharClass)
-	charClass := parseCharClass(regexp.Rune)
-	return createCharClassGenerator(regexp.String(), charClass, args)
+	charClass, err := parseCharClass(regexp.Rune)
+	if err != nil {
+		return nil, err.(*Error).at(regexp)
+	}
+	return createCharClassGenerator(regexp, charClass, args)
 }
 
 func opConcat(regexp *syntax.Regexp, genArgs *GeneratorArgs) (*internalGenerator, error) {
@@ -163,13 +242,13 @@ func opConcat(regexp *syntax.Regexp, genArgs *GeneratorArgs) (*internalGenerator
 
 	generators, err := newGenerators(regexp.Sub, genArgs)
 	if err != nil {
-		return nil, generatorError(err, "error creating generators for concat pattern /%s/", regexp)
+		return nil, err
 	}
 
-	return &internalGenerator{regexp.String(), func() string {
+	return &internalGenerator{Name: regexp.String(), subs: generators, GenerateFunc: func(args *GeneratorArgs) string {
 		var result bytes.Buffer
 		for _, generator := range generators {
-			result.WriteString(generator.Generate())
+			result.WriteString(generator.GenerateFunc(args))
 		}
 		return result.String()
 	}}, nil
@@ -180,27 +259,31 @@ func opAlternate(regexp *syntax.Regexp, genArgs *GeneratorArgs) (*internalGenera
 
 	generators, err := newGenerators(regexp.Sub, genArgs)
 	if err != nil {
-		return nil, generatorError(err, "error creating generators for alternate pattern /%s/", regexp)
+		return nil, err
 	}
 
 	numGens := len(generators)
 
-	return &internalGenerator{regexp.String(), func() string {
-		i := genArgs.rng.Intn(numGens)
+	return &internalGenerator{Name: regexp.String(), subs: generators, GenerateFunc: func(args *GeneratorArgs) string {
+		i := args.rng.Intn(numGens)
 		generator := generators[i]
-		return generator.Generate()
+		return generator.GenerateFunc(args)
 	}}, nil
 }
 
-func opCapture(regexp *syntax.Regexp, args *GeneratorArgs) (*internalGenerator, error) {
+func opCapture(regexp *syntax.Regexp, genArgs *GeneratorArgs) (*internalGenerator, error) {
 	enforceOp(regexp, syntax.OpCapture)
 
 	if err := enforceSingleSub(regexp); err != nil {
 		return nil, err
 	}
 
+	groupArgs, err := genArgs.groupArgs(regexp)
+	if err != nil {
+		return nil, err
+	}
 	groupRegexp := regexp.Sub[0]
-	generator, err := newGenerator(groupRegexp, args)
+	generator, err := newGenerator(groupRegexp, groupArgs)
 	if err != nil {
 		return nil, err
 	}
@@ -208,11 +291,24 @@ func opCapture(regexp *syntax.Regexp, args *GeneratorArgs) (*internalGenerator,
 	// Group indices are 0-based, but index 0 is the whole expression.
 	index := regexp.Cap - 1
 
-	return &internalGenerator{regexp.String(), func() string {
-		return args.CaptureGroupHandler(index, regexp.Name, groupRegexp, generator, args)
+	return &internalGenerator{Name: regexp.String(), subs: []*internalGenerator{generator}, GenerateFunc: func(args *GeneratorArgs) string {
+		if !genArgs.customCaptureGroups {
+			return generator.GenerateFunc(args)
+		}
+		return handleCapture(groupArgs, index, regexp.Name, groupRegexp, generator, args)
 	}}, nil
 }
 
+// handleCapture calls the CaptureGroupHandler in handlerArgs for a capture group generated by sub. The handler is
+// passed sub and handlerArgs with the rng and provider states in args.
+func handleCapture(handlerArgs *GeneratorArgs, index int, name string, group *syntax.Regexp, sub Generator, args *GeneratorArgs) string {
+	handlerArgs = handlerArgs.withStateOf(args)
+	if inner, ok := sub.(*internalGenerator); ok {
+		sub = inner.withArgs(inner.args.withStateOf(args))
+	}
+	return handlerArgs.CaptureGroupHandler(index, name, group, sub, handlerArgs)
+}
+
 func defaultCaptureGroupHandler(index int, name string, group *syntax.Regexp, generator Generator, args *GeneratorArgs) string {
 	return generator.Generate()
 }
@@ -227,14 +323,20 @@ func enforceOp(r *syntax.Regexp, op syntax.Op) {
 // Return an error if r has 0 or more than 1 sub-expression.
 func enforceSingleSub(regexp *syntax.Regexp) error {
 	if len(regexp.Sub) != 1 {
-		return generatorError(nil,
+		return newError(ErrInvalidArgs, regexp,
 			"%s expected 1 sub-expression, but got %d: %s", opToString(regexp.Op), len(regexp.Sub), regexp)
 	}
 	return nil
 }
 
-func createCharClassGenerator(name string, charClass *tCharClass, args *GeneratorArgs) (*internalGenerator, error) {
-	return &internalGenerator{name, func() string {
+func createCharClassGenerator(regexp *syntax.Regexp, charClass *tCharClass, args *GeneratorArgs) (*internalGenerator, error) {
+	if args.Universe != nil {
+		var err error
+		if charClass, err = charClass.intersect(args.Universe); err != nil {
+			return nil, err.(*Error).at(regexp)
+		}
+	}
+	return &internalGenerator{Name: regexp.String(), charClass: charClass, GenerateFunc: func(args *GeneratorArgs) string {
 		i := args.rng.Int31n(charClass.TotalSize)
 		r := charClass.GetRuneAt(i)
 		return runesToString(r)
@@ -249,23 +351,31 @@ func createRepeatingGenerator(regexp *syntax.Regexp, genArgs *GeneratorArgs, min
 
 	generator, err := newGenerator(regexp.Sub[0], genArgs)
 	if err != nil {
-		return nil, generatorError(err, "failed to create generator for subexpression: /%s/", regexp)
+		return nil, err
 	}
 
 	if min == noBound {
 		min = int(genArgs.MinUnboundedRepeatCount)
 	}
-	if max == noBound {
+	unbounded := max == noBound
+	if unbounded {
 		max = int(genArgs.MaxUnboundedRepeatCount)
 	}
 
-	return &internalGenerator{regexp.String(), func() string {
-		n := min + genArgs.rng.Intn(max-min+1)
-
-		var result bytes.Buffer
-		for i := 0; i < n; i++ {
-			result.WriteString(generator.Generate())
-		}
-		return result.String()
-	}}, nil
+	return &internalGenerator{
+		Name: regexp.String(),
+		GenerateFunc: func(args *GeneratorArgs) string {
+			n := genArgs.repeatCount(args.rng, min, max)
+
+			var result bytes.Buffer
+			for i := 0; i < n; i++ {
+				result.WriteString(generator.GenerateFunc(args))
+			}
+			return result.String()
+		},
+		subs:      []*internalGenerator{generator},
+		min:       min,
+		max:       max,
+		unbounded: unbounded,
+	}, nil
 }
@@ -0,0 +1,104 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+)
+
+// constraintDFA returns a dfa that matches the same strings as a MustMatch or MustNotMatch pattern.
+func constraintDFA(pattern string, args *GeneratorArgs) (*dfa, error) {
+	pattern, err := args.Dialect.Translate(pattern)
+	if err != nil {
+		return nil, err
+	}
+	lookarounds, err := findLookarounds(pattern, args.Flags)
+	if err != nil {
+		return nil, err
+	}
+	automaton, err := patternDFA(pattern, lookarounds, args)
+	if err != nil {
+		return nil, locate(err, pattern)
+	}
+	return automaton, nil
+}
+
+// constraintError wraps an error in a MustMatch or MustNotMatch pattern, so it isn't reported as an error in the
+// pattern passed to NewGenerator.
+func constraintError(field, pattern string, err error) error {
+	kind := ErrParse
+	if regenErr, ok := err.(*Error); ok {
+		kind = regenErr.Kind
+	}
+	return &Error{Kind: kind, Offset: -1, Msg: fmt.Sprintf("%s pattern /%s/", field, pattern), Cause: err}
+}
+
+/*
+intersectionState is a state of the product of the automata for a pattern and its constraints. A string is accepted
+if every automaton that isn't negated accepts it, and no negated automaton does.
+*/
+type intersectionState struct {
+	automata []*dfa
+	negated  []bool
+	// The state of each automaton, or -1 if a negated automaton can no longer accept.
+	states []int
+}
+
+func (s *intersectionState) key() string {
+	var key bytes.Buffer
+	for _, state := range s.states {
+		key.WriteString(strconv.Itoa(state))
+		key.WriteByte(',')
+	}
+	return key.String()
+}
+
+func (s *intersectionState) accept() bool {
+	for i, automaton := range s.automata {
+		accept := s.states[i] >= 0 && automaton.states[s.states[i]].accept
+		if accept == s.negated[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *intersectionState) boundaries() []rune {
+	var result []rune
+	for i, automaton := range s.automata {
+		if s.states[i] >= 0 {
+			result = automaton.boundaries(s.states[i], result)
+		}
+	}
+	return result
+}
+
+func (s *intersectionState) next(r rune) automatonState {
+	next := &intersectionState{s.automata, s.negated, make([]int, len(s.states))}
+	for i, automaton := range s.automata {
+		next.states[i] = -1
+		if s.states[i] >= 0 {
+			next.states[i] = automaton.step(s.states[i], r)
+		}
+		if next.states[i] < 0 && !s.negated[i] {
+			return nil
+		}
+	}
+	return next
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"errors"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestIntersection(t *testing.T) {
+	t.Parallel()
+
+	Convey("MustMatch and MustNotMatch", t, func() {
+		Convey("Generates strings that satisfy all the patterns", func() {
+			gen, err := NewGenerator(`[a-z0-9_]{3,20}`, &GeneratorArgs{
+				MustMatch:    []string{`.*[0-9].*`},
+				MustNotMatch: []string{`admin.*`},
+			})
+			So(err, ShouldBeNil)
+			for i := 0; i < SampleSize; i++ {
+				value := gen.Generate()
+				So(regexp.MustCompile(`^[a-z0-9_]{3,20}$`).MatchString(value), ShouldBeTrue)
+				So(value, ShouldContainSubstringMatching, `[0-9]`)
+				So(strings.HasPrefix(value, "admin"), ShouldBeFalse)
+			}
+		})
+
+		Convey("Generates rare combinations", func() {
+			// Only one string in 26^5 satisfies both patterns.
+			gen, err := NewGenerator(`[a-z]{5}`, &GeneratorArgs{MustMatch: []string{`h.*`, `.*llo`, `.e.*`}})
+			So(err, ShouldBeNil)
+			So(gen.Generate(), ShouldEqual, "hello")
+
+			gen, err = NewGenerator(`[ab]{2}`, &GeneratorArgs{MustNotMatch: []string{`aa`, `b.`}})
+			So(err, ShouldBeNil)
+			values, err := gen.(ExtendedGenerator).GenerateUnique(1)
+			So(err, ShouldBeNil)
+			So(values, ShouldResemble, []string{"ab"})
+			_, err = gen.(ExtendedGenerator).GenerateUnique(2)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Supports lookarounds and dialects", func() {
+			gen, err := NewGenerator(`(?=.*\d)\w{4}`, &GeneratorArgs{
+				Flags:        syntax.Perl,
+				MustMatch:    []string{`(?!.*_).*`},
+				MustNotMatch: []string{`\d+`},
+			})
+			So(err, ShouldBeNil)
+			for i := 0; i < SampleSize; i++ {
+				value := gen.Generate()
+				So(value, ShouldHaveLength, 4)
+				So(value, ShouldContainSubstringMatching, `\d`)
+				So(value, ShouldContainSubstringMatching, `[a-zA-Z]`)
+				So(value, ShouldNotContainSubstring, "_")
+			}
+
+			gen, err = NewGenerator(`\d{3}`, &GeneratorArgs{Dialect: JavaScript, MustNotMatch: []string{`\u{30}.*`}})
+			So(err, ShouldBeNil)
+			for i := 0; i < SampleSize; i++ {
+				So(strings.HasPrefix(gen.Generate(), "0"), ShouldBeFalse)
+			}
+		})
+
+		Convey("Respects output length", func() {
+			gen, err := NewGenerator(`[a-c]+`, &GeneratorArgs{MustNotMatch: []string{`.*a.*`}, MaxOutputLength: 3})
+			So(err, ShouldBeNil)
+			values := make(map[string]bool)
+			for i := 0; i < SampleSize*10; i++ {
+				value := gen.Generate()
+				So(regexp.MustCompile(`^[bc]{1,3}$`).MatchString(value), ShouldBeTrue)
+				values[value] = true
+			}
+			So(len(values), ShouldEqual, 2+4+8)
+		})
+
+		Convey("Returns an error if the combination is empty", func() {
+			_, err := NewGenerator(`[a-z]+`, &GeneratorArgs{MustMatch: []string{`\d+`}, Flags: syntax.Perl})
+			So(errors.Is(err, ErrUnsatisfiable), ShouldBeTrue)
+
+			_, err = NewGenerator(`a|b`, &GeneratorArgs{MustNotMatch: []string{`a`, `b`}})
+			So(errors.Is(err, ErrUnsatisfiable), ShouldBeTrue)
+
+			_, err = NewGenerator(`a`, &GeneratorArgs{MustMatch: []string{`[^\x00-\x{10FFFF}]`}})
+			So(errors.Is(err, ErrUnsatisfiable), ShouldBeTrue)
+		})
+
+		Convey("Reports errors in the combined patterns", func() {
+			_, err := NewGenerator(`abc`, &GeneratorArgs{MustNotMatch: []string{`a(b`}})
+			So(errors.Is(err, ErrParse), ShouldBeTrue)
+			So(err.Error(), ShouldStartWith, "MustNotMatch pattern /a(b/: ")
+			var regenErr *Error
+			So(errors.As(err, &regenErr), ShouldBeTrue)
+			So(regenErr.Offset, ShouldEqual, -1)
+
+			_, err = NewGenerator(`abc`, &GeneratorArgs{MustMatch: []string{`a`}, OutputLengthUnit: Bytes})
+			So(errors.Is(err, ErrInvalidArgs), ShouldBeTrue)
+		})
+
+		Convey("Filters other methods", func() {
+			gen, err := NewGenerator(`[abc]`, &GeneratorArgs{MustNotMatch: []string{`b`}})
+			So(err, ShouldBeNil)
+			values, _ := gen.(ExtendedGenerator).GenerateCoverage(0)
+			So(values, ShouldNotContain, "b")
+			So(gen.Generate(), ShouldNotEqual, "b")
+		})
+	})
+}
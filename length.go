@@ -0,0 +1,224 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"fmt"
+	"regexp/syntax"
+	"unicode/utf8"
+)
+
+// LengthUnit is the unit used to measure the length of generated strings.
+type LengthUnit int
+
+const (
+	// Runes measures length in Unicode code points.
+	Runes LengthUnit = iota
+	// Bytes measures length in bytes of UTF-8.
+	Bytes
+)
+
+var lengthUnitNames = map[LengthUnit]string{
+	Runes: "Runes",
+	Bytes: "Bytes",
+}
+
+func (u LengthUnit) String() string {
+	if name, ok := lengthUnitNames[u]; ok {
+		return name
+	}
+	return fmt.Sprintf("LengthUnit(%d)", int(u))
+}
+
+// LengthOverflow is returned by ExtendedGenerator.MaxLength when the maximum length is too large to represent as
+// an int.
+const LengthOverflow = int(^uint(0) >> 1)
+
+// MinLength returns the length of the shortest string the generator can produce, taking MinOutputLength into
+// account. Capture groups are measured from their expressions, ignoring any CaptureGroupHandler or Providers.
+func (gen *internalGenerator) MinLength(unit LengthUnit) int {
+	length := gen.minLength(unit)
+	if lo, _ := gen.outputLengthRange(unit); length < lo {
+		length = lo
+	}
+	return length
+}
+
+// minLength returns the length of the shortest string gen's expression matches, ignoring the output length limits.
+func (gen *internalGenerator) minLength(unit LengthUnit) int {
+	switch {
+	case gen.automaton != nil:
+		return gen.automatonLength(unit, false)
+
+	case gen.charClass != nil:
+		if unit == Bytes {
+			return encodedRuneLen(gen.charClass.Ranges[0].Start)
+		}
+		return 1
+
+	case gen.regexp.Op == syntax.OpLiteral:
+		return literalLength(gen.regexp.Rune, unit)
+
+	case gen.regexp.Op == syntax.OpConcat, gen.regexp.Op == syntax.OpCapture:
+		length := 0
+		for _, sub := range gen.subs {
+			length = addLengths(length, sub.minLength(unit))
+		}
+		return length
+
+	case gen.regexp.Op == syntax.OpAlternate:
+		length := LengthOverflow
+		for _, sub := range gen.subs {
+			if subLength := sub.minLength(unit); subLength < length {
+				length = subLength
+			}
+		}
+		return length
+
+	case gen.isRepeat():
+		return multiplyLength(gen.subs[0].minLength(unit), gen.min)
+	}
+	return 0
+}
+
+// MaxLength returns the length of the longest string the generator can produce, taking MaxUnboundedRepeatCount
+// and MaxOutputLength into account. Returns LengthOverflow if the length overflows an int.
+// Capture groups are measured from their expressions, ignoring any CaptureGroupHandler or Providers.
+func (gen *internalGenerator) MaxLength(unit LengthUnit) int {
+	length := gen.maxLength(unit)
+	if _, hi := gen.outputLengthRange(unit); length > hi {
+		length = hi
+	}
+	return length
+}
+
+// maxLength returns the length of the longest string gen's expression matches, ignoring the output length limits.
+func (gen *internalGenerator) maxLength(unit LengthUnit) int {
+	switch {
+	case gen.automaton != nil:
+		return gen.automatonLength(unit, true)
+
+	case gen.charClass != nil:
+		if unit == Bytes {
+			ranges := gen.charClass.Ranges
+			last := ranges[len(ranges)-1]
+			return encodedRuneLen(last.Start + rune(last.Size-1))
+		}
+		return 1
+
+	case gen.regexp.Op == syntax.OpLiteral:
+		return literalLength(gen.regexp.Rune, unit)
+
+	case gen.regexp.Op == syntax.OpConcat, gen.regexp.Op == syntax.OpCapture:
+		length := 0
+		for _, sub := range gen.subs {
+			length = addLengths(length, sub.maxLength(unit))
+		}
+		return length
+
+	case gen.regexp.Op == syntax.OpAlternate:
+		length := 0
+		for _, sub := range gen.subs {
+			if subLength := sub.maxLength(unit); subLength > length {
+				length = subLength
+			}
+		}
+		return length
+
+	case gen.isRepeat():
+		return multiplyLength(gen.subs[0].maxLength(unit), gen.max)
+	}
+	return 0
+}
+
+// IsFinite returns true if the pattern matches a finite number of strings, or MaxOutputLength limits the strings
+// generated. If false, the pattern contains an unbounded repeat (e.g. "a*") that generates non-empty strings, so its
+// matches are only limited in length by MaxUnboundedRepeatCount.
+func (gen *internalGenerator) IsFinite() bool {
+	// Both engines only generate strings within MaxOutputLength.
+	if gen.args.MaxOutputLength > 0 && (gen.lengthConstrained || gen.automaton != nil) {
+		return true
+	}
+	if gen.automaton != nil {
+		return gen.automaton.dfa.isFinite()
+	}
+	if gen.unbounded && gen.subs[0].maxLength(Runes) > 0 {
+		return false
+	}
+	for _, sub := range gen.subs {
+		if !sub.IsFinite() {
+			return false
+		}
+	}
+	return true
+}
+
+// outputLengthRange returns the range of lengths, measured in unit, of strings within the output length limits, if
+// gen only generates strings within them, or [0, LengthOverflow] otherwise. The limits are in OutputLengthUnit, and
+// a rune is 1 to utf8.UTFMax bytes long.
+func (gen *internalGenerator) outputLengthRange(unit LengthUnit) (lo, hi int) {
+	if !gen.lengthConstrained {
+		return 0, LengthOverflow
+	}
+	lo, hi = gen.args.MinOutputLength, gen.args.MaxOutputLength
+	if hi == 0 {
+		hi = LengthOverflow
+	}
+	switch {
+	case unit == Bytes && gen.args.OutputLengthUnit == Runes:
+		hi = multiplyLength(hi, utf8.UTFMax)
+	case unit == Runes && gen.args.OutputLengthUnit == Bytes:
+		lo = (lo + utf8.UTFMax - 1) / utf8.UTFMax
+	}
+	return lo, hi
+}
+
+// encodedRuneLen returns the number of bytes used to write r to a string.
+func encodedRuneLen(r rune) int {
+	if n := utf8.RuneLen(r); n > 0 {
+		return n
+	}
+	// Invalid runes are written as utf8.RuneError.
+	return utf8.RuneLen(utf8.RuneError)
+}
+
+func literalLength(runes []rune, unit LengthUnit) int {
+	if unit == Bytes {
+		length := 0
+		for _, r := range runes {
+			length += encodedRuneLen(r)
+		}
+		return length
+	}
+	return len(runes)
+}
+
+// addLengths returns a+b, or LengthOverflow if it overflows.
+func addLengths(a, b int) int {
+	if a > LengthOverflow-b {
+		return LengthOverflow
+	}
+	return a + b
+}
+
+// multiplyLength returns length*count, or LengthOverflow if it overflows.
+func multiplyLength(length, count int) int {
+	if length != 0 && count > LengthOverflow/length {
+		return LengthOverflow
+	}
+	return length * count
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"testing"
+	"unicode/utf8"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLength(t *testing.T) {
+	t.Parallel()
+
+	Convey("MinLength and MaxLength", t, func() {
+		Convey("Measure runes", func() {
+			gen := newTestGenerator(`ab(cd|e)[0-9]{2,4}x?`, nil)
+			So(gen.MinLength(Runes), ShouldEqual, 5)
+			So(gen.MaxLength(Runes), ShouldEqual, 9)
+		})
+
+		Convey("Measure bytes", func() {
+			gen := newTestGenerator(`é[a-zé]`, nil)
+			So(gen.MinLength(Runes), ShouldEqual, 2)
+			So(gen.MaxLength(Runes), ShouldEqual, 2)
+			So(gen.MinLength(Bytes), ShouldEqual, 3)
+			So(gen.MaxLength(Bytes), ShouldEqual, 4)
+
+			gen = newTestGenerator(`(?s:.)`, nil)
+			So(gen.MinLength(Bytes), ShouldEqual, 1)
+			So(gen.MaxLength(Bytes), ShouldEqual, utf8.UTFMax)
+		})
+
+		Convey("Use unbounded repeat limits", func() {
+			gen := newTestGenerator(`a+b*`, &GeneratorArgs{MinUnboundedRepeatCount: 2, MaxUnboundedRepeatCount: 10})
+			// MinUnboundedRepeatCount doesn't apply to +.
+			So(gen.MinLength(Runes), ShouldEqual, 1+2)
+			So(gen.MaxLength(Runes), ShouldEqual, 10+10)
+
+			gen = newTestGenerator(`a*`, nil)
+			So(gen.MaxLength(Runes), ShouldEqual, DefaultMaxUnboundedRepeatCount)
+		})
+
+		Convey("Ignore assertions", func() {
+			gen := newTestGenerator(`^\bab$`, nil)
+			So(gen.MinLength(Runes), ShouldEqual, 2)
+			So(gen.MaxLength(Runes), ShouldEqual, 2)
+		})
+
+		Convey("Saturate on overflow", func() {
+			gen := newTestGenerator(`((((a*)*)*)*)*`, &GeneratorArgs{MaxUnboundedRepeatCount: 1 << 20})
+			So(gen.MaxLength(Runes), ShouldEqual, LengthOverflow)
+		})
+
+		Convey("Respect lookarounds and constraints", func() {
+			gen := newTestGenerator(`^(?=a{3})a*$`, nil)
+			So(gen.MinLength(Runes), ShouldEqual, 3)
+
+			gen = newTestGenerator(`(?=é)[aé]{1,2}`, nil)
+			So(gen.MinLength(Bytes), ShouldEqual, 2)
+			So(gen.MaxLength(Bytes), ShouldEqual, 4)
+
+			gen = newTestGenerator(`[a-z]{2,4}`, &GeneratorArgs{MustNotMatch: []string{`.{3,}`}})
+			So(gen.MaxLength(Runes), ShouldEqual, 2)
+		})
+
+		Convey("Respect output length limits on both engines", func() {
+			for _, engine := range []Engine{TreeEngine, AutomatonEngine} {
+				gen := newTestGenerator(`[a-z]+`, &GeneratorArgs{MinOutputLength: 3, MaxOutputLength: 10, Engine: engine})
+				So(gen.MinLength(Runes), ShouldEqual, 3)
+				So(gen.MaxLength(Runes), ShouldEqual, 10)
+				So(gen.IsFinite(), ShouldBeTrue)
+			}
+
+			gen := newTestGenerator(`[a-z]+`, &GeneratorArgs{MinOutputLength: 3})
+			So(gen.MinLength(Runes), ShouldEqual, 3)
+			So(gen.IsFinite(), ShouldBeFalse)
+		})
+
+		Convey("Bound generated strings", func() {
+			gen := newTestGenerator(`[a-z]{1,3}(é|ü+)?`, &GeneratorArgs{MaxUnboundedRepeatCount: 5})
+			for i := 0; i < SampleSize; i++ {
+				value := gen.Generate()
+				So(len(value), ShouldBeBetweenOrEqual, gen.MinLength(Bytes), gen.MaxLength(Bytes))
+				So(utf8.RuneCountInString(value), ShouldBeBetweenOrEqual, gen.MinLength(Runes), gen.MaxLength(Runes))
+			}
+		})
+	})
+
+	Convey("IsFinite", t, func() {
+		So(newTestGenerator(`a{1,100}(b|c)?`, nil).IsFinite(), ShouldBeTrue)
+		So(newTestGenerator(`a+`, nil).IsFinite(), ShouldBeFalse)
+		So(newTestGenerator(`x(a|b*)`, nil).IsFinite(), ShouldBeFalse)
+		So(newTestGenerator(`(^$)*`, nil).IsFinite(), ShouldBeTrue)
+		So(newTestGenerator(`a*`, &GeneratorArgs{MustNotMatch: []string{`aaa.*`}}).IsFinite(), ShouldBeTrue)
+		So(newTestGenerator(`(?=a)a*`, nil).IsFinite(), ShouldBeFalse)
+		So(newTestGenerator(`a+`, &GeneratorArgs{MaxOutputLength: 5}).IsFinite(), ShouldBeTrue)
+	})
+}
@@ -0,0 +1,544 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"bytes"
+	"regexp/syntax"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+/*
+regexp/syntax can't parse lookarounds, so NewGenerator removes them from the pattern before parsing it. Each
+lookaround is replaced by a marker rune, and the automaton for the marked pattern is intersected with one
+automaton per lookaround that checks the text after (or before) each of its markers. The markers are then
+removed, leaving an automaton that matches exactly the strings that satisfy the pattern and all its lookarounds.
+
+Methods that analyze the pattern, such as Tree, MinLength and Entropy, use a generator for the pattern without
+its lookarounds. GenerateUnique, GenerateLength, GenerateCoverage and GenerateCombinations only return strings
+that satisfy the lookarounds.
+*/
+
+type lookaroundKind int
+
+const (
+	lookahead lookaroundKind = iota
+	negativeLookahead
+	lookbehind
+	negativeLookbehind
+)
+
+var lookaroundPrefixes = map[lookaroundKind]string{
+	lookahead:          "(?=",
+	negativeLookahead:  "(?!",
+	lookbehind:         "(?<=",
+	negativeLookbehind: "(?<!",
+}
+
+func (kind lookaroundKind) ahead() bool {
+	return kind == lookahead || kind == negativeLookahead
+}
+
+func (kind lookaroundKind) negative() bool {
+	return kind == negativeLookahead || kind == negativeLookbehind
+}
+
+// lookaround is a lookaround found in a pattern.
+type lookaround struct {
+	kind lookaroundKind
+	// Byte offsets of the lookaround in the pattern, including its parentheses.
+	start, end int
+	body       string
+}
+
+// Lookarounds are replaced by runes from the Supplementary Private Use Area-A, which patterns are unlikely to use.
+const (
+	lookaroundMarkerBase = 0xf0000
+	maxLookarounds       = 0xfffe
+)
+
+// findLookarounds returns the lookarounds in pattern, in order. Literal patterns have no lookarounds.
+// Returns an error if a lookaround contains another lookaround.
+func findLookarounds(pattern string, flags syntax.Flags) ([]lookaround, error) {
+	if flags&syntax.Literal != 0 {
+		return nil, nil
+	}
+	var result []lookaround
+
+	type group struct {
+		start int
+		kind  lookaroundKind
+		// True if the group is a lookaround, and if it's inside another one.
+		lookaround, nested bool
+	}
+	var groups []group
+	depth := 0 // The number of lookarounds in groups.
+
+	for i := 0; i < len(pattern); {
+		switch pattern[i] {
+		case '\\':
+			if flags&syntax.PerlX != 0 && strings.HasPrefix(pattern[i:], `\Q`) {
+				end := strings.Index(pattern[i+2:], `\E`)
+				if end < 0 {
+					return result, nil
+				}
+				i += 2 + end + 2
+				continue
+			}
+			i += 2
+
+		case '[':
+			i = skipCharClass(pattern, i)
+
+		case '(':
+			g := group{start: i}
+			for kind, prefix := range lookaroundPrefixes {
+				if strings.HasPrefix(pattern[i:], prefix) {
+					g.kind, g.lookaround = kind, true
+				}
+			}
+			if g.lookaround {
+				g.nested = depth > 0
+				depth++
+			}
+			groups = append(groups, g)
+			i++
+
+		case ')':
+			if len(groups) > 0 {
+				g := groups[len(groups)-1]
+				groups = groups[:len(groups)-1]
+				if g.nested {
+					return nil, &Error{Kind: ErrUnsupportedOp, Expr: pattern[g.start : i+1], Offset: g.start,
+						Msg: "nested lookarounds are not supported"}
+				}
+				if g.lookaround {
+					depth--
+					result = append(result, lookaround{
+						kind:  g.kind,
+						start: g.start,
+						end:   i + 1,
+						body:  pattern[g.start+len(lookaroundPrefixes[g.kind]) : i],
+					})
+				}
+			}
+			i++
+
+		default:
+			i++
+		}
+	}
+	return result, nil
+}
+
+// skipCharClass returns the offset after the character class starting at pattern[start].
+func skipCharClass(pattern string, start int) int {
+	i := start + 1
+	if i < len(pattern) && pattern[i] == '^' {
+		i++
+	}
+	// A ] at the start of a class is a literal.
+	if i < len(pattern) && pattern[i] == ']' {
+		i++
+	}
+	for i < len(pattern) {
+		switch {
+		case strings.HasPrefix(pattern[i:], "[:"):
+			if end := strings.Index(pattern[i+2:], ":]"); end >= 0 {
+				i += 2 + end + 2
+				continue
+			}
+			i++
+		case pattern[i] == '\\':
+			i += 2
+		case pattern[i] == ']':
+			return i + 1
+		default:
+			i++
+		}
+	}
+	return i
+}
+
+// removeLookarounds returns pattern with its lookarounds replaced by empty groups.
+func removeLookarounds(pattern string, lookarounds []lookaround) string {
+	var result bytes.Buffer
+	last := 0
+	for _, l := range lookarounds {
+		result.WriteString(pattern[last:l.start])
+		result.WriteString("(?:)")
+		last = l.end
+	}
+	result.WriteString(pattern[last:])
+	return result.String()
+}
+
+// lookaroundDFA returns a dfa that matches the strings that match pattern and satisfy its lookarounds.
+func lookaroundDFA(pattern string, lookarounds []lookaround, args *GeneratorArgs) (*dfa, error) {
+	if len(lookarounds) > maxLookarounds {
+		return nil, newError(ErrUnsupportedOp, nil, "too many lookarounds: %d", len(lookarounds))
+	}
+	markers := runeInterval{lookaroundMarkerBase, lookaroundMarkerBase + rune(len(lookarounds)) - 1}
+	for i, r := range pattern {
+		if r >= lookaroundMarkerBase && r <= lookaroundMarkerBase+maxLookarounds {
+			return nil, &Error{Kind: ErrUnsupportedOp, Expr: string(r), Offset: i,
+				Msg: "patterns with lookarounds can't contain runes from U+F0000 to U+FFFFE"}
+		}
+	}
+
+	var marked bytes.Buffer
+	last := 0
+	for i, l := range lookarounds {
+		marked.WriteString(pattern[last:l.start])
+		marked.WriteRune(markers.lo + rune(i))
+		last = l.end
+	}
+	marked.WriteString(pattern[last:])
+
+	regexp, err := syntax.Parse(marked.String(), args.Flags)
+	if err != nil {
+		return nil, parseError(err)
+	}
+	main, err := compileDFA(excludeRunes(regexp, markers), markers, args.alphabet)
+	if err != nil {
+		return nil, err
+	}
+
+	constraints := make([]*lookaroundConstraint, len(lookarounds))
+	for i, l := range lookarounds {
+		body, err := syntax.Parse(l.body, args.Flags)
+		if err != nil {
+			err = locate(parseError(err), l.body)
+			if bodyErr, ok := err.(*Error); ok && bodyErr.Offset >= 0 {
+				bodyErr.Offset += l.start + len(lookaroundPrefixes[l.kind])
+			}
+			return nil, err
+		}
+
+		// Check the text after the marker starts with the body, or the text before the marker ends with it.
+		anything := &syntax.Regexp{Op: syntax.OpStar, Sub: []*syntax.Regexp{{Op: syntax.OpAnyChar}}}
+		body = allowRunes(excludeRunes(body, markers), markers)
+		check := &syntax.Regexp{Op: syntax.OpConcat, Sub: []*syntax.Regexp{body, anything}}
+		if !l.kind.ahead() {
+			check.Sub[0], check.Sub[1] = anything, body
+		}
+
+		automaton, err := compileDFA(check, markers, args.alphabet)
+		if err != nil {
+			return nil, err
+		}
+		constraints[i] = &lookaroundConstraint{l.kind, markers.lo + rune(i), automaton}
+	}
+
+	// Lookbehinds check the whole text, so their runs start at the beginning of the text.
+	start := &lookaroundState{main, constraints, 0, make([][]int, len(constraints))}
+	for i, c := range constraints {
+		if !c.kind.ahead() && len(c.automaton.states) > 0 {
+			start.runs[i] = []int{0}
+		}
+	}
+	if len(main.states) == 0 {
+		return nil, newError(ErrUnsatisfiable, nil, "/%s/ doesn't match any strings", pattern)
+	}
+	product, err := explore(start, args.alphabet)
+	if err != nil {
+		return nil, err
+	}
+	if len(product.states) == 0 {
+		return nil, newError(ErrUnsatisfiable, nil, "no strings match /%s/ and satisfy its lookarounds", pattern)
+	}
+	return explore(newMarkerFreeState(product, markers, []int{0}), args.alphabet)
+}
+
+// excludeRunes returns a copy of re whose character classes don't contain the runes in excluded.
+func excludeRunes(re *syntax.Regexp, excluded runeInterval) *syntax.Regexp {
+	re = re.Simplify()
+	copied := *re
+	switch re.Op {
+	case syntax.OpAnyChar:
+		copied.Op = syntax.OpCharClass
+		copied.Rune = []rune{0, unicode.MaxRune}
+	case syntax.OpAnyCharNotNL:
+		copied.Op = syntax.OpCharClass
+		copied.Rune = []rune{0, '\n' - 1, '\n' + 1, unicode.MaxRune}
+	}
+
+	if copied.Op == syntax.OpCharClass {
+		var runes []rune
+		for i := 0; i+1 < len(copied.Rune); i += 2 {
+			lo, hi := copied.Rune[i], copied.Rune[i+1]
+			if lo < excluded.lo {
+				runes = append(runes, lo, minRune(hi, excluded.lo-1))
+			}
+			if hi > excluded.hi {
+				runes = append(runes, maxRune(lo, excluded.hi+1), hi)
+			}
+		}
+		copied.Rune = runes
+		if len(runes) == 0 {
+			copied.Op = syntax.OpNoMatch
+		}
+	}
+
+	copied.Sub = make([]*syntax.Regexp, len(re.Sub))
+	for i, sub := range re.Sub {
+		copied.Sub[i] = excludeRunes(sub, excluded)
+	}
+	return &copied
+}
+
+// allowRunes returns a copy of re that also matches strings with runes from allowed inserted anywhere.
+func allowRunes(re *syntax.Regexp, allowed runeInterval) *syntax.Regexp {
+	class := &syntax.Regexp{Op: syntax.OpCharClass, Rune: []rune{allowed.lo, allowed.hi}}
+	any := &syntax.Regexp{Op: syntax.OpStar, Sub: []*syntax.Regexp{class}}
+
+	var allow func(re *syntax.Regexp) *syntax.Regexp
+	allow = func(re *syntax.Regexp) *syntax.Regexp {
+		switch re.Op {
+		case syntax.OpLiteral:
+			result := &syntax.Regexp{Op: syntax.OpConcat}
+			for _, r := range re.Rune {
+				literal := &syntax.Regexp{Op: syntax.OpLiteral, Flags: re.Flags, Rune: []rune{r}}
+				result.Sub = append(result.Sub, any, literal)
+			}
+			return result
+		case syntax.OpCharClass, syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+			return &syntax.Regexp{Op: syntax.OpConcat, Sub: []*syntax.Regexp{any, re}}
+		}
+
+		copied := *re
+		copied.Sub = make([]*syntax.Regexp, len(re.Sub))
+		for i, sub := range re.Sub {
+			copied.Sub[i] = allow(sub)
+		}
+		return &copied
+	}
+	return &syntax.Regexp{Op: syntax.OpConcat, Sub: []*syntax.Regexp{allow(re), any}}
+}
+
+func minRune(a, b rune) rune {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxRune(a, b rune) rune {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// lookaroundConstraint checks one lookaround at each occurrence of its marker.
+type lookaroundConstraint struct {
+	kind   lookaroundKind
+	marker rune
+	// For lookaheads, matches strings that start with the body. For lookbehinds, matches strings that end with it.
+	automaton *dfa
+}
+
+/*
+lookaroundState is a state of the intersection of the automaton for the marked pattern with the constraints.
+
+A lookbehind runs its automaton over the whole text, and checks whether it accepts at each marker. A lookahead
+starts a new run of its automaton (an obligation) after each marker, and checks whether the runs accept at the
+end of the text.
+*/
+type lookaroundState struct {
+	main        *dfa
+	constraints []*lookaroundConstraint
+	state       int
+	// For lookaheads, the sorted states of the obligations. For lookbehinds, the state of the single run, or
+	// nothing if it's dead.
+	runs [][]int
+}
+
+func (s *lookaroundState) key() string {
+	var key bytes.Buffer
+	key.WriteString(strconv.Itoa(s.state))
+	for _, runs := range s.runs {
+		key.WriteByte(';')
+		for _, run := range runs {
+			key.WriteString(strconv.Itoa(run))
+			key.WriteByte(',')
+		}
+	}
+	return key.String()
+}
+
+func (s *lookaroundState) accept() bool {
+	if !s.main.states[s.state].accept {
+		return false
+	}
+	for i, c := range s.constraints {
+		if !c.kind.ahead() {
+			continue
+		}
+		for _, run := range s.runs[i] {
+			if c.automaton.states[run].accept == c.kind.negative() {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (s *lookaroundState) boundaries() []rune {
+	result := s.main.boundaries(s.state, nil)
+	for i, c := range s.constraints {
+		result = append(result, c.marker, c.marker+1)
+		for _, run := range s.runs[i] {
+			result = c.automaton.boundaries(run, result)
+		}
+	}
+	return result
+}
+
+func (s *lookaroundState) next(r rune) automatonState {
+	next := &lookaroundState{s.main, s.constraints, s.main.step(s.state, r), make([][]int, len(s.runs))}
+	if next.state < 0 {
+		return nil
+	}
+
+	for i, c := range s.constraints {
+		if !c.kind.ahead() {
+			runs := s.runs[i]
+			if r == c.marker {
+				accepted := len(runs) > 0 && c.automaton.states[runs[0]].accept
+				if accepted == c.kind.negative() {
+					return nil
+				}
+			}
+			if len(runs) > 0 {
+				if run := c.automaton.step(runs[0], r); run >= 0 {
+					next.runs[i] = []int{run}
+				}
+			}
+			continue
+		}
+
+		var runs []int
+		for _, run := range s.runs[i] {
+			run = c.automaton.step(run, r)
+			if run < 0 {
+				if c.kind.negative() {
+					// The body can't match any more, so the negative lookahead is satisfied.
+					continue
+				}
+				return nil
+			}
+			runs = append(runs, run)
+		}
+		if r == c.marker {
+			if len(c.automaton.states) == 0 {
+				if !c.kind.negative() {
+					return nil
+				}
+			} else {
+				runs = append(runs, 0)
+			}
+		}
+		next.runs[i] = uniqueSortedInts(runs)
+	}
+	return next
+}
+
+func uniqueSortedInts(values []int) []int {
+	sort.Ints(values)
+	var result []int
+	for i, value := range values {
+		if i == 0 || value != values[i-1] {
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+// markerFreeState is a set of states of an automaton with the transitions on markers removed, by treating them as
+// empty transitions.
+type markerFreeState struct {
+	automaton *dfa
+	markers   runeInterval
+	states    []int
+}
+
+func newMarkerFreeState(automaton *dfa, markers runeInterval, states []int) *markerFreeState {
+	// Follow transitions on markers.
+	seen := make(map[int]bool)
+	var closure []int
+	for len(states) > 0 {
+		state := states[len(states)-1]
+		states = states[:len(states)-1]
+		if seen[state] {
+			continue
+		}
+		seen[state] = true
+		closure = append(closure, state)
+		for _, t := range automaton.states[state].transitions {
+			if t.hi >= markers.lo && t.lo <= markers.hi {
+				states = append(states, t.to)
+			}
+		}
+	}
+	return &markerFreeState{automaton, markers, uniqueSortedInts(closure)}
+}
+
+func (s *markerFreeState) key() string {
+	var key bytes.Buffer
+	for _, state := range s.states {
+		key.WriteString(strconv.Itoa(state))
+		key.WriteByte(',')
+	}
+	return key.String()
+}
+
+func (s *markerFreeState) accept() bool {
+	for _, state := range s.states {
+		if s.automaton.states[state].accept {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *markerFreeState) boundaries() []rune {
+	result := []rune{s.markers.lo, s.markers.hi + 1}
+	for _, state := range s.states {
+		result = s.automaton.boundaries(state, result)
+	}
+	return result
+}
+
+func (s *markerFreeState) next(r rune) automatonState {
+	if r >= s.markers.lo && r <= s.markers.hi {
+		return nil
+	}
+	var next []int
+	for _, state := range s.states {
+		if state = s.automaton.step(state, r); state >= 0 {
+			next = append(next, state)
+		}
+	}
+	if len(next) == 0 {
+		return nil
+	}
+	return newMarkerFreeState(s.automaton, s.markers, next)
+}
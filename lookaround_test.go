@@ -0,0 +1,142 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"errors"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLookarounds(t *testing.T) {
+	t.Parallel()
+
+	Convey("Lookarounds", t, func() {
+		Convey("Password rules", func() {
+			gen := newTestGenerator(`^(?=.*[A-Z])(?=.*\d)(?!.*password)\w{8,16}$`, nil)
+			for i := 0; i < SampleSize; i++ {
+				value := gen.Generate()
+				So(regexp.MustCompile(`^\w{8,16}$`).MatchString(value), ShouldBeTrue)
+				So(value, ShouldContainSubstringMatching, `[A-Z]`)
+				So(value, ShouldContainSubstringMatching, `\d`)
+				So(value, ShouldNotContainSubstring, "password")
+			}
+		})
+
+		Convey("Negative lookahead", func() {
+			gen := newTestGenerator(`(?!ab)[ab]{2}`, nil)
+			values, err := gen.GenerateUnique(3)
+			So(err, ShouldBeNil)
+			sort.Strings(values)
+			So(values, ShouldResemble, []string{"aa", "ba", "bb"})
+
+			_, err = gen.GenerateUnique(4)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Lookbehind", func() {
+			gen := newTestGenerator(`\w(?<=[aeiou])\d`, nil)
+			for i := 0; i < SampleSize; i++ {
+				So(regexp.MustCompile(`^[aeiou]\d$`).MatchString(gen.Generate()), ShouldBeTrue)
+			}
+
+			gen = newTestGenerator(`[ab](?<!a)c`, nil)
+			for i := 0; i < SampleSize; i++ {
+				So(gen.Generate(), ShouldEqual, "bc")
+			}
+		})
+
+		Convey("Lookarounds in repeats", func() {
+			gen := newTestGenerator(`(?:(?=[a-c])\w)+`, &GeneratorArgs{Flags: syntax.Perl, MaxUnboundedRepeatCount: 10})
+			for i := 0; i < SampleSize; i++ {
+				So(regexp.MustCompile(`^[a-c]{1,10}$`).MatchString(gen.Generate()), ShouldBeTrue)
+			}
+		})
+
+		Convey("Lookarounds next to each other", func() {
+			gen := newTestGenerator(`x(?<=x)(?=y)(?<!z)y`, nil)
+			So(gen.Generate(), ShouldEqual, "xy")
+		})
+
+		Convey("Respects output length", func() {
+			gen := newTestGenerator(`(?=.*\d)[a-z0-9]+`, &GeneratorArgs{Flags: syntax.Perl, MinOutputLength: 3, MaxOutputLength: 5})
+			for i := 0; i < SampleSize; i++ {
+				value := gen.Generate()
+				So(utf8.RuneCountInString(value), ShouldBeBetweenOrEqual, 3, 5)
+				So(value, ShouldContainSubstringMatching, `\d`)
+			}
+
+			value, err := gen.GenerateLength(20)
+			So(err, ShouldBeNil)
+			So(value, ShouldHaveLength, 20)
+			So(value, ShouldContainSubstringMatching, `\d`)
+		})
+
+		Convey("Ignores parentheses in classes and escapes", func() {
+			gen := newTestGenerator(`[(]\(\?=x`, nil)
+			So(gen.Generate(), ShouldEqual, "((?=x")
+
+			gen = newTestGenerator(`[(?=]`, nil)
+			So("(?=", ShouldContainSubstring, gen.Generate())
+		})
+
+		Convey("Ignores lookarounds in literal patterns", func() {
+			gen, err := NewGenerator(`(?=x)`, &GeneratorArgs{Flags: syntax.Literal})
+			So(err, ShouldBeNil)
+			So(gen.Generate(), ShouldEqual, "(?=x)")
+		})
+
+		Convey("Analyzes the pattern without lookarounds", func() {
+			gen := newTestGenerator(`(?=a)[ab]{2}`, nil)
+			So(gen.MinLength(Runes), ShouldEqual, 2)
+			So(gen.String(), ShouldEqual, `(?=a)[ab]{2}`)
+		})
+
+		Convey("Returns errors", func() {
+			_, err := NewGenerator(`(?=a)b`, &GeneratorArgs{Flags: syntax.Perl})
+			So(errors.Is(err, ErrUnsatisfiable), ShouldBeTrue)
+
+			_, err = NewGenerator(`a(?=(?<=a)b)b`, &GeneratorArgs{Flags: syntax.Perl})
+			So(errors.Is(err, ErrUnsupportedOp), ShouldBeTrue)
+			var regenErr *Error
+			So(errors.As(err, &regenErr), ShouldBeTrue)
+			So(regenErr.Offset, ShouldEqual, 4)
+			So(regenErr.Expr, ShouldEqual, `(?<=a)`)
+
+			_, err = NewGenerator(`xyzxyz(?=a{2,1})b`, &GeneratorArgs{Flags: syntax.Perl})
+			So(errors.Is(err, ErrParse), ShouldBeTrue)
+			So(errors.As(err, &regenErr), ShouldBeTrue)
+			So(regenErr.Offset, ShouldEqual, 10)
+
+			_, err = NewGenerator(`(?=a(b)a`, &GeneratorArgs{Flags: syntax.Perl})
+			So(errors.Is(err, ErrParse), ShouldBeTrue)
+		})
+	})
+}
+
+func ShouldContainSubstringMatching(actual interface{}, expected ...interface{}) string {
+	if regexp.MustCompile(expected[0].(string)).MatchString(actual.(string)) {
+		return ""
+	}
+	return "Expected " + strings.TrimSpace(actual.(string)) + " to contain a match for " + expected[0].(string)
+}
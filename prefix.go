@@ -0,0 +1,140 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+/*
+GenerateWithPrefix returns a random string that starts with prefix and matches the whole pattern, or an error if
+there is no such string.
+
+Completions are generated from an automaton for the pattern, like AutomatonEngine: the length of the completion
+is chosen uniformly, then a completion of that length uniformly. The result is no longer than MaxLength(Runes),
+and is within MinOutputLength and MaxOutputLength if OutputLengthUnit is Runes. CaptureGroupHandler and
+Providers are ignored.
+*/
+func (gen *internalGenerator) GenerateWithPrefix(prefix string) (string, error) {
+	sampler, state, err := gen.completionState(prefix)
+	if err != nil {
+		return "", err
+	}
+
+	var lengths []int
+	lo, hi := gen.completionLengths(prefix, sampler)
+	for n := lo; n <= hi; n++ {
+		if sampler.canGenerateFrom(state, n) {
+			lengths = append(lengths, n)
+		}
+	}
+	if len(lengths) == 0 {
+		return "", newError(ErrUnsatisfiable, nil, "/%s/ has no completions of %q within the length limits", gen, prefix)
+	}
+
+	return prefix + sampler.generateFrom(gen.args.rng, state, lengths[gen.args.rng.Intn(len(lengths))]), nil
+}
+
+/*
+Complete returns up to n of the shortest strings that can be appended to partial to match the whole pattern, in
+order of length, or an error if there are none. The empty string is returned first if partial already matches.
+
+Every distinct rune that can come next is listed, e.g. "b", "c" and "d" for "a(b|c|d)" after "a". Where many
+runes would do, letters and digits are listed first, so completions are suitable as autocomplete suggestions. Use
+GenerateWithPrefix to sample random completions. Completions respect the same length limits as GenerateWithPrefix.
+*/
+func (gen *internalGenerator) Complete(partial string, n int) ([]string, error) {
+	if n <= 0 {
+		return nil, newError(ErrInvalidArgs, nil, "invalid number of completions %d", n)
+	}
+	sampler, state, err := gen.completionState(partial)
+	if err != nil {
+		return nil, err
+	}
+
+	var completions []string
+	lo, hi := gen.completionLengths(partial, sampler)
+	for length := lo; length <= hi && len(completions) < n; length++ {
+		if sampler.canGenerateFrom(state, length) {
+			completions = sampler.enumerate(completions, state, length, nil, n, true)
+		}
+	}
+	if len(completions) == 0 {
+		return nil, newError(ErrUnsatisfiable, nil, "/%s/ has no completions of %q within the length limits", gen, partial)
+	}
+	return completions, nil
+}
+
+// completionState returns the automaton for completing strings, and its state after prefix.
+func (gen *internalGenerator) completionState(prefix string) (*dfaSampler, int, error) {
+	completer, err := gen.completer()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	state := -1
+	if len(completer.dfa.states) > 0 {
+		state = 0
+		for _, r := range prefix {
+			if state = completer.dfa.step(state, r); state < 0 {
+				break
+			}
+		}
+	}
+	if state < 0 {
+		return nil, 0, newError(ErrUnsatisfiable, nil, "no strings matching /%s/ start with %q", gen, prefix)
+	}
+	return completer, state, nil
+}
+
+// completer returns the automaton for completing strings, compiling it the first time it's needed.
+func (gen *internalGenerator) completer() (*dfaSampler, error) {
+	gen.tables.lock.Lock()
+	defer gen.tables.lock.Unlock()
+
+	if gen.tables.completer == nil {
+		gen.tables.completer = gen.automaton
+	}
+	if gen.tables.completer == nil {
+		automaton, err := compileDFA(gen.regexp, runeInterval{}, gen.args.alphabet)
+		if err != nil {
+			return nil, err
+		}
+		gen.tables.completer = newDFASampler(automaton)
+	}
+	return gen.tables.completer, nil
+}
+
+// completionLengths returns the bounds on the length of completions of prefix.
+func (gen *internalGenerator) completionLengths(prefix string, sampler *dfaSampler) (lo, hi int) {
+	hi = gen.MaxLength(Runes)
+	if gen.args.OutputLengthUnit == Runes {
+		lo = gen.args.MinOutputLength
+		if gen.args.MaxOutputLength > 0 && gen.args.MaxOutputLength < hi {
+			hi = gen.args.MaxOutputLength
+		}
+	}
+	if limit := maxAutomatonTableSize / len(sampler.dfa.states); hi > limit {
+		hi = limit
+	}
+
+	length := 0
+	for range prefix {
+		length++
+	}
+	lo, hi = lo-length, hi-length
+	if lo < 0 {
+		lo = 0
+	}
+	return lo, hi
+}
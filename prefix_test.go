@@ -0,0 +1,115 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCompletion(t *testing.T) {
+	t.Parallel()
+
+	Convey("GenerateWithPrefix", t, func() {
+		Convey("Generates matching strings with the prefix", func() {
+			gen, err := NewGenerator(`(foo|bar)-[a-z]+[0-9]`, nil)
+			So(err, ShouldBeNil)
+			matches := regexp.MustCompile(`^(foo|bar)-[a-z]+\d$`)
+			for _, prefix := range []string{"", "b", "foo-", "bar-xy"} {
+				for i := 0; i < SampleSize; i++ {
+					value, err := gen.(ExtendedGenerator).GenerateWithPrefix(prefix)
+					So(err, ShouldBeNil)
+					So(strings.HasPrefix(value, prefix), ShouldBeTrue)
+					So(matches.MatchString(value), ShouldBeTrue)
+				}
+			}
+		})
+
+		Convey("Respects length limits", func() {
+			gen, err := NewGenerator(`a*`, &GeneratorArgs{MinOutputLength: 3, MaxOutputLength: 5})
+			So(err, ShouldBeNil)
+			for i := 0; i < SampleSize; i++ {
+				value, err := gen.(ExtendedGenerator).GenerateWithPrefix("aa")
+				So(err, ShouldBeNil)
+				So(len(value), ShouldBeBetweenOrEqual, 3, 5)
+			}
+
+			_, err = gen.(ExtendedGenerator).GenerateWithPrefix("aaaaaa")
+			So(errors.Is(err, ErrUnsatisfiable), ShouldBeTrue)
+		})
+
+		Convey("Respects constraints", func() {
+			gen, err := NewGenerator(`[ab]{3}`, &GeneratorArgs{MustNotMatch: []string{`.*b.*`}})
+			So(err, ShouldBeNil)
+			value, err := gen.(ExtendedGenerator).GenerateWithPrefix("a")
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, "aaa")
+		})
+
+		Convey("Returns an error when there is no completion", func() {
+			gen, err := NewGenerator(`foo[0-9]`, nil)
+			So(err, ShouldBeNil)
+			_, err = gen.(ExtendedGenerator).GenerateWithPrefix("fox")
+			So(errors.Is(err, ErrUnsatisfiable), ShouldBeTrue)
+		})
+	})
+
+	Convey("Complete", t, func() {
+		Convey("Lists the shortest completions", func() {
+			gen, err := NewGenerator(`colou?r|cold|[0-9]`, nil)
+			So(err, ShouldBeNil)
+			completions, err := gen.(ExtendedGenerator).Complete("col", 10)
+			So(err, ShouldBeNil)
+			So(completions, ShouldResemble, []string{"d", "or", "our"})
+
+			completions, err = gen.(ExtendedGenerator).Complete("color", 10)
+			So(err, ShouldBeNil)
+			So(completions, ShouldResemble, []string{""})
+
+			completions, err = gen.(ExtendedGenerator).Complete("", 2)
+			So(err, ShouldBeNil)
+			So(completions, ShouldResemble, []string{"0", "1"})
+		})
+
+		Convey("Lists every rune that can come next", func() {
+			gen, err := NewGenerator(`a(b|c|d)`, nil)
+			So(err, ShouldBeNil)
+			completions, err := gen.(ExtendedGenerator).Complete("a", 10)
+			So(err, ShouldBeNil)
+			So(completions, ShouldResemble, []string{"b", "c", "d"})
+
+			gen, err = NewGenerator(`x[^a]`, nil)
+			So(err, ShouldBeNil)
+			completions, err = gen.(ExtendedGenerator).Complete("x", 3)
+			So(err, ShouldBeNil)
+			So(completions, ShouldResemble, []string{"b", "c", "d"})
+		})
+
+		Convey("Returns errors", func() {
+			gen, err := NewGenerator(`abc`, nil)
+			So(err, ShouldBeNil)
+			_, err = gen.(ExtendedGenerator).Complete("b", 1)
+			So(errors.Is(err, ErrUnsatisfiable), ShouldBeTrue)
+			_, err = gen.(ExtendedGenerator).Complete("a", 0)
+			So(errors.Is(err, ErrInvalidArgs), ShouldBeTrue)
+		})
+	})
+}
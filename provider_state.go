@@ -0,0 +1,252 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"fmt"
+	"regexp/syntax"
+	"sync"
+)
+
+// ProviderState is the state of a stateful provider for a single capture group of a single generator.
+type ProviderState interface {
+	// Next returns the next value for the group and advances the state, or an error if there are no more values.
+	Next(args *GeneratorArgs) (string, error)
+
+	// Clone returns an independent copy of the state. Used to save and restore generator state.
+	Clone() ProviderState
+}
+
+/*
+Stateful returns a Provider that keeps state across calls to Generate.
+
+newState is called the first time each capture group is generated to create the group's initial state. If it
+returns an error, the provider returns the error, and newState is called again the next time the group is generated.
+Each generator has its own state for each group, so e.g. a counter used by two groups, or by two generators
+created from the same pattern, will count independently.
+
+Calls to Next are serialized, so states don't need to be safe for concurrent use.
+*/
+func Stateful(newState func(group *syntax.Regexp, args *GeneratorArgs) (ProviderState, error)) Provider {
+	// Only used for its address, which identifies the provider in the generator state.
+	id := new(byte)
+
+	return func(group *syntax.Regexp, args *GeneratorArgs) (string, error) {
+		key := stateKey{id, group}
+		return args.state.next(key, args, func() (ProviderState, error) {
+			return newState(group, args)
+		})
+	}
+}
+
+// Counter returns a stateful provider that generates sequential decimal integers starting at start.
+// Values are zero-padded to the minimum length of the group, so e.g. `(?P<id>[0-9]{4})` will generate
+// 0001, 0002, etc.
+func Counter(start int64) Provider {
+	return Stateful(func(group *syntax.Regexp, args *GeneratorArgs) (ProviderState, error) {
+		generator, err := newGenerator(group, args)
+		if err != nil {
+			return nil, err
+		}
+		return &counterState{next: start, width: generator.MinLength(Runes)}, nil
+	})
+}
+
+/*
+Unique returns a stateful provider that generates values from the group's expression without repeating them, by
+sampling without replacement from an enumeration of the strings the expression matches (see
+ExtendedGenerator.GenerateUnique).
+
+Once the group runs out of new values, the provider returns an ErrUnsatisfiable error, so the group is generated
+from its expression as usual, which may repeat values.
+*/
+func Unique() Provider {
+	return Stateful(func(group *syntax.Regexp, args *GeneratorArgs) (ProviderState, error) {
+		generator, err := newGenerator(group, args)
+		if err != nil {
+			return nil, err
+		}
+		sizes := newLanguageSizes(LengthOverflow)
+		return &uniqueState{
+			generator: generator,
+			sizes:     sizes,
+			sampler:   newIndexSampler(sizes.of(generator), args),
+			seen:      make(map[string]bool),
+		}, nil
+	})
+}
+
+// Cycle returns a stateful provider that generates each of values in order, starting over after the last one.
+// The provider returns an ErrInvalidArgs error if there are no values.
+func Cycle(values ...string) Provider {
+	return Stateful(func(group *syntax.Regexp, args *GeneratorArgs) (ProviderState, error) {
+		if len(values) == 0 {
+			return nil, newError(ErrInvalidArgs, group, "Cycle requires at least one value")
+		}
+		return &cycleState{values: values}, nil
+	})
+}
+
+// GeneratorState is a snapshot of the stateful providers of a generator, returned by ExtendedGenerator.SaveState.
+type GeneratorState struct {
+	states map[stateKey]ProviderState
+}
+
+// stateKey identifies the state of a single Stateful provider for a single capture group.
+type stateKey struct {
+	provider *byte
+	group    *syntax.Regexp
+}
+
+// generatorState holds the provider states of a single generator.
+type generatorState struct {
+	lock   sync.Mutex
+	states map[stateKey]*lockedState
+}
+
+// lockedState serializes calls to a ProviderState. States are locked individually so that providers
+// can generate nested capture groups.
+type lockedState struct {
+	lock  sync.Mutex
+	state ProviderState
+}
+
+func newGeneratorState() *generatorState {
+	return &generatorState{states: make(map[stateKey]*lockedState)}
+}
+
+// next advances the state for key, creating it with newState if necessary.
+func (s *generatorState) next(key stateKey, args *GeneratorArgs, newState func() (ProviderState, error)) (string, error) {
+	entry, err := s.entry(key, newState)
+	if err != nil {
+		return "", err
+	}
+
+	entry.lock.Lock()
+	defer entry.lock.Unlock()
+	return entry.state.Next(args)
+}
+
+// entry returns the state for key, creating it with newState if necessary. The generator state is unlocked before
+// the provider state is advanced, so providers can generate nested capture groups.
+func (s *generatorState) entry(key stateKey, newState func() (ProviderState, error)) (*lockedState, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if entry, ok := s.states[key]; ok {
+		return entry, nil
+	}
+	state, err := newState()
+	if err != nil {
+		return nil, err
+	}
+	entry := &lockedState{state: state}
+	s.states[key] = entry
+	return entry, nil
+}
+
+func (s *generatorState) reset() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.states = make(map[stateKey]*lockedState)
+}
+
+func (s *generatorState) save() *GeneratorState {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	states := make(map[stateKey]ProviderState, len(s.states))
+	for key, entry := range s.states {
+		entry.lock.Lock()
+		states[key] = entry.state.Clone()
+		entry.lock.Unlock()
+	}
+	return &GeneratorState{states}
+}
+
+func (s *generatorState) restore(state *GeneratorState) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.states = make(map[stateKey]*lockedState, len(state.states))
+	for key, providerState := range state.states {
+		s.states[key] = &lockedState{state: providerState.Clone()}
+	}
+}
+
+type counterState struct {
+	next  int64
+	width int
+}
+
+func (s *counterState) Next(args *GeneratorArgs) (string, error) {
+	value := s.next
+	s.next++
+	return fmt.Sprintf("%0*d", s.width, value), nil
+}
+
+func (s *counterState) Clone() ProviderState {
+	clone := *s
+	return &clone
+}
+
+type uniqueState struct {
+	generator *internalGenerator
+	// Only read after the state is created, so it can be shared by clones.
+	sizes   *languageSizes
+	sampler *indexSampler
+	// Different numbers can produce the same string, so the values returned so far are also kept.
+	seen map[string]bool
+}
+
+func (s *uniqueState) Next(args *GeneratorArgs) (string, error) {
+	for misses := 0; misses < maxUniqueMisses; misses++ {
+		index, ok := s.sampler.next()
+		if !ok {
+			break
+		}
+		if value := s.sizes.stringAt(s.generator, index); !s.seen[value] {
+			s.seen[value] = true
+			return value, nil
+		}
+	}
+	return "", newError(ErrUnsatisfiable, nil, "/%s/ has no more unique values after %d", s.generator, len(s.seen))
+}
+
+func (s *uniqueState) Clone() ProviderState {
+	seen := make(map[string]bool, len(s.seen))
+	for value := range s.seen {
+		seen[value] = true
+	}
+	return &uniqueState{s.generator, s.sizes, s.sampler.clone(), seen}
+}
+
+type cycleState struct {
+	values []string
+	next   int
+}
+
+func (s *cycleState) Next(args *GeneratorArgs) (string, error) {
+	value := s.values[s.next]
+	s.next = (s.next + 1) % len(s.values)
+	return value, nil
+}
+
+func (s *cycleState) Clone() ProviderState {
+	clone := *s
+	return &clone
+}
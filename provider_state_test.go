@@ -0,0 +1,190 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"regexp/syntax"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func ExampleCounter() {
+	registry := NewProviderRegistry()
+	registry.Register("id", Counter(41))
+
+	generator, _ := NewGenerator(`order-(?P<id>[0-9]{6})`, &GeneratorArgs{
+		Flags:     syntax.Perl,
+		Providers: registry,
+	})
+
+	fmt.Println(generator.Generate())
+	fmt.Println(generator.Generate())
+
+	// Output:
+	// order-000041
+	// order-000042
+}
+
+func TestStatefulProviders(t *testing.T) {
+	t.Parallel()
+
+	newGen := func(pattern string, name string, provider Provider) ExtendedGenerator {
+		registry := NewProviderRegistry()
+		registry.Register(name, provider)
+		gen, err := NewGenerator(pattern, &GeneratorArgs{
+			RngSource: rand.NewSource(0),
+			Flags:     syntax.Perl,
+			Providers: registry,
+		})
+		So(err, ShouldBeNil)
+		return gen.(ExtendedGenerator)
+	}
+
+	Convey("Counter", t, func() {
+		Convey("Counts across calls to Generate", func() {
+			gen := newGen(`(?P<n>\d+)`, "n", Counter(1))
+			So(gen.Generate(), ShouldEqual, "1")
+			So(gen.Generate(), ShouldEqual, "2")
+			So(gen.Generate(), ShouldEqual, "3")
+		})
+
+		Convey("Counts each group separately", func() {
+			gen := newGen(`(?P<n>\d+)-(?P<n2>\d+)`, "n", Counter(1))
+			gen.Generate()
+			So(gen.Generate(), ShouldNotEqual, "2-2")
+
+			registry := NewProviderRegistry()
+			seqGen, err := NewGenerator(`(?P<seq>\d+)-(?P<seq2>\d+)`, &GeneratorArgs{Flags: syntax.Perl, Providers: registry})
+			So(err, ShouldBeNil)
+			So(seqGen.Generate(), ShouldEqual, "1-1")
+			So(seqGen.Generate(), ShouldEqual, "2-2")
+		})
+
+		Convey("Counts each generator separately", func() {
+			provider := Counter(1)
+			gen1 := newGen(`(?P<n>\d+)`, "n", provider)
+			gen2 := newGen(`(?P<n>\d+)`, "n", provider)
+			So(gen1.Generate(), ShouldEqual, "1")
+			So(gen1.Generate(), ShouldEqual, "2")
+			So(gen2.Generate(), ShouldEqual, "1")
+		})
+
+		Convey("Pads to the minimum length of the group", func() {
+			gen := newGen(`(?P<n>[0-9]{3,5})`, "n", Counter(7))
+			So(gen.Generate(), ShouldEqual, "007")
+		})
+	})
+
+	Convey("Unique", t, func() {
+		Convey("Never repeats a value", func() {
+			gen := newGen(`(?P<id>[0-9]{3})`, "id", Unique())
+			seen := make(map[string]bool)
+			for i := 0; i < 500; i++ {
+				value := gen.Generate()
+				So(seen[value], ShouldBeFalse)
+				seen[value] = true
+			}
+		})
+
+		Convey("Returns an error when it runs out of values", func() {
+			provider := Unique()
+			gen := newGen(`(?P<id>[ab])`, "id", provider).(*internalGenerator)
+			values := make(map[string]bool)
+			for i := 0; i < 2; i++ {
+				value, err := provider(gen.regexp, gen.args)
+				So(err, ShouldBeNil)
+				values[value] = true
+			}
+			So(values, ShouldHaveLength, 2)
+
+			_, err := provider(gen.regexp, gen.args)
+			So(errors.Is(err, ErrUnsatisfiable), ShouldBeTrue)
+		})
+
+		Convey("Restores saved states", func() {
+			gen := newGen(`(?P<id>[0-9]{2})`, "id", Unique())
+			seen := map[string]bool{gen.Generate(): true}
+			state := gen.SaveState()
+			for i := 0; i < 50; i++ {
+				gen.Generate()
+			}
+
+			gen.RestoreState(state)
+			for i := 0; i < 99; i++ {
+				value := gen.Generate()
+				So(seen[value], ShouldBeFalse)
+				seen[value] = true
+			}
+		})
+	})
+
+	Convey("Stateful", t, func() {
+		Convey("Falls back to the expression until a state can be created", func() {
+			fail := true
+			provider := Stateful(func(group *syntax.Regexp, args *GeneratorArgs) (ProviderState, error) {
+				if fail {
+					return nil, errors.New("not yet")
+				}
+				return &cycleState{values: []string{"x"}}, nil
+			})
+			gen := newGen(`(?P<c>[a-z])`, "c", provider)
+			So(gen.Generate(), ShouldNotBeEmpty)
+
+			fail = false
+			So(gen.Generate(), ShouldEqual, "x")
+			So(gen.Generate(), ShouldEqual, "x")
+		})
+	})
+
+	Convey("Cycle", t, func() {
+		gen := newGen(`(?P<method>GET|POST|PUT)`, "method", Cycle("GET", "POST"))
+		So(gen.Generate(), ShouldEqual, "GET")
+		So(gen.Generate(), ShouldEqual, "POST")
+		So(gen.Generate(), ShouldEqual, "GET")
+
+		_, err := Cycle()(&syntax.Regexp{Op: syntax.OpEmptyMatch}, gen.(*internalGenerator).args)
+		So(errors.Is(err, ErrInvalidArgs), ShouldBeTrue)
+	})
+
+	Convey("Generator state", t, func() {
+		gen := newGen(`(?P<n>\d+)`, "n", Counter(1))
+		gen.Generate()
+		gen.Generate()
+
+		Convey("ResetState starts over", func() {
+			gen.ResetState()
+			So(gen.Generate(), ShouldEqual, "1")
+		})
+
+		Convey("RestoreState returns to a saved state", func() {
+			state := gen.SaveState()
+			So(gen.Generate(), ShouldEqual, "3")
+			So(gen.Generate(), ShouldEqual, "4")
+
+			gen.RestoreState(state)
+			So(gen.Generate(), ShouldEqual, "3")
+
+			// The same snapshot can be restored more than once.
+			gen.RestoreState(state)
+			So(gen.Generate(), ShouldEqual, "3")
+		})
+	})
+}
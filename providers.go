@@ -0,0 +1,181 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Provider generates a realistic value for a named capture group.
+// group is the regular expression within the group, and args is the args used to create the generator for the group
+// (see CaptureGroupHandler).
+// Providers should use args.Rng() as their source of randomness so that seeded generators are reproducible.
+// If a provider returns an error, the group is generated from its expression instead.
+type Provider func(group *syntax.Regexp, args *GeneratorArgs) (string, error)
+
+/*
+ProviderRegistry maps capture group names to Providers.
+
+A provider can be registered for an exact group name, or for a name prefix. When a group is generated,
+a provider registered for its exact name is used if there is one, otherwise the provider registered for
+the longest matching prefix is used. E.g. a provider registered for the prefix "uuid" will be used for
+the groups (?P<uuid>…) and (?P<uuid_order>…).
+
+The output of a provider is only used if it matches the group's expression. Otherwise, if the provider returns an
+error, and for groups without a provider, the group is generated from its expression as usual.
+
+A registry can safely be shared between generators and used from multiple goroutines.
+*/
+type ProviderRegistry struct {
+	lock     sync.RWMutex
+	names    map[string]Provider
+	prefixes map[string]Provider
+
+	// Compiled group expressions used to validate provider output, keyed by expression string.
+	matchers sync.Map
+}
+
+// NewProviderRegistry returns a registry containing the built-in providers, which are registered as prefixes:
+//
+//	uuid       random version 4 UUID, e.g. "1b4e28ba-2fa1-4d2e-8d5c-0e2f1c6b5a3d"
+//	timestamp  RFC 3339 timestamp in UTC, e.g. "2015-06-21T14:03:27Z"
+//	date       ISO 8601 date, e.g. "2015-06-21"
+//	ipv4       dotted-decimal IPv4 address, e.g. "192.168.14.2"
+//	seq        sequential integers starting at 1 (see Counter)
+func NewProviderRegistry() *ProviderRegistry {
+	registry := &ProviderRegistry{
+		names:    make(map[string]Provider),
+		prefixes: make(map[string]Provider),
+	}
+	registry.RegisterPrefix("uuid", uuidProvider)
+	registry.RegisterPrefix("timestamp", timestampProvider)
+	registry.RegisterPrefix("date", dateProvider)
+	registry.RegisterPrefix("ipv4", ipv4Provider)
+	registry.RegisterPrefix("seq", Counter(1))
+	return registry
+}
+
+// Register sets the provider used for groups named name, replacing any existing one.
+func (r *ProviderRegistry) Register(name string, provider Provider) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.names[name] = provider
+}
+
+// RegisterPrefix sets the provider used for groups whose names start with prefix, replacing any existing one.
+func (r *ProviderRegistry) RegisterPrefix(prefix string, provider Provider) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.prefixes[prefix] = provider
+}
+
+// Lookup returns the provider that will be used for groups named name.
+func (r *ProviderRegistry) Lookup(name string) (provider Provider, ok bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	if provider, ok = r.names[name]; ok {
+		return
+	}
+
+	longest := -1
+	for prefix, p := range r.prefixes {
+		if strings.HasPrefix(name, prefix) && len(prefix) > longest {
+			provider, ok = p, true
+			longest = len(prefix)
+		}
+	}
+	return
+}
+
+// CaptureGroupHandler returns a handler that uses the providers in r, and generates groups without a provider
+// from their expressions.
+func (r *ProviderRegistry) CaptureGroupHandler() CaptureGroupHandler {
+	return r.captureGroupHandler(defaultCaptureGroupHandler)
+}
+
+// captureGroupHandler returns a handler that uses the providers in r, and calls fallback for unnamed groups,
+// groups without a provider, and groups whose provider returned an error or a non-matching string.
+func (r *ProviderRegistry) captureGroupHandler(fallback CaptureGroupHandler) CaptureGroupHandler {
+	return func(index int, name string, group *syntax.Regexp, generator Generator, args *GeneratorArgs) string {
+		if name != "" {
+			if provider, ok := r.Lookup(name); ok {
+				if value, err := provider(group, args); err == nil && r.matches(group, value) {
+					return value
+				}
+			}
+		}
+		return fallback(index, name, group, generator, args)
+	}
+}
+
+// matches returns true if value matches the entire expression group.
+func (r *ProviderRegistry) matches(group *syntax.Regexp, value string) bool {
+	pattern := group.String()
+
+	matcher, ok := r.matchers.Load(pattern)
+	if !ok {
+		// A nil matcher is cached for expressions the regexp package can't compile, so they never match.
+		compiled, _ := regexp.Compile(`^(?:` + pattern + `)$`)
+		matcher, _ = r.matchers.LoadOrStore(pattern, compiled)
+	}
+
+	compiled := matcher.(*regexp.Regexp)
+	return compiled != nil && compiled.MatchString(value)
+}
+
+func uuidProvider(group *syntax.Regexp, args *GeneratorArgs) (string, error) {
+	rng := args.Rng()
+	var b [16]byte
+	for i := range b {
+		b[i] = byte(rng.Intn(256))
+	}
+
+	// Set the version (4) and variant (RFC 4122) bits.
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+// Earliest and latest times generated by the timestamp and date providers.
+var (
+	minProviderTime = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC).Unix()
+	maxProviderTime = time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC).Unix()
+)
+
+func randomProviderTime(args *GeneratorArgs) time.Time {
+	return time.Unix(minProviderTime+args.Rng().Int63n(maxProviderTime-minProviderTime), 0).UTC()
+}
+
+func timestampProvider(group *syntax.Regexp, args *GeneratorArgs) (string, error) {
+	return randomProviderTime(args).Format(time.RFC3339), nil
+}
+
+func dateProvider(group *syntax.Regexp, args *GeneratorArgs) (string, error) {
+	return randomProviderTime(args).Format("2006-01-02"), nil
+}
+
+func ipv4Provider(group *syntax.Regexp, args *GeneratorArgs) (string, error) {
+	rng := args.Rng()
+	return fmt.Sprintf("%d.%d.%d.%d", 1+rng.Intn(254), rng.Intn(256), rng.Intn(256), 1+rng.Intn(254)), nil
+}
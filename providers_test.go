@@ -0,0 +1,154 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"regexp/syntax"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func ExampleProviderRegistry() {
+	pattern := `(?P<timestamp>\S+) INFO request (?P<uuid_request>[-0-9a-f]{36}) from (?P<ipv4>[0-9.]+)`
+
+	generator, _ := NewGenerator(pattern, &GeneratorArgs{
+		Flags:     syntax.Perl,
+		Providers: NewProviderRegistry(),
+	})
+
+	// Print to stderr since we're generating random output and can't assert equality.
+	fmt.Fprintln(os.Stderr, generator.Generate())
+
+	// Output:
+}
+
+func TestProviderRegistry(t *testing.T) {
+	t.Parallel()
+
+	constant := func(value string) Provider {
+		return func(group *syntax.Regexp, args *GeneratorArgs) (string, error) {
+			return value, nil
+		}
+	}
+
+	Convey("Lookup", t, func() {
+		registry := NewProviderRegistry()
+		registry.Register("id", constant("exact"))
+		registry.RegisterPrefix("i", constant("short"))
+		registry.RegisterPrefix("id_", constant("long"))
+
+		Convey("Prefers exact names", func() {
+			provider, ok := registry.Lookup("id")
+			So(ok, ShouldBeTrue)
+			value, _ := provider(nil, nil)
+			So(value, ShouldEqual, "exact")
+		})
+
+		Convey("Prefers the longest prefix", func() {
+			provider, ok := registry.Lookup("id_order")
+			So(ok, ShouldBeTrue)
+			value, _ := provider(nil, nil)
+			So(value, ShouldEqual, "long")
+
+			provider, ok = registry.Lookup("index")
+			So(ok, ShouldBeTrue)
+			value, _ = provider(nil, nil)
+			So(value, ShouldEqual, "short")
+		})
+
+		Convey("Fails for unknown names", func() {
+			_, ok := registry.Lookup("name")
+			So(ok, ShouldBeFalse)
+		})
+	})
+
+	Convey("Providers", t, func() {
+		Convey("Built-in providers match their usual expressions", func() {
+			args := &GeneratorArgs{
+				RngSource: rand.NewSource(0),
+				Flags:     syntax.Perl,
+				Providers: NewProviderRegistry(),
+			}
+
+			ConveyGeneratesStringMatching(args,
+				`(?P<uuid>[-0-9a-f]{36})`,
+				`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+			ConveyGeneratesStringMatching(args,
+				`(?P<timestamp>\S+)`,
+				`^20[0-2]\d-\d\d-\d\dT\d\d:\d\d:\d\dZ$`)
+			ConveyGeneratesStringMatching(args,
+				`(?P<date>\S+)`,
+				`^20[0-2]\d-\d\d-\d\d$`)
+			ConveyGeneratesStringMatching(args,
+				`(?P<ipv4>[0-9.]+)`,
+				`^\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3}$`)
+		})
+
+		Convey("Output is used when it matches the group", func() {
+			registry := NewProviderRegistry()
+			registry.Register("word", constant("hello"))
+
+			gen, err := NewGenerator(`say (?P<word>[a-z]+)`, &GeneratorArgs{Flags: syntax.Perl, Providers: registry})
+			So(err, ShouldBeNil)
+			So(gen.Generate(), ShouldEqual, "say hello")
+		})
+
+		Convey("Falls back to the expression when output doesn't match the group", func() {
+			registry := NewProviderRegistry()
+			registry.Register("word", constant("HELLO"))
+
+			gen, err := NewGenerator(`say (?P<word>[a-z]{3})`, &GeneratorArgs{Flags: syntax.Perl, Providers: registry})
+			So(err, ShouldBeNil)
+			for i := 0; i < SampleSize; i++ {
+				So(gen.Generate(), ShouldNotEqual, "say HELLO")
+			}
+			So(`say (?P<word>[a-z]{3})`, ShouldGenerateStringMatching, `^say [a-z]{3}$`, &GeneratorArgs{Flags: syntax.Perl, Providers: registry})
+		})
+
+		Convey("Falls back to the expression when the provider returns an error", func() {
+			registry := NewProviderRegistry()
+			registry.Register("word", func(group *syntax.Regexp, args *GeneratorArgs) (string, error) {
+				return "abc", errors.New("no words")
+			})
+
+			// The expression can't generate the provider's value.
+			gen, err := NewGenerator(`say (?P<word>[b-z]{3})`, &GeneratorArgs{Flags: syntax.Perl, Providers: registry})
+			So(err, ShouldBeNil)
+			for i := 0; i < SampleSize; i++ {
+				So(gen.Generate(), ShouldNotEqual, "say abc")
+			}
+		})
+
+		Convey("Groups without providers are passed to CaptureGroupHandler", func() {
+			gen, err := NewGenerator(`(?P<uuid>[-0-9a-f]{36})(a)(?P<name>b)`, &GeneratorArgs{
+				Flags:     syntax.Perl,
+				Providers: NewProviderRegistry(),
+				CaptureGroupHandler: func(index int, name string, group *syntax.Regexp, generator Generator, args *GeneratorArgs) string {
+					return fmt.Sprintf("<%d>", index)
+				},
+			})
+			So(err, ShouldBeNil)
+			So(gen.Generate(), ShouldEndWith, "<1><2>")
+			So(gen.Generate(), ShouldNotStartWith, "<0>")
+		})
+	})
+}
@@ -36,7 +36,11 @@ e.g. "x{0,256}".
 
 Flags
 
-Flags can be passed to the parser by setting them in the GeneratorArgs struct.
+Flags can be passed to the parser by setting them in the GeneratorArgs struct. By default, only the flags in
+GeneratorArgs.Flags are used, which differs from regexp.Compile: e.g. \d and (?i) are errors without syntax.Perl.
+Set GeneratorArgs.Compatibility to RegexpCompatible to parse patterns exactly as regexp.Compile does, or use the v2
+package, where that's the default.
+
 Newline flags are respected, and newlines won't be generated unless the appropriate flags for
 matching them are set.
 
@@ -48,6 +52,45 @@ The Perl character class flag is supported, and required if the pattern contains
 
 Unicode groups are not supported at this time. Support may be added in the future.
 
+Providers
+
+Named capture groups can be generated from realistic values instead of their expressions by setting
+GeneratorArgs.Providers. E.g.
+	NewGenerator(`(?P<timestamp>\S+) \[(?P<uuid>[-0-9a-f]{36})\]`, &GeneratorArgs{
+		Flags:     syntax.Perl,
+		Providers: NewProviderRegistry(),
+	})
+will generate log lines with real timestamps and UUIDs. See ProviderRegistry for the built-in providers.
+
+Providers created with Stateful, such as Counter and Unique, keep their state across calls to Generate.
+Each generator has its own state, which can be reset, saved, and restored.
+
+Lookarounds
+
+Lookaheads and lookbehinds, which regexp/syntax doesn't support, are handled by NewGenerator itself:
+	NewGenerator(`^(?=.*[A-Z])(?=.*\d)(?!.*password)\w{8,16}$`, &GeneratorArgs{Flags: syntax.Perl})
+will only generate strings that satisfy all the lookarounds. Patterns with lookarounds are generated by
+AutomatonEngine, which chooses a length uniformly and then a string of that length uniformly, and ignores any
+CaptureGroupHandler or Providers. Lookarounds can't be nested.
+
+Combining Patterns
+
+Strings that match several patterns, and don't match others, are generated by setting MustMatch and MustNotMatch:
+	NewGenerator(`[a-z0-9_]{3,20}`, &GeneratorArgs{
+		MustMatch:    []string{`.*[0-9].*`},
+		MustNotMatch: []string{`admin.*`},
+	})
+The patterns are combined into a single automaton, so even rare combinations are generated directly instead of by
+rejecting strings that don't satisfy them.
+
+Dialects
+
+Patterns written for PCRE, JavaScript or POSIX ERE can be used by setting Dialect:
+	NewGenerator(`\d{3}\s\u{1F600}`, &GeneratorArgs{Dialect: JavaScript})
+They're translated into regexp/syntax patterns first, so escapes such as PCRE's \h and \R, and JavaScript's \s and
+\u{...}, are generated as their dialect defines them. Backreferences, recursion and other constructs that don't
+describe regular languages return an error.
+
 Concurrent Use
 
 A generator can safely be used from multiple goroutines without locking.
@@ -61,7 +104,8 @@ source is created per call to NewGenerator. If no source is passed in, the defau
 The source is not locked and does not use atomic operations, so there is a chance that multiple goroutines using
 the same source may get the same output. While obviously not cryptographically secure, I think the simplicity and performance
 benefit outweighs the risk of collisions. If you really care about preventing this, the solution is simple: don't
-call a single Generator from multiple goroutines.
+call a single Generator from multiple goroutines. Give each goroutine its own clone instead (see
+ExtendedGenerator.Clone), which is cheap, and shares everything with the original except its source and provider states.
 
 Benchmarks
 
@@ -95,7 +139,9 @@ package regen
 import (
 	"fmt"
 	"math/rand"
+	"regexp"
 	"regexp/syntax"
+	"unicode"
 )
 
 // DefaultMaxUnboundedRepeatCount is default value for MaxUnboundedRepeatCount.
@@ -106,9 +152,35 @@ const DefaultMaxUnboundedRepeatCount = 4096
 // (not 1, as when matching).
 // group is the regular expression within the group (e.g. for `(\w+)`, group would be `\w+`).
 // generator is the generator for group.
-// args is the args used to create the generator calling this function.
+// args is the args used to create generator, including the GroupArgs for the group and any groups it's nested in.
 type CaptureGroupHandler func(index int, name string, group *syntax.Regexp, generator Generator, args *GeneratorArgs) string
 
+// Compatibility selects how GeneratorArgs.Flags are used to parse patterns.
+type Compatibility int
+
+const (
+	// DefaultCompatibility is FlagsCompatible in this package, and RegexpCompatible in the v2 package.
+	DefaultCompatibility Compatibility = iota
+	// FlagsCompatible parses patterns with GeneratorArgs.Flags, so e.g. \d and (?i) are errors unless Flags includes
+	// syntax.PerlX. This is the original behaviour of NewGenerator.
+	FlagsCompatible
+	// RegexpCompatible parses patterns with syntax.Perl, like regexp.Compile, in addition to GeneratorArgs.Flags.
+	RegexpCompatible
+)
+
+var compatibilityNames = map[Compatibility]string{
+	DefaultCompatibility: "DefaultCompatibility",
+	FlagsCompatible:      "FlagsCompatible",
+	RegexpCompatible:     "RegexpCompatible",
+}
+
+func (c Compatibility) String() string {
+	if name, ok := compatibilityNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("Compatibility(%d)", int(c))
+}
+
 // GeneratorArgs are arguments passed to NewGenerator that control how generators
 // are created.
 type GeneratorArgs struct {
@@ -117,9 +189,18 @@ type GeneratorArgs struct {
 	// See http://vigna.di.unimi.it/ftp/papers/xorshift.pdf.
 	RngSource rand.Source
 
-	// Default is 0 (syntax.POSIX).
+	// Default is 0 (syntax.POSIX), unless Compatibility is RegexpCompatible.
 	Flags syntax.Flags
 
+	// How patterns are parsed. RegexpCompatible parses them exactly as regexp.Compile does.
+	// Default is FlagsCompatible, which parses them with just Flags. The v2 package defaults to RegexpCompatible.
+	Compatibility Compatibility
+
+	// The syntax of the pattern. Patterns in other dialects are translated by Dialect.Translate, and parsed with
+	// syntax.Perl in addition to Flags.
+	// Default is RE2.
+	Dialect Dialect
+
 	// Maximum number of instances to generate for unbounded repeat expressions (e.g. ".*" and "{1,}")
 	// Default is DefaultMaxUnboundedRepeatCount.
 	MaxUnboundedRepeatCount uint
@@ -127,41 +208,147 @@ type GeneratorArgs struct {
 	// Default is 0.
 	MinUnboundedRepeatCount uint
 
+	// If not 0, the maximum number of instances to generate for any repeat, including ones with explicit bounds (e.g.
+	// "{1,65535}", which is allowed even though regexp/syntax limits repeats to 1000). NewGenerator returns an error
+	// if a repeat has a higher minimum. Lowers MaxUnboundedRepeatCount if it's higher. Repeats in MustMatch and
+	// MustNotMatch patterns, and patterns parsed with syntax.Literal, aren't capped. Must be at most 1000, since
+	// repeats are capped to it before they're parsed.
+	// Default is 0.
+	MaxRepeatCount uint
+	// How MaxRepeatCount is applied to repeats with explicit bounds.
+	// Default is ClampRepeats.
+	RepeatCapStrategy RepeatCapStrategy
+
+	// The distribution of the number of times repeats are generated, within their bounds. Not supported by
+	// AutomatonEngine.
+	// Default is UniformRepeats.
+	RepeatDistribution RepeatDistribution
+
+	// If not nil, character classes (e.g. "." and "[^a]") only generate runes in this table, e.g. unicode.Latin.
+	// Literals are always generated as written. NewGenerator returns an ErrUnsatisfiable error if a character class
+	// has no runes in the table. Not supported by AutomatonEngine.
+	// Default is nil.
+	Universe *unicode.RangeTable
+
+	// Overrides for the expressions inside named capture groups, by group name. Names that don't match any group
+	// are ignored.
+	Groups map[string]GroupArgs
+
+	// Maximum and minimum length of generated strings, measured in OutputLengthUnit. Repeats and alternations are
+	// chosen so that the entire string fits, e.g. "(\w+ ){1,50}" will generate fewer, shorter words to fit in a
+	// small maximum. NewGenerator returns an error if either is negative, or if the pattern can't generate strings
+	// within the bounds.
+	// Default is 0 (no maximum) and 0.
+	MaxOutputLength int
+	MinOutputLength int
+	// Default is Runes.
+	OutputLengthUnit LengthUnit
+	// If true, the lengths of generated strings are distributed uniformly between MinOutputLength and
+	// MaxOutputLength (or the longest string the pattern can generate, if MaxOutputLength is 0), instead of
+	// depending on the structure of the pattern. NewGenerator takes time proportional to the square of the longest
+	// length, so it returns an ErrInvalidArgs error if that's more than MaxUniformOutputLength: set MaxOutputLength
+	// for patterns with long or unbounded repeats.
+	UniformOutputLength bool
+
+	// The algorithm used to generate strings. Patterns with lookarounds, MustMatch or MustNotMatch always use
+	// AutomatonEngine.
+	// Default is TreeEngine.
+	Engine Engine
+
+	// Patterns generated strings must also match, and patterns they must not match, written in the same Dialect and
+	// parsed with the same Flags. Like the pattern passed to NewGenerator, they're matched against entire strings.
+	// If either is set, strings are generated by AutomatonEngine from an automaton for the combination of the
+	// patterns. NewGenerator returns an ErrUnsatisfiable error if no strings satisfy them all.
+	MustMatch    []string
+	MustNotMatch []string
+
 	// Set this to perform special processing of capture groups (e.g. `(\w+)`). The zero value will generate strings
 	// from the expressions in the group.
 	CaptureGroupHandler CaptureGroupHandler
 
+	// Set this to generate named capture groups using Providers (e.g. `(?P<uuid>[-0-9a-f]{36})`).
+	// Groups without a matching provider are passed to CaptureGroupHandler.
+	Providers *ProviderRegistry
+
 	// Used by generators.
 	rng *rand.Rand
+
+	// Used by stateful providers.
+	state *generatorState
+
+	// The runes automata are built over.
+	alphabet []runeInterval
+
+	// True if capture groups are not simply generated from their expressions.
+	customCaptureGroups bool
 }
 
 func (a *GeneratorArgs) initialize() error {
-	var seed int64
-	if nil == a.RngSource {
-		seed = rand.Int63()
-	} else {
-		seed = a.RngSource.Int63()
+	a.rng = newRand(a.RngSource)
+	a.state = newGeneratorState()
+	a.alphabet = automatonAlphabet
+
+	switch a.Compatibility {
+	case DefaultCompatibility, FlagsCompatible:
+	case RegexpCompatible:
+		a.Flags |= syntax.Perl
+	default:
+		return newError(ErrInvalidArgs, nil, "invalid Compatibility %d", a.Compatibility)
+	}
+	if a.Dialect != RE2 {
+		a.Flags |= syntax.Perl
 	}
-	rngSource := xorShift64Source(seed)
-	a.rng = rand.New(&rngSource)
 
 	// unicode groups only allowed with Perl
 	if (a.Flags&syntax.UnicodeGroups) == syntax.UnicodeGroups && (a.Flags&syntax.Perl) != syntax.Perl {
-		return generatorError(nil, "UnicodeGroups not supported")
+		return newError(ErrUnicodeGroups, nil, "UnicodeGroups not supported")
 	}
 
 	if a.MaxUnboundedRepeatCount < 1 {
 		a.MaxUnboundedRepeatCount = DefaultMaxUnboundedRepeatCount
 	}
 
+	if _, ok := repeatDistributionNames[a.RepeatDistribution]; !ok {
+		return newError(ErrInvalidArgs, nil, "invalid RepeatDistribution %d", a.RepeatDistribution)
+	}
+
+	if _, ok := engineNames[a.Engine]; !ok {
+		return newError(ErrInvalidArgs, nil, "invalid Engine %d", a.Engine)
+	}
+
+	if _, ok := lengthUnitNames[a.OutputLengthUnit]; !ok {
+		return newError(ErrInvalidArgs, nil, "invalid OutputLengthUnit %d", a.OutputLengthUnit)
+	}
+
+	if _, ok := repeatCapStrategyNames[a.RepeatCapStrategy]; !ok {
+		return newError(ErrInvalidArgs, nil, "invalid RepeatCapStrategy %d", a.RepeatCapStrategy)
+	}
+
+	if a.MinOutputLength < 0 || a.MaxOutputLength < 0 {
+		return newError(ErrInvalidArgs, nil, "invalid output length bounds: MinOutputLength(%d), MaxOutputLength(%d)",
+			a.MinOutputLength, a.MaxOutputLength)
+	}
+
 	if a.MinUnboundedRepeatCount > a.MaxUnboundedRepeatCount {
-		panic(fmt.Sprintf("MinUnboundedRepeatCount(%d) > MaxUnboundedRepeatCount(%d)",
-			a.MinUnboundedRepeatCount, a.MaxUnboundedRepeatCount))
+		return newError(ErrInvalidArgs, nil, "MinUnboundedRepeatCount(%d) > MaxUnboundedRepeatCount(%d)",
+			a.MinUnboundedRepeatCount, a.MaxUnboundedRepeatCount)
+	}
+
+	if a.MaxRepeatCount > maxParsedRepeatCount {
+		return newError(ErrInvalidArgs, nil, "MaxRepeatCount(%d) is more than %d, the most regexp/syntax can parse",
+			a.MaxRepeatCount, maxParsedRepeatCount)
+	}
+	if err := a.capUnboundedRepeats(); err != nil {
+		return err
 	}
 
+	a.customCaptureGroups = a.CaptureGroupHandler != nil || a.Providers != nil
 	if a.CaptureGroupHandler == nil {
 		a.CaptureGroupHandler = defaultCaptureGroupHandler
 	}
+	if a.Providers != nil {
+		a.CaptureGroupHandler = a.Providers.captureGroupHandler(a.CaptureGroupHandler)
+	}
 
 	return nil
 }
@@ -182,22 +369,86 @@ type Generator interface {
 }
 
 /*
-Generate a random string that matches the regular expression pattern.
-If args is nil, default values are used.
+ExtendedGenerator is implemented by all the generators returned by this package, including the ones passed to
+CaptureGroupHandlers. It's separate from Generator so that other implementations of Generator don't have to
+implement it. E.g.
+	generator, _ := NewGenerator(`[a-z]{3,5}`, nil)
+	strings, err := generator.(ExtendedGenerator).GenerateUnique(10)
+*/
+type ExtendedGenerator interface {
+	Generator
+
+	// GenerateUnique returns n distinct generated strings, or an error if the generator can't produce that many.
+	GenerateUnique(n int) ([]string, error)
+
+	// GenerateCoverage returns a small set of strings that exercise every choice the generator can make.
+	// If maxStrings > 0, at most maxStrings are returned. The report lists the choices that weren't exercised.
+	GenerateCoverage(maxStrings int) ([]string, *CoverageReport)
+
+	// GenerateCombinations returns a small set of strings that cover every combination of strength choices across
+	// the alternations and optional expressions of the pattern. A strength of 2 generates pairwise combinations.
+	GenerateCombinations(strength int) ([]string, error)
+
+	// Tree returns a copy of the tree of generators, for analyzing the generator without re-parsing its pattern.
+	Tree() *Node
+
+	// MinLength returns the length of the shortest string the generator can produce, within MinOutputLength.
+	MinLength(unit LengthUnit) int
+	// MaxLength returns the length of the longest string the generator can produce, within MaxOutputLength.
+	MaxLength(unit LengthUnit) int
+	// IsFinite returns true if the pattern matches a finite number of strings, or MaxOutputLength limits them, so
+	// MaxLength doesn't depend on MaxUnboundedRepeatCount.
+	IsFinite() bool
+
+	// GenerateLength returns a string of exactly length runes (or bytes, see OutputLengthUnit), or an error if the
+	// generator can't produce a string of that length.
+	GenerateLength(length int) (string, error)
+
+	// GenerateWithPrefix returns a string that starts with prefix and matches the whole pattern, or an error if
+	// there is no such string.
+	GenerateWithPrefix(prefix string) (string, error)
+	// Complete returns up to n of the shortest strings that can be appended to partial to match the whole pattern,
+	// or an error if there are none.
+	Complete(partial string, n int) ([]string, error)
+
+	// GenerateDocument returns text with generated strings embedded in noise, and the byte offsets of each one.
+	// If args is nil, default values are used.
+	GenerateDocument(args *DocumentArgs) (*Document, error)
+
+	// Clone returns a generator for the same pattern with its own random number generator, seeded from source, and
+	// its own provider states, without recompiling the pattern.
+	Clone(source rand.Source) ExtendedGenerator
+	// WithSeed returns a clone of the generator seeded from rand.NewSource(seed).
+	WithSeed(seed int64) ExtendedGenerator
+
+	// Entropy estimates the randomness of the generated strings, in bits, or returns an error if it can't.
+	Entropy() (Entropy, error)
+
+	// ResetState resets all stateful providers (see Stateful) used by the generator to their initial states.
+	ResetState()
+	// SaveState returns a snapshot of the state of all stateful providers used by the generator.
+	SaveState() *GeneratorState
+	// RestoreState restores the stateful providers used by the generator to a snapshot returned by SaveState.
+	RestoreState(state *GeneratorState)
+}
 
-This function does not seed the default RNG, so you must call rand.Seed() if you want
-non-deterministic strings.
+/*
+Generate a random string that matches the regular expression pattern, using default args.
+
+The generator for pattern is kept in DefaultCache, so calling Generate repeatedly with the same pattern only parses
+it once. Each call generates from a clone of it seeded from the default RNG, so you must call rand.Seed() if you
+want non-deterministic strings on versions of Go before 1.20.
 */
 func Generate(pattern string) (string, error) {
-	generator, err := NewGenerator(pattern, nil)
+	generator, err := DefaultCache.Generator(pattern, 0)
 	if err != nil {
 		return "", err
 	}
-	return generator.Generate(), nil
+	return generator.Clone(nil).Generate(), nil
 }
 
 // NewGenerator creates a generator that returns random strings that match the regular expression in pattern.
-// If args is nil, default values are used.
+// If args is nil, default values are used. The generator implements ExtendedGenerator.
 func NewGenerator(pattern string, inputArgs *GeneratorArgs) (generator Generator, err error) {
 	args := GeneratorArgs{}
 
@@ -209,17 +460,161 @@ func NewGenerator(pattern string, inputArgs *GeneratorArgs) (generator Generator
 		return nil, err
 	}
 
+	source := pattern
+	var wordAnchor int
+	if pattern, wordAnchor, err = args.Dialect.translate(pattern); err != nil {
+		return nil, err
+	}
+	if wordAnchor >= 0 {
+		if err = checkWordAnchor(source, wordAnchor, &args); err != nil {
+			return nil, err
+		}
+	}
+	if pattern, err = args.capRepeatText(pattern); err != nil {
+		return nil, locate(err, source)
+	}
+
+	var lookarounds []lookaround
+	if lookarounds, err = findLookarounds(pattern, args.Flags); err != nil {
+		return nil, err
+	}
+
 	var regexp *syntax.Regexp
-	regexp, err = syntax.Parse(pattern, args.Flags)
+	regexp, err = syntax.Parse(removeLookarounds(pattern, lookarounds), args.Flags)
 	if err != nil {
-		return
+		return nil, locate(parseError(err), source)
 	}
 
 	var gen *internalGenerator
-	gen, err = newGenerator(regexp, &args)
+	if gen, err = newRootGenerator(regexp, pattern, lookarounds, &args); err != nil {
+		return nil, locate(err, source)
+	}
+	return gen, nil
+}
+
+/*
+NewGeneratorFromRegexp creates a generator that returns random strings that match re, parsing it the way
+regexp.Compile does: with syntax.Perl flags, whatever args.Flags and args.Dialect are. If args is nil, default values
+are used.
+
+GenerateDocument finds matches with re itself, so they're leftmost-longest if re.Longest has been called.
+
+Regexps from regexp.CompilePOSIX are also parsed with syntax.Perl flags, which they don't always agree with: e.g.
+their negated classes don't match '\n'. So if re means something else with syntax.POSIX flags, generated strings
+are checked against re, and strings it doesn't match entirely are generated again. Returns an ErrUnsatisfiable error
+if re doesn't match any of the first strings a clone of the generator generates, and Generate panics with one if re
+doesn't match any of maxRegexpAttempts strings in a row. Strings from a CaptureGroupHandler or Providers are never
+checked.
+*/
+func NewGeneratorFromRegexp(re *regexp.Regexp, inputArgs *GeneratorArgs) (Generator, error) {
+	args := GeneratorArgs{}
+	if inputArgs != nil {
+		args = *inputArgs
+	}
+	args.Flags = syntax.Perl
+	args.Dialect = RE2
+
+	generator, err := NewGenerator(re.String(), &args)
+	if err != nil {
+		return nil, err
+	}
+	gen := generator.(*internalGenerator)
+	gen.source = re
+	if gen.args.customCaptureGroups || !dependsOnFlags(re.String()) {
+		return gen, nil
+	}
+
+	// A leftmost-longest copy of re finds a match of the whole string if there is one.
+	matcher := *re
+	matcher.Longest()
+	gen.sourceMatcher = &matcher
+
+	// Probe a clone, so gen's random number generator and provider states aren't used.
+	probe := gen.Clone(nil).(*internalGenerator)
+	for i := 0; !gen.matchesSource(probe.GenerateFunc(probe.args)); i++ {
+		if i == maxRegexpAttempts {
+			return nil, newError(ErrUnsatisfiable, nil, "/%s/ didn't match any of %d generated strings", re, i)
+		}
+	}
+	generate := gen.GenerateFunc
+	gen.GenerateFunc = func(args *GeneratorArgs) string {
+		for i := 0; i < maxRegexpAttempts; i++ {
+			if value := generate(args); gen.matchesSource(value) {
+				return value
+			}
+		}
+		panic(newError(ErrUnsatisfiable, nil, "/%s/ didn't match any of %d generated strings", re, maxRegexpAttempts))
+	}
+	return gen, nil
+}
+
+// Number of strings NewGeneratorFromRegexp generates to check that its regexp matches one.
+const maxRegexpAttempts = 1000
+
+// dependsOnFlags returns true if pattern can be parsed with both syntax.Perl and syntax.POSIX flags, and means
+// something different with each.
+func dependsOnFlags(pattern string) bool {
+	posix, err := syntax.Parse(pattern, syntax.POSIX)
+	if err != nil {
+		return false
+	}
+	perl, err := syntax.Parse(pattern, syntax.Perl)
+	return err == nil && posix.String() != perl.String()
+}
+
+// matchesSource returns true if value is matched entirely by the regexp passed to NewGeneratorFromRegexp, if it's
+// checked.
+func (gen *internalGenerator) matchesSource(value string) bool {
+	if gen.sourceMatcher == nil {
+		return true
+	}
+	match := gen.sourceMatcher.FindStringIndex(value)
+	return match != nil && match[0] == 0 && match[1] == len(value)
+}
+
+// NewGeneratorFromSyntax creates a generator that returns random strings that match the parsed expression re.
+// args.Flags and args.Dialect are ignored, since re is already parsed. If args is nil, default values are used.
+func NewGeneratorFromSyntax(re *syntax.Regexp, inputArgs *GeneratorArgs) (Generator, error) {
+	args := GeneratorArgs{}
+	if inputArgs != nil {
+		args = *inputArgs
+	}
+	args.Dialect = RE2
+	if err := args.initialize(); err != nil {
+		return nil, err
+	}
+
+	gen, err := newRootGenerator(re, re.String(), nil, &args)
+	if err != nil {
+		return nil, locate(err, re.String())
+	}
+	return gen, nil
+}
+
+// newRootGenerator creates a generator for the whole of pattern, which has been parsed into regexp without its
+// lookarounds.
+func newRootGenerator(regexp *syntax.Regexp, pattern string, lookarounds []lookaround, args *GeneratorArgs) (*internalGenerator, error) {
+	regexp, err := args.capRepeats(regexp)
 	if err != nil {
-		return
+		return nil, err
 	}
 
+	if args.Engine == AutomatonEngine || len(lookarounds) > 0 || len(args.MustMatch) > 0 || len(args.MustNotMatch) > 0 {
+		return newAutomatonEngineGenerator(regexp, pattern, lookarounds, args)
+	}
+
+	gen, err := newGenerator(regexp, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if args.UniformOutputLength {
+		err = newUniformLengthGenerator(gen, args)
+	} else if args.MaxOutputLength > 0 || args.MinOutputLength > 0 {
+		err = newBoundedGenerator(gen, args)
+	}
+	if err != nil {
+		return nil, err
+	}
 	return gen, nil
 }
@@ -83,3 +83,17 @@ func BenchmarkLargeRepeatGenerateSerial(b *testing.B) {
 		generator.Generate()
 	}
 }
+
+func BenchmarkComplexClone(b *testing.B) {
+	generator, err := NewGenerator(BigFancyRegexp, &GeneratorArgs{
+		RngSource: rngSource,
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		generator.(ExtendedGenerator).WithSeed(int64(i))
+	}
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package regen
 
 import (
+	"errors"
 	"fmt"
 	"math/rand"
 	"os"
@@ -86,6 +87,22 @@ func ExampleNewGenerator_perl() {
 	// Matches!
 }
 
+func ExampleNewGenerator_regexpCompatible() {
+	pattern := `(?i)\d{5}[a-z]`
+
+	generator, _ := NewGenerator(pattern, &GeneratorArgs{
+		Compatibility: RegexpCompatible,
+	})
+
+	str := generator.Generate()
+
+	if regexp.MustCompile(pattern).MatchString(str) {
+		fmt.Println("Matches!")
+	}
+	// Output:
+	// Matches!
+}
+
 func ExampleCaptureGroupHandler() {
 	pattern := `Hello, (?P<firstname>[A-Z][a-z]{2,10}) (?P<lastname>[A-Z][a-z]{2,10})`
 
@@ -129,15 +146,42 @@ func TestGeneratorArgs(t *testing.T) {
 			So(err.Error(), ShouldEqual, "UnicodeGroups not supported")
 		})
 
-		Convey("Panics if repeat bounds are invalid", func() {
+		Convey("Returns error if repeat bounds are invalid", func() {
 			args := &GeneratorArgs{
 				MinUnboundedRepeatCount: 2,
 				MaxUnboundedRepeatCount: 1,
 			}
 
-			So(func() { args.initialize() },
-				ShouldPanicWith,
-				"MinUnboundedRepeatCount(2) > MaxUnboundedRepeatCount(1)")
+			err := args.initialize()
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "MinUnboundedRepeatCount(2) > MaxUnboundedRepeatCount(1)")
+			So(errors.Is(err, ErrInvalidArgs), ShouldBeTrue)
+		})
+
+		Convey("Adds Perl flags if RegexpCompatible", func() {
+			args := &GeneratorArgs{Flags: syntax.FoldCase, Compatibility: RegexpCompatible}
+			So(args.initialize(), ShouldBeNil)
+			So(args.Flags, ShouldEqual, syntax.Perl|syntax.FoldCase)
+
+			args = &GeneratorArgs{Compatibility: FlagsCompatible}
+			So(args.initialize(), ShouldBeNil)
+			So(args.Flags, ShouldEqual, syntax.POSIX)
+
+			args = &GeneratorArgs{Compatibility: Compatibility(42)}
+			So(errors.Is(args.initialize(), ErrInvalidArgs), ShouldBeTrue)
+		})
+
+		Convey("Names engines and length units", func() {
+			So(AutomatonEngine.String(), ShouldEqual, "AutomatonEngine")
+			So(Bytes.String(), ShouldEqual, "Bytes")
+			So(Engine(42).String(), ShouldEqual, "Engine(42)")
+
+			args := &GeneratorArgs{OutputLengthUnit: LengthUnit(42)}
+			err := args.initialize()
+			So(errors.Is(err, ErrInvalidArgs), ShouldBeTrue)
+
+			_, err = NewGenerator(`a`, &GeneratorArgs{Engine: AutomatonEngine, OutputLengthUnit: Bytes})
+			So(err.Error(), ShouldContainSubstring, "not Bytes")
 		})
 
 		Convey("Allows equal repeat bounds", func() {
@@ -192,6 +236,131 @@ func TestNewGenerator(t *testing.T) {
 			_, err := NewGenerator("", args)
 			So(err, ShouldNotBeNil)
 		})
+
+		Convey("Returns errors for patterns that don't match anything", func() {
+			for _, pattern := range []string{`[^\x00-\x{10FFFF}]`, `ab[^\x00-\x{10FFFF}]`} {
+				_, err := NewGenerator(pattern, nil)
+				So(err, ShouldNotBeNil)
+				So(errors.Is(err, ErrUnsatisfiable), ShouldBeTrue)
+			}
+		})
+	})
+}
+
+func TestNewGeneratorFromRegexp(t *testing.T) {
+	t.Parallel()
+
+	Convey("NewGeneratorFromRegexp", t, func() {
+		Convey("Parses patterns like regexp.Compile", func() {
+			re := regexp.MustCompile(`\d{3}-\w+`)
+			gen, err := NewGeneratorFromRegexp(re, &GeneratorArgs{MaxUnboundedRepeatCount: 5})
+			So(err, ShouldBeNil)
+			So(gen.String(), ShouldEqual, `[0-9][0-9][0-9]-[0-9A-Z_a-z]+`)
+			for i := 0; i < SampleSize; i++ {
+				So(re.MatchString(gen.Generate()), ShouldBeTrue)
+			}
+		})
+
+		Convey("Ignores Flags and Dialect", func() {
+			re := regexp.MustCompile(`(?i)a\pL`)
+			gen, err := NewGeneratorFromRegexp(re, &GeneratorArgs{Flags: syntax.Literal, Dialect: PCRE})
+			So(err, ShouldBeNil)
+			So(re.MatchString(gen.Generate()), ShouldBeTrue)
+		})
+
+		Convey("Finds matches in documents with the regexp", func() {
+			re := regexp.MustCompile(`a|ab`)
+			re.Longest()
+			gen, err := NewGeneratorFromRegexp(re, nil)
+			So(err, ShouldBeNil)
+			doc, err := gen.(ExtendedGenerator).GenerateDocument(&DocumentArgs{Separators: []string{""}, Background: "abc"})
+			So(err, ShouldBeNil)
+			So(re.FindAllStringIndex(doc.Text, -1), ShouldResemble, doc.Matches)
+		})
+
+		Convey("Forwards errors", func() {
+			_, err := NewGeneratorFromRegexp(regexp.MustCompile(`a`), &GeneratorArgs{Engine: Engine(42)})
+			So(errors.Is(err, ErrInvalidArgs), ShouldBeTrue)
+
+			// POSIX classes never match '\n'.
+			_, err = NewGeneratorFromRegexp(regexp.MustCompilePOSIX(`[^\x00-\x09\x0b-\x{10FFFF}]`), nil)
+			So(errors.Is(err, ErrUnsatisfiable), ShouldBeTrue)
+		})
+
+		Convey("Only generates strings that POSIX regexps match", func() {
+			re := regexp.MustCompilePOSIX(`[^\x01-\x08\x0b-\x{10FFFF}]`)
+			gen, err := NewGeneratorFromRegexp(re, nil)
+			So(err, ShouldBeNil)
+			for i := 0; i < SampleSize; i++ {
+				So(gen.Generate(), ShouldEqual, "\t")
+			}
+
+			values, err := gen.(ExtendedGenerator).GenerateUnique(1)
+			So(err, ShouldBeNil)
+			So(values, ShouldResemble, []string{"\t"})
+
+			// Leftmost-first matching would only find "a".
+			re = regexp.MustCompile(`a|ab`)
+			gen, err = NewGeneratorFromRegexp(re, nil)
+			So(err, ShouldBeNil)
+			values, err = gen.(ExtendedGenerator).GenerateUnique(2)
+			So(err, ShouldBeNil)
+			So(values, ShouldHaveLength, 2)
+		})
+
+		Convey("Doesn't check custom capture groups", func() {
+			providers := NewProviderRegistry()
+			providers.Register("id", Counter(1))
+			gen, err := NewGeneratorFromRegexp(regexp.MustCompile(`id=(?P<id>\d{4})`), &GeneratorArgs{Providers: providers})
+			So(err, ShouldBeNil)
+			So(gen.Generate(), ShouldEqual, "id=0001")
+
+			gen, err = NewGeneratorFromRegexp(regexp.MustCompile(`(a)`), &GeneratorArgs{
+				CaptureGroupHandler: func(index int, name string, group *syntax.Regexp, generator Generator, args *GeneratorArgs) string {
+					return "X"
+				},
+			})
+			So(err, ShouldBeNil)
+			So(gen.Generate(), ShouldEqual, "X")
+		})
+
+		Convey("Only checks patterns that depend on the flags", func() {
+			So(dependsOnFlags(`[^a]`), ShouldBeTrue)
+			So(dependsOnFlags(`^a$`), ShouldBeTrue)
+			So(dependsOnFlags(`a|ab`), ShouldBeFalse)
+			So(dependsOnFlags(`\d+`), ShouldBeFalse)
+		})
+	})
+
+	Convey("NewGeneratorFromSyntax", t, func() {
+		Convey("Generates strings from an expression tree", func() {
+			re := &syntax.Regexp{Op: syntax.OpConcat, Sub: []*syntax.Regexp{
+				{Op: syntax.OpLiteral, Rune: []rune("id-")},
+				{Op: syntax.OpRepeat, Min: 2, Max: 4, Sub: []*syntax.Regexp{
+					{Op: syntax.OpCharClass, Rune: []rune{'0', '9'}},
+				}},
+			}}
+			gen, err := NewGeneratorFromSyntax(re, &GeneratorArgs{Flags: syntax.Literal})
+			So(err, ShouldBeNil)
+			matcher := regexp.MustCompile(`^id-[0-9]{2,4}$`)
+			for i := 0; i < SampleSize; i++ {
+				So(matcher.MatchString(gen.Generate()), ShouldBeTrue)
+			}
+			So(re.String(), ShouldEqual, `id-[0-9]{2,4}`)
+		})
+
+		Convey("Supports the automaton engine", func() {
+			re, err := syntax.Parse(`[ab]{4}`, syntax.Perl)
+			So(err, ShouldBeNil)
+			gen, err := NewGeneratorFromSyntax(re, &GeneratorArgs{MustNotMatch: []string{`.*b.*`}})
+			So(err, ShouldBeNil)
+			So(gen.Generate(), ShouldEqual, "aaaa")
+		})
+
+		Convey("Returns errors", func() {
+			_, err := NewGeneratorFromSyntax(&syntax.Regexp{Op: syntax.OpNoMatch}, nil)
+			So(errors.Is(err, ErrUnsatisfiable), ShouldBeTrue)
+		})
 	})
 }
 
@@ -353,6 +522,10 @@ func TestGenNegativeCharClass(t *testing.T) {
 
 	Convey("NegativeCharClass", t, func() {
 		ConveyGeneratesStringMatchingItself(nil, "[^a-zA-Z0-9]")
+
+		Convey("Ignores null bytes", func() {
+			ConveyGeneratesStringMatching(nil, `[\x00a]`, `^a$`)
+		})
 	})
 }
 
@@ -563,6 +736,17 @@ func ConveyGeneratesStringMatching(args *GeneratorArgs, pattern string, expected
 	})
 }
 
+// newTestGenerator creates a generator for pattern, which must succeed. If args is nil, the pattern is parsed with
+// syntax.Perl; otherwise args is used as is.
+func newTestGenerator(pattern string, args *GeneratorArgs) ExtendedGenerator {
+	if args == nil {
+		args = &GeneratorArgs{Flags: syntax.Perl}
+	}
+	gen, err := NewGenerator(pattern, args)
+	So(err, ShouldBeNil)
+	return gen.(ExtendedGenerator)
+}
+
 func ShouldGenerateStringMatching(actual interface{}, expected ...interface{}) string {
 	return ShouldGenerateStringMatchingTimes(actual, expected[0], expected[1], SampleSize)
 }
@@ -577,6 +761,7 @@ func ShouldGenerateStringMatchingTimes(actual interface{}, expected ...interface
 	if err != nil {
 		panic(err)
 	}
+	automaton := newCrossValidationGenerator(pattern, args)
 
 	for i := 0; i < times; i++ {
 		result := generator.Generate()
@@ -588,11 +773,48 @@ func ShouldGenerateStringMatchingTimes(actual interface{}, expected ...interface
 			return fmt.Sprintf("string “%s” generated from /%s/ did not match /%s/.",
 				result, pattern, expectedPattern)
 		}
+		if automaton == nil {
+			continue
+		}
+
+		if !automaton.accepts(result) {
+			return fmt.Sprintf("string “%s” generated from /%s/ was not accepted by the automaton engine.",
+				result, pattern)
+		}
+		result = automaton.Generate()
+		if matched, _ := regexp.MatchString(expectedPattern, result); !matched {
+			return fmt.Sprintf("string “%s” generated from /%s/ by the automaton engine did not match /%s/.",
+				result, pattern, expectedPattern)
+		}
 	}
 
 	return ""
 }
 
+// newCrossValidationGenerator returns a generator for pattern that uses AutomatonEngine, to check it against the
+// default engine. Returns nil if the engines aren't comparable: the automaton engine ignores capture group
+// handlers, doesn't support args for individual expressions, and respects assertions the default engine ignores
+// (e.g. `$abc^` doesn't match anything).
+func newCrossValidationGenerator(pattern string, args *GeneratorArgs) *internalGenerator {
+	automatonArgs := GeneratorArgs{}
+	if args != nil {
+		automatonArgs = *args
+	}
+	if automatonArgs.CaptureGroupHandler != nil || automatonArgs.Providers != nil {
+		return nil
+	}
+	automatonArgs.Engine = AutomatonEngine
+
+	generator, err := NewGenerator(pattern, &automatonArgs)
+	if errors.Is(err, ErrUnsatisfiable) || errors.Is(err, ErrUnsupportedOp) {
+		return nil
+	}
+	if err != nil {
+		panic(err)
+	}
+	return generator.(*internalGenerator)
+}
+
 func generateLenHistogram(regexp string, maxLen int, args *GeneratorArgs) (counts []int) {
 	generator, err := NewGenerator(regexp, args)
 	if err != nil {
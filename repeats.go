@@ -0,0 +1,262 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"regexp/syntax"
+	"strconv"
+	"strings"
+)
+
+// RepeatCapStrategy is how GeneratorArgs.MaxRepeatCount is applied to repeats with explicit bounds.
+type RepeatCapStrategy int
+
+const (
+	// ClampRepeats lowers the maximum of every repeat above the cap to the cap, e.g. with a cap of 100, "a{1,255}"
+	// and "a{1,65535}" both become "a{1,100}".
+	ClampRepeats RepeatCapStrategy = iota
+	// ScaleRepeats scales the maximums of all the repeats in the pattern by the same factor, so the largest one is
+	// the cap, e.g. with a cap of 100, "a{1,255}" and "b{1,65535}" become "a{1,1}" and "b{1,100}". Maximums aren't
+	// scaled below their minimums. Repeats aren't scaled if none are above the cap.
+	ScaleRepeats
+)
+
+var repeatCapStrategyNames = map[RepeatCapStrategy]string{
+	ClampRepeats: "ClampRepeats",
+	ScaleRepeats: "ScaleRepeats",
+}
+
+func (s RepeatCapStrategy) String() string {
+	if name, ok := repeatCapStrategyNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("RepeatCapStrategy(%d)", int(s))
+}
+
+// capUnboundedRepeats lowers MaxUnboundedRepeatCount to MaxRepeatCount, or returns an error if
+// MinUnboundedRepeatCount is above it.
+func (a *GeneratorArgs) capUnboundedRepeats() error {
+	if a.MaxRepeatCount == 0 {
+		return nil
+	}
+	if a.MinUnboundedRepeatCount > a.MaxRepeatCount {
+		return newError(ErrInvalidArgs, nil, "MinUnboundedRepeatCount(%d) > MaxRepeatCount(%d)",
+			a.MinUnboundedRepeatCount, a.MaxRepeatCount)
+	}
+	if a.MaxUnboundedRepeatCount > a.MaxRepeatCount {
+		a.MaxUnboundedRepeatCount = a.MaxRepeatCount
+	}
+	return nil
+}
+
+// capRepeats returns a copy of regexp with the maximums of its repeats lowered to MaxRepeatCount, using
+// RepeatCapStrategy, or an error if the minimum of a repeat is above MaxRepeatCount. regexp isn't modified.
+func (a *GeneratorArgs) capRepeats(regexp *syntax.Regexp) (*syntax.Regexp, error) {
+	if a.MaxRepeatCount == 0 {
+		return regexp, nil
+	}
+	limit := int(a.MaxRepeatCount)
+
+	largest := largestRepeat(regexp)
+	if largest <= limit {
+		return regexp, checkRepeatMinimums(regexp, limit)
+	}
+
+	var capped func(re *syntax.Regexp) (*syntax.Regexp, error)
+	capped = func(re *syntax.Regexp) (*syntax.Regexp, error) {
+		result := *re
+		result.Sub = make([]*syntax.Regexp, len(re.Sub))
+		for i, sub := range re.Sub {
+			var err error
+			if result.Sub[i], err = capped(sub); err != nil {
+				return nil, err
+			}
+		}
+
+		if re.Op != syntax.OpRepeat {
+			return &result, nil
+		}
+		if re.Min > limit {
+			return nil, repeatMinimumError(re, limit)
+		}
+		result.Max = a.cappedMax(re.Min, re.Max, largest)
+		return &result, nil
+	}
+	return capped(regexp)
+}
+
+// cappedMax returns the maximum of the repeat {min,max} capped using RepeatCapStrategy, where largest is the largest
+// maximum in the pattern. Unbounded repeats (max < 0) are left alone.
+func (a *GeneratorArgs) cappedMax(min, max, largest int) int {
+	limit := int(a.MaxRepeatCount)
+	switch {
+	case max < 0:
+		return max
+	case a.RepeatCapStrategy == ScaleRepeats:
+		max = int(int64(max) * int64(limit) / int64(largest))
+	case max > limit:
+		max = limit
+	}
+	if max < min {
+		max = min
+	}
+	return max
+}
+
+// The largest repeat count regexp/syntax accepts.
+const maxParsedRepeatCount = 1000
+
+// A repeat in the text of a pattern, e.g. "{1,5}".
+type repeatText struct {
+	start, end int
+	min, max   int
+}
+
+var repeatTextPattern = regexp.MustCompile(`^\{([0-9]+)(,([0-9]*))?\}`)
+
+/*
+capRepeatText caps the repeats in pattern, as capRepeats does, if any of them are above the limit of 1000 that
+regexp/syntax enforces, e.g. "{1,65535}". Those patterns couldn't be parsed otherwise. Patterns without such
+repeats are returned unchanged, and capped by capRepeats once they're parsed.
+*/
+func (a *GeneratorArgs) capRepeatText(pattern string) (string, error) {
+	if a.MaxRepeatCount == 0 || a.Flags&syntax.Literal != 0 {
+		return pattern, nil
+	}
+	limit := int(a.MaxRepeatCount)
+
+	repeats := findRepeatText(pattern, a.Flags&syntax.PerlX != 0)
+	largest := 0
+	for _, r := range repeats {
+		if r.max > largest {
+			largest = r.max
+		}
+	}
+	if largest <= maxParsedRepeatCount {
+		return pattern, nil
+	}
+
+	var result bytes.Buffer
+	last := 0
+	for _, r := range repeats {
+		expr := pattern[r.start:r.end]
+		if r.min > limit {
+			return "", &Error{Kind: ErrInvalidArgs, Expr: expr, Offset: -1,
+				Msg: fmt.Sprintf("%s repeats at least %d times, more than MaxRepeatCount(%d)", expr, r.min, limit)}
+		}
+		if r.max >= 0 && r.max < r.min {
+			// Invalid, and left for syntax.Parse to report.
+			continue
+		}
+		if max := a.cappedMax(r.min, r.max, largest); max != r.max {
+			result.WriteString(pattern[last:r.start])
+			fmt.Fprintf(&result, "{%d,%d}", r.min, max)
+			last = r.end
+		}
+	}
+	result.WriteString(pattern[last:])
+	return result.String(), nil
+}
+
+// findRepeatText returns the repeats in pattern, skipping escapes, character classes, and quoted text if perlX.
+// The maximum of unbounded repeats is -1, and counts that don't fit in an int are returned as math.MaxInt32.
+func findRepeatText(pattern string, perlX bool) []repeatText {
+	var repeats []repeatText
+	for i := 0; i < len(pattern); {
+		switch pattern[i] {
+		case '\\':
+			if perlX && strings.HasPrefix(pattern[i:], `\Q`) {
+				end := strings.Index(pattern[i+2:], `\E`)
+				if end < 0 {
+					return repeats
+				}
+				i += 2 + end + 2
+				continue
+			}
+			i += 2
+
+		case '[':
+			i = skipCharClass(pattern, i)
+
+		case '{':
+			match := repeatTextPattern.FindStringSubmatchIndex(pattern[i:])
+			if match == nil {
+				i++
+				continue
+			}
+			r := repeatText{start: i, end: i + match[1], min: parseCount(pattern[i+match[2] : i+match[3]])}
+			switch {
+			case match[4] < 0:
+				r.max = r.min
+			case match[7] > match[6]:
+				r.max = parseCount(pattern[i+match[6] : i+match[7]])
+			default:
+				r.max = -1
+			}
+			repeats = append(repeats, r)
+			i = r.end
+
+		default:
+			i++
+		}
+	}
+	return repeats
+}
+
+func parseCount(digits string) int {
+	count, err := strconv.Atoi(digits)
+	if err != nil {
+		return math.MaxInt32
+	}
+	return count
+}
+
+// largestRepeat returns the largest explicit maximum of the repeats in regexp.
+func largestRepeat(regexp *syntax.Regexp) int {
+	largest := 0
+	if regexp.Op == syntax.OpRepeat {
+		largest = regexp.Max
+	}
+	for _, sub := range regexp.Sub {
+		if max := largestRepeat(sub); max > largest {
+			largest = max
+		}
+	}
+	return largest
+}
+
+// checkRepeatMinimums returns an error if the minimum of a repeat in regexp is above limit.
+func checkRepeatMinimums(regexp *syntax.Regexp, limit int) error {
+	if regexp.Op == syntax.OpRepeat && regexp.Min > limit {
+		return repeatMinimumError(regexp, limit)
+	}
+	for _, sub := range regexp.Sub {
+		if err := checkRepeatMinimums(sub, limit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func repeatMinimumError(repeat *syntax.Regexp, limit int) error {
+	return newError(ErrInvalidArgs, repeat, "/%s/ repeats at least %d times, more than MaxRepeatCount(%d)",
+		repeat, repeat.Min, limit)
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"errors"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMaxRepeatCount(t *testing.T) {
+	t.Parallel()
+
+	Convey("MaxRepeatCount", t, func() {
+		Convey("Clamps bounded and unbounded repeats", func() {
+			gen, err := NewGenerator(`a{1,1000}-b{2,5}-c*`, &GeneratorArgs{MaxRepeatCount: 10})
+			So(err, ShouldBeNil)
+			So(gen.(ExtendedGenerator).MaxLength(Runes), ShouldEqual, 10+1+5+1+10)
+
+			matcher := regexp.MustCompile(`^a{1,10}-b{2,5}-c{0,10}$`)
+			for i := 0; i < SampleSize; i++ {
+				So(matcher.MatchString(gen.Generate()), ShouldBeTrue)
+			}
+		})
+
+		Convey("Scales repeats", func() {
+			gen, err := NewGenerator(`a{1,1000}-b{0,100}-c{3,5}`, &GeneratorArgs{
+				MaxRepeatCount:    10,
+				RepeatCapStrategy: ScaleRepeats,
+			})
+			So(err, ShouldBeNil)
+			So(gen.(ExtendedGenerator).MaxLength(Runes), ShouldEqual, 10+1+1+1+3)
+			So(gen.(ExtendedGenerator).MinLength(Runes), ShouldEqual, 1+1+0+1+3)
+		})
+
+		Convey("Caps repeats above the limit of regexp/syntax", func() {
+			for _, pattern := range []string{`a{1,65535}`, `a{1,100000}`, `(a{1,65535}){2}`} {
+				gen, err := NewGenerator(pattern, &GeneratorArgs{MaxRepeatCount: 10})
+				So(err, ShouldBeNil)
+				So(gen.(ExtendedGenerator).MaxLength(Runes) <= 20, ShouldBeTrue)
+			}
+
+			gen, err := NewGenerator(`a{1,65535}-b{0,6554}-c{3,5}`, &GeneratorArgs{
+				MaxRepeatCount:    10,
+				RepeatCapStrategy: ScaleRepeats,
+			})
+			So(err, ShouldBeNil)
+			So(gen.(ExtendedGenerator).MaxLength(Runes), ShouldEqual, 10+1+1+1+3)
+
+			gen, err = NewGenerator(`a{1,100000}`, &GeneratorArgs{MaxRepeatCount: 10})
+			So(err, ShouldBeNil)
+			for i := 0; i < SampleSize; i++ {
+				So(len(gen.Generate()), ShouldBeBetweenOrEqual, 1, 10)
+			}
+
+			_, err = NewGenerator(`a{1,65535}`, nil)
+			So(errors.Is(err, ErrParse), ShouldBeTrue)
+			_, err = NewGenerator(`b{2000}c{1,65535}`, &GeneratorArgs{MaxRepeatCount: 10})
+			So(errors.Is(err, ErrInvalidArgs), ShouldBeTrue)
+		})
+
+		Convey("Doesn't scale repeats below the cap", func() {
+			gen, err := NewGenerator(`a{1,5}`, &GeneratorArgs{MaxRepeatCount: 10, RepeatCapStrategy: ScaleRepeats})
+			So(err, ShouldBeNil)
+			So(gen.(ExtendedGenerator).MaxLength(Runes), ShouldEqual, 5)
+		})
+
+		Convey("Applies to the automaton engine and lookarounds", func() {
+			for _, pattern := range []string{`x{2,500}`, `(?=x)x{2,500}`} {
+				gen, err := NewGenerator(pattern, &GeneratorArgs{
+					Flags:          syntax.Perl,
+					Engine:         AutomatonEngine,
+					MaxRepeatCount: 4,
+				})
+				So(err, ShouldBeNil)
+				for i := 0; i < SampleSize; i++ {
+					So(len(gen.Generate()), ShouldBeBetweenOrEqual, 2, 4)
+				}
+			}
+		})
+
+		Convey("Doesn't apply to constraints", func() {
+			gen, err := NewGenerator(`a{2,3}`, &GeneratorArgs{MaxRepeatCount: 3, MustMatch: []string{`a{1,50}`}})
+			So(err, ShouldBeNil)
+			So(strings.Trim(gen.Generate(), "a"), ShouldBeEmpty)
+		})
+
+		Convey("Only caps repeats", func() {
+			gen, err := NewGenerator(`\{1,500}[{1,500}]\Q{1,500}\Ex{1,500}[[:alpha:]{]{1,500}`, &GeneratorArgs{
+				Flags:          syntax.Perl,
+				MaxRepeatCount: 100,
+			})
+			So(err, ShouldBeNil)
+			So(gen.(ExtendedGenerator).MaxLength(Runes), ShouldEqual, 7+1+7+100+100)
+		})
+
+		Convey("Doesn't apply to literal patterns", func() {
+			for _, pattern := range []string{`a{1,5000}`, `x{2000}`} {
+				gen, err := NewGenerator(pattern, &GeneratorArgs{Flags: syntax.Literal, MaxRepeatCount: 10})
+				So(err, ShouldBeNil)
+				So(gen.Generate(), ShouldEqual, pattern)
+			}
+		})
+
+		Convey("Applies to expressions from NewGeneratorFromSyntax", func() {
+			re := &syntax.Regexp{Op: syntax.OpRepeat, Min: 2, Max: 100000, Sub: []*syntax.Regexp{
+				{Op: syntax.OpLiteral, Rune: []rune{'a'}},
+			}}
+			gen, err := NewGeneratorFromSyntax(re, &GeneratorArgs{MaxRepeatCount: 7})
+			So(err, ShouldBeNil)
+			So(gen.(ExtendedGenerator).MaxLength(Runes), ShouldEqual, 7)
+			So(re.Max, ShouldEqual, 100000)
+		})
+
+		Convey("Returns an error if the cap is below a minimum", func() {
+			_, err := NewGenerator(`ab{20,30}`, &GeneratorArgs{MaxRepeatCount: 10})
+			So(errors.Is(err, ErrInvalidArgs), ShouldBeTrue)
+			var regenErr *Error
+			So(errors.As(err, &regenErr), ShouldBeTrue)
+			So(regenErr.Expr, ShouldEqual, `b{20,30}`)
+			So(regenErr.Offset, ShouldEqual, 1)
+
+			_, err = NewGenerator(`b{20}`, &GeneratorArgs{MaxRepeatCount: 30, RepeatCapStrategy: ScaleRepeats})
+			So(err, ShouldBeNil)
+			_, err = NewGenerator(`b{20}c{1,100}`, &GeneratorArgs{MaxRepeatCount: 10, RepeatCapStrategy: ScaleRepeats})
+			So(errors.Is(err, ErrInvalidArgs), ShouldBeTrue)
+
+			_, err = NewGenerator(`a*`, &GeneratorArgs{MaxRepeatCount: 3, MinUnboundedRepeatCount: 4})
+			So(errors.Is(err, ErrInvalidArgs), ShouldBeTrue)
+			_, err = NewGenerator(`(?P<g>a*)`, &GeneratorArgs{
+				Flags:          syntax.Perl,
+				MaxRepeatCount: 3,
+				Groups:         map[string]GroupArgs{"g": {MinUnboundedRepeatCount: 4, MaxUnboundedRepeatCount: 5}},
+			})
+			So(errors.Is(err, ErrInvalidArgs), ShouldBeTrue)
+
+			_, err = NewGenerator(`a`, &GeneratorArgs{RepeatCapStrategy: RepeatCapStrategy(42)})
+			So(errors.Is(err, ErrInvalidArgs), ShouldBeTrue)
+
+			_, err = NewGenerator(`a{1500}`, &GeneratorArgs{MaxRepeatCount: 2000})
+			So(errors.Is(err, ErrInvalidArgs), ShouldBeTrue)
+			So(err.Error(), ShouldContainSubstring, "MaxRepeatCount(2000)")
+		})
+	})
+}
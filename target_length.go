@@ -0,0 +1,372 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"bytes"
+	"math/big"
+	"math/rand"
+	"regexp/syntax"
+)
+
+/*
+lengthSolver generates strings of exact lengths.
+
+It uses dynamic programming over the generator tree to find the set of lengths, up to limit, that each generator
+can produce. Sets of lengths are stored as bitsets in big.Ints: bit n is set if the generator can produce a string
+of length n. Generating a string of length n then only makes choices (branches, repeat counts, and the lengths of
+sub-expressions) that can still reach n.
+
+Computing the sets takes time proportional to limit² for each repeat, so limits in the thousands are cheap but
+limits in the millions are not.
+*/
+type lengthSolver struct {
+	unit  LengthUnit
+	limit int
+	mask  *big.Int
+
+	lengths map[*internalGenerator]*big.Int
+	// For concatenations, suffixes[gen][i] is the set of lengths subs[i:] can produce.
+	suffixes map[*internalGenerator][]*big.Int
+	// For repeats, parts[gen][j] is the set of lengths j non-empty repetitions can produce.
+	parts map[*internalGenerator][]*big.Int
+}
+
+func newLengthSolver(args *GeneratorArgs, limit int) *lengthSolver {
+	mask := new(big.Int).Lsh(big.NewInt(1), uint(limit+1))
+	return &lengthSolver{
+		unit:     args.OutputLengthUnit,
+		limit:    limit,
+		mask:     mask.Sub(mask, big.NewInt(1)),
+		lengths:  make(map[*internalGenerator]*big.Int),
+		suffixes: make(map[*internalGenerator][]*big.Int),
+		parts:    make(map[*internalGenerator][]*big.Int),
+	}
+}
+
+/*
+GenerateLength returns a string of exactly length runes, or bytes if OutputLengthUnit is Bytes.
+Returns an error if the generator can't produce a string of that length. On the tree engine, the lengths the
+pattern can produce are solved up to length first, which takes time proportional to its square, so lengths above
+MaxUniformOutputLength return an ErrInvalidArgs error.
+
+Capture groups are generated by CaptureGroupHandler as usual, with a generator that produces strings of the
+length assigned to the group. Handlers that ignore their generator may change the length of the result.
+*/
+func (gen *internalGenerator) GenerateLength(length int) (string, error) {
+	if length < 0 {
+		return "", newError(ErrInvalidArgs, nil, "invalid length %d", length)
+	}
+	unsatisfiable := newError(ErrUnsatisfiable, nil, "/%s/ cannot generate strings of length %d", gen, length)
+	if gen.automaton != nil {
+		// The automaton can generate lengths outside the ones Generate chooses from, up to the size of its table.
+		tableLimit := maxAutomatonTableSize/len(gen.automaton.dfa.states) - 1
+		if gen.args.OutputLengthUnit != Runes || length > tableLimit || !gen.automaton.canGenerate(length) {
+			return "", unsatisfiable
+		}
+		return gen.automaton.generate(gen.args.rng, length), nil
+	}
+	// Check the bounds before building a table of length bits.
+	unit := gen.args.OutputLengthUnit
+	if length < gen.minLength(unit) || length > gen.maxLength(unit) {
+		return "", unsatisfiable
+	}
+	if length > MaxUniformOutputLength {
+		return "", newError(ErrInvalidArgs, nil, "length %d is more than MaxUniformOutputLength(%d)", length,
+			MaxUniformOutputLength)
+	}
+	solver := gen.lengthSolver(length)
+	if solver.of(gen.node()).Bit(length) == 0 {
+		return "", unsatisfiable
+	}
+	return solver.generate(gen, length, gen.args), nil
+}
+
+// lengthSolver returns a solver for gen with a limit of at least limit, which has already solved gen so it's safe
+// to use concurrently.
+func (gen *internalGenerator) lengthSolver(limit int) *lengthSolver {
+	gen.tables.lock.Lock()
+	defer gen.tables.lock.Unlock()
+
+	if gen.tables.solver == nil || gen.tables.solver.limit < limit {
+		solver := newLengthSolver(gen.args, limit)
+		solver.of(gen.node())
+		gen.tables.solver = solver
+	}
+	return gen.tables.solver
+}
+
+// MaxUniformOutputLength is the longest length UniformOutputLength can choose from, and the longest length
+// GenerateLength accepts on the tree engine. The lengths a pattern can produce are solved up to the maximum length,
+// which takes time proportional to its square.
+const MaxUniformOutputLength = 1 << 14
+
+// newUniformLengthGenerator replaces the GenerateFunc of gen with one that chooses a length uniformly from the
+// lengths gen can produce between args.MinOutputLength and args.MaxOutputLength, then generates a string of that
+// length. Returns an error if there are no such lengths, or if the longest one gen can produce in the bounds is
+// longer than MaxUniformOutputLength.
+func newUniformLengthGenerator(gen *internalGenerator, args *GeneratorArgs) error {
+	lo, hi := args.MinOutputLength, args.MaxOutputLength
+	if hi == 0 {
+		hi = gen.maxLength(args.OutputLengthUnit)
+		if hi == LengthOverflow {
+			return newError(ErrInvalidArgs, nil, "/%s/ has no maximum length: UniformOutputLength requires MaxOutputLength", gen)
+		}
+	}
+	if lo > hi {
+		return newError(ErrInvalidArgs, nil, "MinOutputLength(%d) > MaxOutputLength(%d)", lo, hi)
+	}
+
+	// Longer lengths are impossible, so don't build a table for them.
+	limit := hi
+	if maxLength := gen.maxLength(args.OutputLengthUnit); limit > maxLength {
+		limit = maxLength
+	}
+	if limit > MaxUniformOutputLength {
+		return newError(ErrInvalidArgs, nil,
+			"/%s/ can generate strings %d long: UniformOutputLength requires a MaxOutputLength of at most %d",
+			gen, limit, MaxUniformOutputLength)
+	}
+	solver := newLengthSolver(args, limit)
+	var lengths []int
+	possible := solver.of(gen)
+	for n := lo; n <= limit; n++ {
+		if possible.Bit(n) == 1 {
+			lengths = append(lengths, n)
+		}
+	}
+	if len(lengths) == 0 {
+		return newError(ErrUnsatisfiable, nil, "/%s/ cannot generate strings with lengths between %d and %d", gen, lo, hi)
+	}
+
+	gen.tables.solver = solver
+	gen.lengthConstrained = true
+	gen.GenerateFunc = func(args *GeneratorArgs) string {
+		return solver.generate(gen, lengths[args.rng.Intn(len(lengths))], args)
+	}
+	return nil
+}
+
+// of returns the set of lengths gen can produce, up to the limit.
+func (s *lengthSolver) of(gen *internalGenerator) *big.Int {
+	if lengths, ok := s.lengths[gen]; ok {
+		return lengths
+	}
+
+	lengths := new(big.Int)
+	switch {
+	case gen.charClass != nil:
+		if s.unit == Bytes {
+			for _, r := range gen.charClass.Ranges {
+				first, last := encodedRuneLen(r.Start), encodedRuneLen(r.Start+rune(r.Size-1))
+				for n := first; n <= last; n++ {
+					lengths.SetBit(lengths, n, 1)
+				}
+			}
+		} else {
+			lengths.SetBit(lengths, 1, 1)
+		}
+
+	case gen.regexp.Op == syntax.OpLiteral:
+		lengths.SetBit(lengths, literalLength(gen.regexp.Rune, s.unit), 1)
+
+	case gen.regexp.Op == syntax.OpConcat:
+		suffixes := make([]*big.Int, len(gen.subs)+1)
+		suffixes[len(gen.subs)] = big.NewInt(1)
+		for i := len(gen.subs) - 1; i >= 0; i-- {
+			suffixes[i] = s.sum(s.of(gen.subs[i]), suffixes[i+1])
+		}
+		s.suffixes[gen] = suffixes
+		lengths = suffixes[0]
+
+	case gen.regexp.Op == syntax.OpAlternate:
+		for _, sub := range gen.subs {
+			lengths.Or(lengths, s.of(sub))
+		}
+
+	case gen.regexp.Op == syntax.OpCapture:
+		lengths = s.of(gen.subs[0])
+
+	case gen.isRepeat():
+		// Every non-empty repetition is at least 1 long, so there are at most limit of them.
+		nonEmpty := new(big.Int).SetBit(s.of(gen.subs[0]), 0, 0)
+		maxParts := gen.max
+		if maxParts > s.limit {
+			maxParts = s.limit
+		}
+
+		parts := []*big.Int{big.NewInt(1)}
+		for j := 1; j <= maxParts; j++ {
+			next := s.sum(parts[j-1], nonEmpty)
+			if next.Sign() == 0 {
+				break
+			}
+			parts = append(parts, next)
+		}
+		s.parts[gen] = parts
+
+		for j := s.minParts(gen); j < len(parts); j++ {
+			lengths.Or(lengths, parts[j])
+		}
+
+	default:
+		// Empty matches and assertions.
+		lengths.SetBit(lengths, 0, 1)
+	}
+
+	lengths.And(lengths, s.mask)
+	s.lengths[gen] = lengths
+	return lengths
+}
+
+// minParts returns the minimum number of non-empty repetitions a repeat can generate.
+func (s *lengthSolver) minParts(gen *internalGenerator) int {
+	if s.of(gen.subs[0]).Bit(0) == 1 {
+		// The remaining repetitions can be empty.
+		return 0
+	}
+	return gen.min
+}
+
+// sum returns the set of lengths {a + b} for all a in as and b in bs, up to the limit.
+func (s *lengthSolver) sum(as, bs *big.Int) *big.Int {
+	result := new(big.Int)
+	shifted := new(big.Int)
+	for b := 0; b < bs.BitLen(); b++ {
+		if bs.Bit(b) == 1 {
+			result.Or(result, shifted.Lsh(as, uint(b)))
+		}
+	}
+	return result.And(result, s.mask)
+}
+
+// generate returns a string of length n from gen, which must be able to produce one, using the rng and provider
+// states in args.
+func (s *lengthSolver) generate(gen *internalGenerator, n int, args *GeneratorArgs) string {
+	var result bytes.Buffer
+	s.write(&result, gen.node(), n, args)
+	return result.String()
+}
+
+func (s *lengthSolver) write(result *bytes.Buffer, gen *internalGenerator, n int, args *GeneratorArgs) {
+	switch {
+	case gen.charClass != nil:
+		if s.unit == Bytes {
+			result.WriteRune(gen.charClass.randomRuneWithLength(args.rng, n, n))
+		} else {
+			result.WriteRune(gen.charClass.GetRuneAt(args.rng.Int31n(gen.charClass.TotalSize)))
+		}
+
+	case gen.regexp.Op == syntax.OpLiteral:
+		result.WriteString(runesToString(gen.regexp.Rune...))
+
+	case gen.regexp.Op == syntax.OpConcat:
+		s.of(gen)
+		suffixes := s.suffixes[gen]
+		for i, sub := range gen.subs {
+			length := s.choose(s.of(sub), suffixes[i+1], n, args.rng)
+			s.write(result, sub, length, args)
+			n -= length
+		}
+
+	case gen.regexp.Op == syntax.OpAlternate:
+		var candidates []*internalGenerator
+		for _, sub := range gen.subs {
+			if s.of(sub).Bit(n) == 1 {
+				candidates = append(candidates, sub)
+			}
+		}
+		s.write(result, candidates[args.rng.Intn(len(candidates))], n, args)
+
+	case gen.regexp.Op == syntax.OpCapture:
+		sub := gen.subs[0]
+		if !args.customCaptureGroups {
+			s.write(result, sub, n, args)
+			break
+		}
+		exact := &exactLengthSubGenerator{sub.withArgs(sub.args.withStateOf(args)), s, n}
+		result.WriteString(handleCapture(sub.args, gen.regexp.Cap-1, gen.regexp.Name, gen.regexp.Sub[0], exact, args))
+
+	case gen.isRepeat():
+		s.writeRepeat(result, gen, n, args)
+	}
+
+	// Empty matches and assertions write nothing. gen may be the uniform length generator itself, so don't call
+	// gen.Generate.
+}
+
+// writeRepeat chooses a number of non-empty repetitions that can produce n, then pads them with empty
+// repetitions (if the sub-expression can produce the empty string) to a random count within the repeat's bounds.
+func (s *lengthSolver) writeRepeat(result *bytes.Buffer, gen *internalGenerator, n int, args *GeneratorArgs) {
+	s.of(gen)
+	parts := s.parts[gen]
+	sub := gen.subs[0]
+	nonEmpty := new(big.Int).SetBit(s.of(sub), 0, 0)
+
+	var counts []int
+	for j := s.minParts(gen); j < len(parts); j++ {
+		if parts[j].Bit(n) == 1 {
+			counts = append(counts, j)
+		}
+	}
+	j := counts[args.rng.Intn(len(counts))]
+
+	count := j
+	if s.of(sub).Bit(0) == 1 {
+		minCount := gen.min
+		if minCount < j {
+			minCount = j
+		}
+		count = minCount + args.rng.Intn(gen.max-minCount+1)
+	}
+
+	lengths := make([]int, count)
+	for i := 0; i < j; i++ {
+		lengths[i] = s.choose(nonEmpty, parts[j-i-1], n, args.rng)
+		n -= lengths[i]
+	}
+	args.rng.Shuffle(len(lengths), func(a, b int) {
+		lengths[a], lengths[b] = lengths[b], lengths[a]
+	})
+
+	for _, length := range lengths {
+		s.write(result, sub, length, args)
+	}
+}
+
+// choose returns a random length l from lengths such that n-l is in rest.
+func (s *lengthSolver) choose(lengths, rest *big.Int, n int, rng *rand.Rand) int {
+	var candidates []int
+	for l := 0; l <= n && l < lengths.BitLen(); l++ {
+		if lengths.Bit(l) == 1 && rest.Bit(n-l) == 1 {
+			candidates = append(candidates, l)
+		}
+	}
+	return candidates[rng.Intn(len(candidates))]
+}
+
+// exactLengthSubGenerator is passed to capture group handlers by lengthSolver, so the group is generated with the
+// length assigned to it. Clones generate the group without the length.
+type exactLengthSubGenerator struct {
+	*internalGenerator
+	solver *lengthSolver
+	length int
+}
+
+func (gen *exactLengthSubGenerator) Generate() string {
+	return gen.solver.generate(gen.internalGenerator, gen.length, gen.args)
+}
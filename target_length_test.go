@@ -0,0 +1,147 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"errors"
+	"regexp"
+	"regexp/syntax"
+	"testing"
+	"unicode/utf8"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTargetLength(t *testing.T) {
+	t.Parallel()
+
+	Convey("GenerateLength", t, func() {
+		Convey("Generates strings of the exact length", func() {
+			patterns := []string{`[a-z]+`, `(a|bb)*`, `x(ab|cde)+y?`, `(\w+ ){1,50}`, `a*b*c*`}
+			for _, pattern := range patterns {
+				gen := newTestGenerator(pattern, nil)
+				matcher := regexp.MustCompile(`^(?:` + pattern + `)$`)
+				for _, length := range []int{7, 10, 100} {
+					for i := 0; i < 10; i++ {
+						value, err := gen.GenerateLength(length)
+						So(err, ShouldBeNil)
+						So(utf8.RuneCountInString(value), ShouldEqual, length)
+						So(matcher.MatchString(value), ShouldBeTrue)
+					}
+				}
+			}
+		})
+
+		Convey("Measures bytes", func() {
+			gen := newTestGenerator(`[aé€]{1,20}`, &GeneratorArgs{OutputLengthUnit: Bytes})
+			for _, length := range []int{1, 2, 3, 5, 30} {
+				value, err := gen.GenerateLength(length)
+				So(err, ShouldBeNil)
+				So(len(value), ShouldEqual, length)
+				So(regexp.MustCompile(`^[aé€]{1,20}$`).MatchString(value), ShouldBeTrue)
+			}
+		})
+
+		Convey("Passes the length to capture groups", func() {
+			gen := newTestGenerator(`(?P<word>[a-z]+)-\d{1,3}`, &GeneratorArgs{
+				Flags: syntax.Perl,
+				CaptureGroupHandler: func(index int, name string, group *syntax.Regexp, generator Generator, args *GeneratorArgs) string {
+					return generator.Generate()
+				},
+			})
+			for i := 0; i < SampleSize; i++ {
+				value, err := gen.GenerateLength(6)
+				So(err, ShouldBeNil)
+				So(value, ShouldHaveLength, 6)
+			}
+		})
+
+		Convey("Returns an error if no string has the length", func() {
+			gen := newTestGenerator(`(ab)*`, nil)
+			_, err := gen.GenerateLength(3)
+			So(err, ShouldNotBeNil)
+
+			value, err := gen.GenerateLength(4)
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, "abab")
+
+			_, err = newTestGenerator(`[a-z]{5}`, nil).GenerateLength(4)
+			So(err, ShouldNotBeNil)
+			_, err = newTestGenerator(`a`, nil).GenerateLength(-1)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Rejects lengths outside the bounds without building a table", func() {
+			gen := newTestGenerator(`ab`, nil)
+			_, err := gen.GenerateLength(1 << 33)
+			So(errors.Is(err, ErrUnsatisfiable), ShouldBeTrue)
+			So(gen.(*internalGenerator).tables.solver, ShouldBeNil)
+
+			_, err = newTestGenerator(`[ab]*`, &GeneratorArgs{Engine: AutomatonEngine}).GenerateLength(1 << 33)
+			So(errors.Is(err, ErrUnsatisfiable), ShouldBeTrue)
+
+			_, err = newTestGenerator(`(a*b?)*`, nil).GenerateLength(20000)
+			So(errors.Is(err, ErrInvalidArgs), ShouldBeTrue)
+
+			gen = newTestGenerator(`ab`, &GeneratorArgs{UniformOutputLength: true, MaxOutputLength: 1 << 33})
+			So(gen.Generate(), ShouldEqual, "ab")
+		})
+	})
+
+	Convey("UniformOutputLength", t, func() {
+		Convey("Spreads lengths uniformly", func() {
+			gen := newTestGenerator(`[a-z]+`, &GeneratorArgs{UniformOutputLength: true, MinOutputLength: 1, MaxOutputLength: 4})
+			counts := make(map[int]int)
+			for i := 0; i < 4000; i++ {
+				counts[len(gen.Generate())]++
+			}
+			So(counts, ShouldHaveLength, 4)
+			for length := 1; length <= 4; length++ {
+				So(counts[length], ShouldBeBetween, 800, 1200)
+			}
+		})
+
+		Convey("Only uses lengths the pattern can generate", func() {
+			gen := newTestGenerator(`(ab)*`, &GeneratorArgs{UniformOutputLength: true, MaxOutputLength: 9})
+			for i := 0; i < SampleSize; i++ {
+				So(regexp.MustCompile(`^(ab){0,4}$`).MatchString(gen.Generate()), ShouldBeTrue)
+			}
+		})
+
+		Convey("Defaults the maximum to the longest string", func() {
+			gen := newTestGenerator(`a{2,6}`, &GeneratorArgs{UniformOutputLength: true})
+			for i := 0; i < SampleSize; i++ {
+				So(len(gen.Generate()), ShouldBeBetweenOrEqual, 2, 6)
+			}
+		})
+
+		Convey("Requires MaxOutputLength for long patterns", func() {
+			_, err := NewGenerator(`(\w+ ){1,50}`, &GeneratorArgs{Flags: syntax.Perl, UniformOutputLength: true})
+			So(errors.Is(err, ErrInvalidArgs), ShouldBeTrue)
+
+			gen := newTestGenerator(`(\w+ ){1,50}`, &GeneratorArgs{Flags: syntax.Perl, UniformOutputLength: true, MaxOutputLength: 100})
+			for i := 0; i < SampleSize; i++ {
+				So(len(gen.Generate()), ShouldBeBetweenOrEqual, 2, 100)
+			}
+		})
+
+		Convey("Returns an error if no string is in range", func() {
+			_, err := NewGenerator(`(abc)+`, &GeneratorArgs{UniformOutputLength: true, MinOutputLength: 4, MaxOutputLength: 5})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp/syntax"
+)
+
+/*
+Node describes a single generator in the tree of generators that make up a Generator.
+
+The tree is built from the simplified expression (see syntax.Regexp.Simplify), so e.g. "a{2,3}" is represented as
+"aaa?". Repeat bounds are resolved, so e.g. the Max of "a*" is MaxUnboundedRepeatCount.
+
+Nodes are copies, so modifying them does not affect the generator. A modified tree can be converted back to an
+expression with Regexp.
+*/
+type Node struct {
+	Op syntax.Op
+	// The expression generated by this node.
+	Expr     string
+	Children []*Node
+
+	// The runes generated by OpLiteral nodes.
+	Runes []rune
+
+	// The runes chosen from by OpCharClass, OpAnyChar, and OpAnyCharNotNL nodes.
+	Ranges []RuneRange
+
+	// The bounds on the number of times the child of OpQuest, OpStar, OpPlus, and OpRepeat nodes is generated.
+	Min, Max int
+
+	// The index and name of OpCapture nodes, as passed to CaptureGroupHandler.
+	CaptureIndex int
+	CaptureName  string
+}
+
+// RuneRange is an inclusive range of runes in a character class.
+type RuneRange struct {
+	Lo, Hi rune
+}
+
+func (r RuneRange) String() string {
+	return tCharClassRange{r.Lo, int32(r.Hi-r.Lo) + 1}.pattern()
+}
+
+// Tree returns the root of a copy of the tree of generators.
+func (gen *internalGenerator) Tree() *Node {
+	node := &Node{
+		Op:   gen.regexp.Op,
+		Expr: gen.String(),
+	}
+
+	switch {
+	case gen.charClass != nil:
+		for _, r := range gen.charClass.Ranges {
+			node.Ranges = append(node.Ranges, RuneRange{r.Start, r.Start + rune(r.Size-1)})
+		}
+	case gen.regexp.Op == syntax.OpLiteral:
+		node.Runes = append([]rune(nil), gen.regexp.Rune...)
+	case gen.regexp.Op == syntax.OpCapture:
+		node.CaptureIndex = gen.regexp.Cap - 1
+		node.CaptureName = gen.regexp.Name
+	case gen.isRepeat():
+		node.Min, node.Max = gen.min, gen.max
+	}
+
+	for _, sub := range gen.subs {
+		node.Children = append(node.Children, sub.Tree())
+	}
+	return node
+}
+
+// Walk calls fn for n and each of its descendants, depth-first. If fn returns false, the children of that node
+// are skipped.
+func (n *Node) Walk(fn func(node *Node) bool) {
+	if fn(n) {
+		for _, child := range n.Children {
+			child.Walk(fn)
+		}
+	}
+}
+
+// Regexp returns an expression that generates the same strings as the tree rooted at n.
+// Repeats are returned as OpRepeat with their resolved bounds, and character classes as OpCharClass.
+func (n *Node) Regexp() *syntax.Regexp {
+	regexp := &syntax.Regexp{Op: n.Op}
+	for _, child := range n.Children {
+		regexp.Sub = append(regexp.Sub, child.Regexp())
+	}
+
+	switch n.Op {
+	case syntax.OpLiteral:
+		regexp.Rune = append([]rune(nil), n.Runes...)
+	case syntax.OpCharClass, syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		regexp.Op = syntax.OpCharClass
+		for _, r := range n.Ranges {
+			regexp.Rune = append(regexp.Rune, r.Lo, r.Hi)
+		}
+	case syntax.OpQuest, syntax.OpStar, syntax.OpPlus, syntax.OpRepeat:
+		regexp.Op = syntax.OpRepeat
+		regexp.Min, regexp.Max = n.Min, n.Max
+	case syntax.OpCapture:
+		regexp.Cap = n.CaptureIndex + 1
+		regexp.Name = n.CaptureName
+	}
+	return regexp
+}
+
+// String returns a multi-line description of the tree rooted at n.
+func (n *Node) String() string {
+	var buffer bytes.Buffer
+	n.writeTo(&buffer, "")
+	return buffer.String()
+}
+
+func (n *Node) writeTo(w io.Writer, indent string) {
+	fmt.Fprintf(w, "%s%s /%s/", indent, opToString(n.Op), n.Expr)
+	switch n.Op {
+	case syntax.OpCharClass, syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		fmt.Fprintf(w, " ranges=%v", n.Ranges)
+	case syntax.OpQuest, syntax.OpStar, syntax.OpPlus, syntax.OpRepeat:
+		fmt.Fprintf(w, " min=%d max=%d", n.Min, n.Max)
+	case syntax.OpCapture:
+		fmt.Fprintf(w, " index=%d name=%q", n.CaptureIndex, n.CaptureName)
+	}
+	fmt.Fprintln(w)
+
+	for _, child := range n.Children {
+		child.writeTo(w, indent+"  ")
+	}
+}
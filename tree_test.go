@@ -0,0 +1,107 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"fmt"
+	"regexp/syntax"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func ExampleNode() {
+	generator, _ := NewGenerator(`(?P<key>[a-z]+)=(on|off)?`, &GeneratorArgs{
+		Flags:                   syntax.Perl,
+		MaxUnboundedRepeatCount: 10,
+	})
+
+	fmt.Print(generator.(ExtendedGenerator).Tree())
+
+	// Output:
+	// OpConcat /(?P<key>[a-z]+)=(o(?:n|ff))?/
+	//   OpCapture /(?P<key>[a-z]+)/ index=0 name="key"
+	//     OpPlus /[a-z]+/ min=1 max=10
+	//       OpCharClass /[a-z]/ ranges=[a-z]
+	//   OpLiteral /=/
+	//   OpQuest /(o(?:n|ff))?/ min=0 max=1
+	//     OpCapture /(o(?:n|ff))/ index=1 name=""
+	//       OpConcat /o(?:n|ff)/
+	//         OpLiteral /o/
+	//         OpAlternate /n|ff/
+	//           OpLiteral /n/
+	//           OpLiteral /ff/
+}
+
+func TestTree(t *testing.T) {
+	t.Parallel()
+
+	tree := func(pattern string) *Node {
+		gen, err := NewGenerator(pattern, &GeneratorArgs{
+			Flags:                   syntax.Perl,
+			MaxUnboundedRepeatCount: 5,
+		})
+		So(err, ShouldBeNil)
+		return gen.(ExtendedGenerator).Tree()
+	}
+
+	Convey("Tree", t, func() {
+		Convey("Reports literals", func() {
+			node := tree(`abc`)
+			So(node.Op, ShouldEqual, syntax.OpLiteral)
+			So(node.Runes, ShouldResemble, []rune("abc"))
+			So(node.Children, ShouldBeEmpty)
+		})
+
+		Convey("Reports char class ranges", func() {
+			So(tree(`[a-c0-9x]`).Ranges, ShouldResemble, []RuneRange{{'0', '9'}, {'a', 'c'}, {'x', 'x'}})
+			So(tree(`(?s:.)`).Ranges, ShouldResemble, []RuneRange{{1, '\U0010ffff'}})
+		})
+
+		Convey("Reports resolved repeat bounds", func() {
+			node := tree(`a*`)
+			So(node.Op, ShouldEqual, syntax.OpStar)
+			So(node.Min, ShouldEqual, 0)
+			So(node.Max, ShouldEqual, 5)
+			So(node.Children, ShouldHaveLength, 1)
+			So(node.Children[0].Op, ShouldEqual, syntax.OpLiteral)
+		})
+
+		Convey("Reports capture groups", func() {
+			node := tree(`(a)(?P<name>b)`)
+			So(node.Children[1].CaptureIndex, ShouldEqual, 1)
+			So(node.Children[1].CaptureName, ShouldEqual, "name")
+		})
+
+		Convey("Walks every node", func() {
+			var ops []syntax.Op
+			tree(`a|b*`).Walk(func(node *Node) bool {
+				ops = append(ops, node.Op)
+				return true
+			})
+			So(ops, ShouldResemble, []syntax.Op{syntax.OpAlternate, syntax.OpLiteral, syntax.OpStar, syntax.OpLiteral})
+		})
+
+		Convey("Converts back to an expression", func() {
+			node := tree(`(?P<id>[0-9]+)-x*`)
+			So(node.Regexp().String(), ShouldEqual, `(?P<id>[0-9]{1,5})-x{0,5}`)
+
+			node.Children[0].Children[0].Max = 2
+			So(node.Regexp().String(), ShouldGenerateStringMatching, `^[0-9]{1,2}-x{0,5}$`, &GeneratorArgs{Flags: syntax.Perl})
+		})
+	})
+}
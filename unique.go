@@ -0,0 +1,331 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"bytes"
+	"math"
+	"math/big"
+	"regexp/syntax"
+	"sort"
+)
+
+// Minimum number of duplicate strings GenerateUnique will tolerate from random generation before
+// switching to enumeration.
+const minUniqueCollisions = 100
+
+// Number of strings in a row GenerateUnique will sample from an enumeration without finding a new one, before
+// giving up. Many numbers may produce the same string, or strings rejected by lookarounds,
+// MustMatch, MustNotMatch or the output length limits.
+const maxUniqueMisses = 10000
+
+/*
+GenerateUnique returns n distinct strings.
+
+Strings are generated randomly until duplicates become common, then the remaining strings are sampled without
+replacement from an enumeration of all the strings the generator can produce. Enumeration uses the expressions
+in capture groups, so it's not used if capture groups are handled by a custom CaptureGroupHandler or Providers.
+
+Returns an ErrUnsatisfiable error if the generator can't produce n distinct strings, or if it stops finding new
+ones, and an ErrInvalidArgs error if n is negative.
+*/
+func (gen *internalGenerator) GenerateUnique(n int) ([]string, error) {
+	if n < 0 {
+		return nil, newError(ErrInvalidArgs, nil, "invalid number of strings %d", n)
+	}
+	results := make([]string, 0, n)
+	seen := make(map[string]bool, n)
+	// add returns false if value is a duplicate or doesn't satisfy the generator's constraints.
+	add := func(value string) bool {
+		if seen[value] || !gen.accepts(value) {
+			return false
+		}
+		seen[value] = true
+		results = append(results, value)
+		return true
+	}
+
+	canEnumerate := !gen.args.customCaptureGroups || !gen.hasCaptureGroups()
+	var sizes *languageSizes
+	if canEnumerate {
+		sizes = newLanguageSizes(gen.outputSlack())
+		if size := sizes.of(gen); size.Cmp(big.NewInt(int64(n))) < 0 {
+			return nil, newError(ErrUnsatisfiable, nil, "cannot generate %d unique strings from /%s/: it only matches %s strings",
+				n, gen, size)
+		}
+	}
+
+	// Random generation is fast and respects capture group handlers, so use it while it keeps finding new strings.
+	maxCollisions := n/10 + minUniqueCollisions
+	for collisions := 0; len(results) < n && collisions < maxCollisions; {
+		if !add(gen.GenerateFunc(gen.args)) {
+			collisions++
+		}
+	}
+	if len(results) == n {
+		return results, nil
+	}
+
+	if !canEnumerate {
+		return nil, newError(ErrUnsatisfiable, nil, "cannot generate %d unique strings from /%s/: only found %d",
+			n, gen, len(results))
+	}
+
+	sampler := newIndexSampler(sizes.of(gen), gen.args)
+	for misses := 0; len(results) < n; {
+		index, ok := sampler.next()
+		if !ok {
+			// Different indices may produce the same string, so the language is smaller than its size.
+			return nil, newError(ErrUnsatisfiable, nil, "cannot generate %d unique strings from /%s/: it only matches %d strings",
+				n, gen, len(results))
+		}
+		if add(sizes.stringAt(gen, index)) {
+			misses = 0
+			continue
+		}
+		if misses++; misses >= maxUniqueMisses {
+			return nil, newError(ErrUnsatisfiable, nil, "cannot generate %d unique strings from /%s/: only found %d",
+				n, gen, len(results))
+		}
+	}
+	return results, nil
+}
+
+func (gen *internalGenerator) hasCaptureGroups() bool {
+	if gen.regexp.Op == syntax.OpCapture {
+		return true
+	}
+	for _, sub := range gen.subs {
+		if sub.hasCaptureGroups() {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+languageSizes memoizes the number of strings each generator can produce, counted as the number of distinct ways
+the generator can make its random choices. This is an upper bound on the number of distinct strings, since
+some expressions can produce the same string in more than one way (e.g. "a?a?").
+
+Repeats are limited to the counts that fit in slack runes (or bytes) of output beyond the minimum length, so
+strings longer than MaxOutputLength are mostly left out.
+
+Strings are numbered from 0 to size-1, and stringAt returns the string with a given number.
+*/
+type languageSizes struct {
+	sizes map[*internalGenerator]*big.Int
+	slack int
+}
+
+// newLanguageSizes returns languageSizes that limit repeats to slack, which may be LengthOverflow.
+func newLanguageSizes(slack int) *languageSizes {
+	return &languageSizes{sizes: make(map[*internalGenerator]*big.Int), slack: slack}
+}
+
+func (sizes *languageSizes) of(gen *internalGenerator) *big.Int {
+	if size, ok := sizes.sizes[gen]; ok {
+		return size
+	}
+
+	size := big.NewInt(1)
+	switch {
+	case gen.charClass != nil:
+		size.SetInt64(int64(gen.charClass.TotalSize))
+
+	case gen.regexp.Op == syntax.OpConcat:
+		for _, sub := range gen.subs {
+			size.Mul(size, sizes.of(sub))
+		}
+
+	case gen.regexp.Op == syntax.OpAlternate:
+		size.SetInt64(0)
+		for _, sub := range gen.subs {
+			size.Add(size, sizes.of(sub))
+		}
+
+	case gen.regexp.Op == syntax.OpCapture:
+		size = sizes.of(gen.subs[0])
+
+	case gen.isRepeat():
+		size = repeatSizeBelow(sizes.of(gen.subs[0]), gen.min, gen.repeatLimit(sizes.slack)+1)
+	}
+
+	sizes.sizes[gen] = size
+	return size
+}
+
+// stringAt returns the string numbered index, which must be less than the size of gen.
+func (sizes *languageSizes) stringAt(gen *internalGenerator, index *big.Int) string {
+	var result bytes.Buffer
+	sizes.writeStringAt(&result, gen, new(big.Int).Set(index))
+	return result.String()
+}
+
+// writeStringAt writes the string numbered index to w. index is modified.
+func (sizes *languageSizes) writeStringAt(w *bytes.Buffer, gen *internalGenerator, index *big.Int) {
+	switch {
+	case gen.charClass != nil:
+		w.WriteRune(gen.charClass.GetRuneAt(int32(index.Int64())))
+
+	case gen.regexp.Op == syntax.OpLiteral:
+		w.WriteString(runesToString(gen.regexp.Rune...))
+
+	case gen.regexp.Op == syntax.OpConcat:
+		sizes.writeProductAt(w, gen.subs, index)
+
+	case gen.regexp.Op == syntax.OpAlternate:
+		for _, sub := range gen.subs {
+			subSize := sizes.of(sub)
+			if index.Cmp(subSize) < 0 {
+				sizes.writeStringAt(w, sub, index)
+				return
+			}
+			index.Sub(index, subSize)
+		}
+		panic("index out of bounds")
+
+	case gen.regexp.Op == syntax.OpCapture:
+		sizes.writeStringAt(w, gen.subs[0], index)
+
+	case gen.isRepeat():
+		subSize := sizes.of(gen.subs[0])
+
+		// Find the largest count whose strings are numbered at or below index.
+		count := gen.min + sort.Search(gen.repeatLimit(sizes.slack)-gen.min+1, func(i int) bool {
+			return repeatSizeBelow(subSize, gen.min, gen.min+i+1).Cmp(index) > 0
+		})
+		index.Sub(index, repeatSizeBelow(subSize, gen.min, count))
+
+		subs := make([]*internalGenerator, count)
+		for i := range subs {
+			subs[i] = gen.subs[0]
+		}
+		sizes.writeProductAt(w, subs, index)
+	}
+}
+
+// writeProductAt writes the string numbered index from the concatenation of gens.
+func (sizes *languageSizes) writeProductAt(w *bytes.Buffer, gens []*internalGenerator, index *big.Int) {
+	subIndex := new(big.Int)
+	for _, sub := range gens {
+		index.DivMod(index, sizes.of(sub), subIndex)
+		sizes.writeStringAt(w, sub, subIndex)
+	}
+}
+
+func (gen *internalGenerator) isRepeat() bool {
+	switch gen.regexp.Op {
+	case syntax.OpQuest, syntax.OpStar, syntax.OpPlus, syntax.OpRepeat:
+		return true
+	}
+	return false
+}
+
+// repeatSizeBelow returns the number of ways to repeat a sub-expression of size subSize at least min times
+// and less than max times: subSize^min + subSize^(min+1) + … + subSize^(max-1).
+func repeatSizeBelow(subSize *big.Int, min, max int) *big.Int {
+	if max <= min {
+		return new(big.Int)
+	}
+
+	switch subSize.Sign() {
+	case 0:
+		if min == 0 {
+			return big.NewInt(1)
+		}
+		return new(big.Int)
+	}
+	if subSize.Cmp(big.NewInt(1)) == 0 {
+		return big.NewInt(int64(max - min))
+	}
+
+	// Geometric series: (subSize^max - subSize^min) / (subSize - 1)
+	size := new(big.Int).Exp(subSize, big.NewInt(int64(max)), nil)
+	size.Sub(size, new(big.Int).Exp(subSize, big.NewInt(int64(min)), nil))
+	return size.Div(size, new(big.Int).Sub(subSize, big.NewInt(1)))
+}
+
+// indexSampler returns random numbers from [0, size) without replacement.
+type indexSampler struct {
+	size *big.Int
+	args *GeneratorArgs
+
+	// For sizes that fit in an int64, a sparse Fisher-Yates shuffle: swapped[i] is the number at position i,
+	// if it's not i.
+	small    bool
+	position int64
+	swapped  map[int64]int64
+
+	// For larger sizes, the numbers returned so far. Collisions are so unlikely that retrying is cheap.
+	returned map[string]bool
+}
+
+func newIndexSampler(size *big.Int, args *GeneratorArgs) *indexSampler {
+	return &indexSampler{
+		size:     size,
+		args:     args,
+		small:    size.Cmp(big.NewInt(math.MaxInt64)) <= 0,
+		swapped:  make(map[int64]int64),
+		returned: make(map[string]bool),
+	}
+}
+
+func (s *indexSampler) next() (*big.Int, bool) {
+	if s.small {
+		size := s.size.Int64()
+		if s.position >= size {
+			return nil, false
+		}
+
+		j := s.position + s.args.rng.Int63n(size-s.position)
+		value := s.lookup(j)
+		s.swapped[j] = s.lookup(s.position)
+		delete(s.swapped, s.position)
+		s.position++
+		return big.NewInt(value), true
+	}
+
+	for {
+		value := new(big.Int).Rand(s.args.rng, s.size)
+		if key := value.String(); !s.returned[key] {
+			s.returned[key] = true
+			return value, true
+		}
+	}
+}
+
+// clone returns a copy of s that returns the same numbers as s from now on, without affecting s.
+func (s *indexSampler) clone() *indexSampler {
+	clone := *s
+	clone.swapped = make(map[int64]int64, len(s.swapped))
+	for i, value := range s.swapped {
+		clone.swapped[i] = value
+	}
+	clone.returned = make(map[string]bool, len(s.returned))
+	for key := range s.returned {
+		clone.returned[key] = true
+	}
+	return &clone
+}
+
+func (s *indexSampler) lookup(i int64) int64 {
+	if value, ok := s.swapped[i]; ok {
+		return value
+	}
+	return i
+}
@@ -0,0 +1,167 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"errors"
+	"math/big"
+	"math/rand"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGenerateUnique(t *testing.T) {
+	t.Parallel()
+
+	newGen := func(pattern string, args *GeneratorArgs) *internalGenerator {
+		if args == nil {
+			args = &GeneratorArgs{RngSource: rand.NewSource(0)}
+		}
+		gen, err := NewGenerator(pattern, args)
+		So(err, ShouldBeNil)
+		return gen.(*internalGenerator)
+	}
+
+	ShouldBeUniqueMatches := func(actual interface{}, expected ...interface{}) string {
+		values := actual.([]string)
+		matcher := regexp.MustCompile(`^(?:` + expected[0].(string) + `)$`)
+		seen := make(map[string]bool)
+		for _, value := range values {
+			if seen[value] {
+				return "duplicate value: " + value
+			}
+			seen[value] = true
+			if !matcher.MatchString(value) {
+				return "value doesn't match: " + value
+			}
+		}
+		return ""
+	}
+
+	Convey("GenerateUnique", t, func() {
+		Convey("Generates the entire language", func() {
+			values, err := newGen(`[0-9]{3}`, nil).GenerateUnique(1000)
+			So(err, ShouldBeNil)
+			So(values, ShouldHaveLength, 1000)
+			So(values, ShouldBeUniqueMatches, `[0-9]{3}`)
+		})
+
+		Convey("Handles skewed distributions", func() {
+			values, err := newGen(`a|b|[0-9]{5}`, nil).GenerateUnique(500)
+			So(err, ShouldBeNil)
+			So(values, ShouldHaveLength, 500)
+			So(values, ShouldBeUniqueMatches, `a|b|[0-9]{5}`)
+		})
+
+		Convey("Handles large languages", func() {
+			values, err := newGen(`[a-z]{20}`, nil).GenerateUnique(100)
+			So(err, ShouldBeNil)
+			So(values, ShouldBeUniqueMatches, `[a-z]{20}`)
+		})
+
+		Convey("Fails when n is larger than the language", func() {
+			_, err := newGen(`[ab]{3}`, nil).GenerateUnique(9)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "it only matches 8 strings")
+		})
+
+		Convey("Fails when the language is smaller than its size", func() {
+			gen := newGen(`a?a?`, nil)
+
+			values, err := gen.GenerateUnique(3)
+			So(err, ShouldBeNil)
+			So(values, ShouldHaveLength, 3)
+
+			_, err = gen.GenerateUnique(4)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "it only matches 3 strings")
+		})
+
+		Convey("Fails when it stops finding new strings", func() {
+			// The choices can be made in about 2^60 ways, but there are only 121 distinct strings.
+			_, err := newGen(`(a|aa){0,60}`, nil).GenerateUnique(200)
+			So(errors.Is(err, ErrUnsatisfiable), ShouldBeTrue)
+
+			gen := newGen(`[a-z]{20}`, &GeneratorArgs{MustNotMatch: []string{`.*[b-z].*`}})
+			values, err := gen.GenerateUnique(1)
+			So(err, ShouldBeNil)
+			So(values, ShouldResemble, []string{strings.Repeat("a", 20)})
+			_, err = gen.GenerateUnique(2)
+			So(errors.Is(err, ErrUnsatisfiable), ShouldBeTrue)
+		})
+
+		Convey("Fails when n is negative", func() {
+			_, err := newGen(`[ab]{3}`, nil).GenerateUnique(-1)
+			So(errors.Is(err, ErrInvalidArgs), ShouldBeTrue)
+		})
+
+		Convey("Fails when capture groups are handled and it runs out", func() {
+			gen := newGen(`([a-z]{5})`, &GeneratorArgs{
+				CaptureGroupHandler: func(index int, name string, group *syntax.Regexp, generator Generator, args *GeneratorArgs) string {
+					return "same"
+				},
+			})
+			_, err := gen.GenerateUnique(2)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "only found 1")
+		})
+
+		Convey("Only enumerates strings within the length limits", func() {
+			gen := newGen(`x(a|b)*`, &GeneratorArgs{MaxOutputLength: 5})
+			values, err := gen.GenerateUnique(31)
+			So(err, ShouldBeNil)
+			So(values, ShouldBeUniqueMatches, `x[ab]{0,4}`)
+
+			gen = newGen(`x(a|b)*`, &GeneratorArgs{MaxOutputLength: 3})
+			_, err = gen.GenerateUnique(8)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "it only matches 7 strings")
+		})
+	})
+
+	Convey("languageSizes", t, func() {
+		args := &GeneratorArgs{MaxUnboundedRepeatCount: 3}
+
+		Convey("Counts repeats", func() {
+			sizes := newLanguageSizes(LengthOverflow)
+			So(sizes.of(newGen(`a*`, args)).Int64(), ShouldEqual, 4)
+			So(sizes.of(newGen(`[a-c]{0,2}`, args)).Int64(), ShouldEqual, 13)
+			So(sizes.of(newGen(`(ab|c)+`, args)).Int64(), ShouldEqual, 2+4+8)
+		})
+
+		Convey("Enumerates every string", func() {
+			for _, pattern := range []string{`[a-c]{0,2}`, `x(ab|c)?y`, `[ab]*`, `^(a|[0-9])[xy]$`} {
+				gen := newGen(pattern, args)
+				sizes := newLanguageSizes(LengthOverflow)
+				size := sizes.of(gen).Int64()
+				matcher := regexp.MustCompile(`^(?:` + pattern + `)$`)
+
+				seen := make(map[string]bool)
+				for i := int64(0); i < size; i++ {
+					value := sizes.stringAt(gen, big.NewInt(i))
+					So(matcher.MatchString(value), ShouldBeTrue)
+					seen[value] = true
+				}
+				So(len(seen), ShouldEqual, size)
+			}
+		})
+	})
+}
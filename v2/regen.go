@@ -0,0 +1,124 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package regen is version 2 of the API of github.com/zach-klippenstein/goregen, in which patterns are parsed exactly
+as regexp.Compile does by default:
+
+	regen.Generate(`\d{3}-(?i:[a-z]+)`)
+
+works without passing syntax.Perl. Set GeneratorArgs.Compatibility to FlagsCompatible to parse patterns with just
+GeneratorArgs.Flags, as version 1 does.
+
+Everything else is shared with version 1, so generators and arguments can be used with either.
+*/
+package regen
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"strconv"
+
+	"github.com/zach-klippenstein/goregen"
+)
+
+type (
+	Generator         = regen.Generator
+	ExtendedGenerator = regen.ExtendedGenerator
+	GeneratorArgs     = regen.GeneratorArgs
+	Compatibility     = regen.Compatibility
+)
+
+const (
+	DefaultCompatibility = regen.DefaultCompatibility
+	FlagsCompatible      = regen.FlagsCompatible
+	RegexpCompatible     = regen.RegexpCompatible
+)
+
+// Generate a random string that matches the regular expression pattern, parsed as regexp.Compile does. The
+// generator is kept in regen.DefaultCache, which is shared with version 1.
+func Generate(pattern string) (string, error) {
+	generator, err := regen.DefaultCache.Generator(pattern, syntax.Perl)
+	if err != nil {
+		return "", err
+	}
+	return generator.Clone(nil).Generate(), nil
+}
+
+// MustGenerate is like Generate, but panics if pattern can't be parsed or generated.
+func MustGenerate(pattern string) string {
+	value, err := Generate(pattern)
+	if err != nil {
+		panic("regen: Generate(" + strconv.Quote(pattern) + "): " + err.Error())
+	}
+	return value
+}
+
+// GenerateN returns n random strings that match the regular expression pattern, parsed as regexp.Compile does.
+// See regen.GenerateN.
+func GenerateN(pattern string, n int) ([]string, error) {
+	if n < 0 {
+		return nil, &regen.Error{Kind: regen.ErrInvalidArgs, Offset: -1, Msg: "invalid number of strings " + strconv.Itoa(n)}
+	}
+	generator, err := regen.DefaultCache.Generator(pattern, syntax.Perl)
+	if err != nil {
+		return nil, err
+	}
+
+	generator = generator.Clone(nil)
+	values := make([]string, n)
+	for i := range values {
+		values[i] = generator.Generate()
+	}
+	return values, nil
+}
+
+// NewGenerator creates a generator that returns random strings that match the regular expression in pattern.
+// Unless args.Compatibility is set, the pattern is parsed as regexp.Compile does, with syntax.Perl in addition to
+// args.Flags. If args is nil, default values are used.
+func NewGenerator(pattern string, inputArgs *GeneratorArgs) (Generator, error) {
+	return regen.NewGenerator(pattern, compatibleArgs(inputArgs))
+}
+
+// NewGeneratorFromRegexp creates a generator that returns random strings that match re. See
+// regen.NewGeneratorFromRegexp.
+func NewGeneratorFromRegexp(re *regexp.Regexp, inputArgs *GeneratorArgs) (Generator, error) {
+	return regen.NewGeneratorFromRegexp(re, compatibleArgs(inputArgs))
+}
+
+// NewGeneratorFromSyntax creates a generator that returns random strings that match the parsed expression re.
+// args.MustMatch and args.MustNotMatch are parsed as NewGenerator parses patterns.
+func NewGeneratorFromSyntax(re *syntax.Regexp, inputArgs *GeneratorArgs) (Generator, error) {
+	return regen.NewGeneratorFromSyntax(re, compatibleArgs(inputArgs))
+}
+
+// DiffPatterns returns up to max strings in each direction that match one pattern but not the other, parsing the
+// patterns as NewGenerator does. See regen.DiffPatterns.
+func DiffPatterns(oldPattern, newPattern string, inputArgs *GeneratorArgs, max int) (*regen.Diff, error) {
+	return regen.DiffPatterns(oldPattern, newPattern, compatibleArgs(inputArgs), max)
+}
+
+// compatibleArgs returns a copy of args that defaults to RegexpCompatible.
+func compatibleArgs(inputArgs *GeneratorArgs) *GeneratorArgs {
+	args := GeneratorArgs{}
+	if inputArgs != nil {
+		args = *inputArgs
+	}
+	if args.Compatibility == DefaultCompatibility {
+		args.Compatibility = RegexpCompatible
+	}
+	return &args
+}
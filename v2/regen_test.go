@@ -0,0 +1,116 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/zach-klippenstein/goregen"
+)
+
+func ExampleNewGenerator() {
+	pattern := `\d{5}`
+
+	generator, _ := NewGenerator(pattern, nil)
+
+	str := generator.Generate()
+
+	if matched, _ := regexp.MatchString("[[:digit:]]{5}", str); matched {
+		fmt.Println("Matches!")
+	}
+	// Output:
+	// Matches!
+}
+
+func TestNewGenerator(t *testing.T) {
+	t.Parallel()
+
+	Convey("NewGenerator", t, func() {
+		Convey("Parses patterns as regexp.Compile does", func() {
+			for _, pattern := range []string{`\d+`, `(?i)ab`, `\pL\PL`, `a+?b??`, `(?s).`, `[[:^alpha:]]\z`} {
+				matcher := regexp.MustCompile(`^(?:` + pattern + `)$`)
+				gen, err := NewGenerator(pattern, &GeneratorArgs{MaxUnboundedRepeatCount: 5})
+				So(err, ShouldBeNil)
+				for i := 0; i < 100; i++ {
+					So(matcher.MatchString(gen.Generate()), ShouldBeTrue)
+				}
+			}
+		})
+
+		Convey("Adds to Flags", func() {
+			gen, err := NewGenerator(`a`, &GeneratorArgs{Flags: syntax.FoldCase})
+			So(err, ShouldBeNil)
+			So(gen.String(), ShouldEqual, `(?i:A)`)
+		})
+
+		Convey("Keeps the original behaviour explicitly", func() {
+			_, err := NewGenerator(`\d`, &GeneratorArgs{Compatibility: FlagsCompatible})
+			So(errors.Is(err, regen.ErrParse), ShouldBeTrue)
+		})
+
+		Convey("Doesn't change the caller's args", func() {
+			args := &GeneratorArgs{}
+			_, err := NewGenerator(`\d`, args)
+			So(err, ShouldBeNil)
+			So(args.Compatibility, ShouldEqual, DefaultCompatibility)
+		})
+	})
+
+	Convey("Generate", t, func() {
+		value, err := Generate(`\d{3}`)
+		So(err, ShouldBeNil)
+		So(regexp.MustCompile(`^[0-9]{3}$`).MatchString(value), ShouldBeTrue)
+	})
+
+	Convey("MustGenerate", t, func() {
+		So(regexp.MustCompile(`^[0-9]{3}$`).MatchString(MustGenerate(`\d{3}`)), ShouldBeTrue)
+		So(func() { MustGenerate(`\p{Foo}`) }, ShouldPanic)
+	})
+
+	Convey("GenerateN", t, func() {
+		values, err := GenerateN(`\d{3}`, 3)
+		So(err, ShouldBeNil)
+		So(values, ShouldHaveLength, 3)
+		for _, value := range values {
+			So(regexp.MustCompile(`^[0-9]{3}$`).MatchString(value), ShouldBeTrue)
+		}
+
+		_, err = GenerateN(`\d`, -1)
+		So(errors.Is(err, regen.ErrInvalidArgs), ShouldBeTrue)
+	})
+
+	Convey("DiffPatterns", t, func() {
+		diff, err := DiffPatterns(`\d`, `[0-9]`, nil, 1)
+		So(err, ShouldBeNil)
+		So(diff.Equivalent(), ShouldBeTrue)
+	})
+
+	Convey("NewGeneratorFromSyntax", t, func() {
+		re, err := syntax.Parse(`[0-9]{2}`, 0)
+		So(err, ShouldBeNil)
+		gen, err := NewGeneratorFromSyntax(re, &GeneratorArgs{MustNotMatch: []string{`\d0`}})
+		So(err, ShouldBeNil)
+		for i := 0; i < 100; i++ {
+			So(gen.Generate(), ShouldNotEndWith, "0")
+		}
+	})
+}